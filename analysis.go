@@ -0,0 +1,507 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/tools/go/callgraph"
+	"golang.org/x/tools/go/callgraph/static"
+	"golang.org/x/tools/go/loader"
+	"golang.org/x/tools/go/ssa"
+)
+
+// AnalysisOptions are the flags which decide how Analyze analyzes a program,
+// which of its findings it reports, and which of those it suppresses.
+type AnalysisOptions struct {
+	// Tests is whether the tests of the packages are entry points of the
+	// program too.
+	Tests bool
+	// Precision is how precisely the call graph is built. If Budget isn't
+	// zero and the call graph isn't built that long after Start, it's
+	// built at PrecisionFast instead.
+	Precision Precision
+	Budget    time.Duration
+	Start     time.Time
+	// Commands is whether findings say which commands can run them.
+	Commands bool
+	// ChangedFiles, if not nil, are the files whose commands are the only
+	// ones analyzed.
+	ChangedFiles map[string]bool
+	// OnlyFile, if not empty, is the absolute name of the only file whose
+	// findings are reported.
+	OnlyFile string
+	// TrustMigrations is whether files embedded in the binary are constant
+	// queries, and the queries migration tools run aren't checked.
+	TrustMigrations bool
+	// Findings of a lower severity or confidence than these are left out.
+	MinSeverity, MinConfidence Level
+	// ConstQueries is whether the constant queries are collected, e.g. to
+	// dump them. ValidateSQL implies it.
+	ConstQueries bool
+	// ValidateSQL is whether constant queries which aren't valid SQL are
+	// reported, in Dialect or, if it's nil, that of the program's drivers.
+	ValidateSQL bool
+	Dialect     *Dialect
+	// BaselineMode is "write" to add the findings to Baseline, "check" to
+	// suppress those in it, or empty.
+	BaselineMode string
+	Baseline     *Baseline
+	// Changed, if not nil, are the lines changed since DiffRef, and
+	// findings on other lines are suppressed.
+	Changed ChangedLines
+	DiffRef string
+	// Gosec, if not nil, suppresses findings on the lines it has SQL
+	// findings on.
+	Gosec *GosecReport
+
+	// Root is the directory file names in messages are relative to, if
+	// not empty. Quiet leaves out the messages which aren't warnings.
+	Root  string
+	Quiet bool
+	// Logger, Deadline and Timings record the progress of the analysis.
+	// Any of them may be nil.
+	Logger   *Logger
+	Deadline *Deadline
+	Timings  *Timings
+}
+
+// An Analysis is what Analyze found in a program.
+type Analysis struct {
+	SSA          *ssa.Program
+	QueryMethods []*QueryMethod
+	// Failures are the packages whose commands were left out because
+	// they couldn't be built.
+	Failures []PackageFailure
+	// Checked counts the calls to query methods which were checked, and
+	// ConstChecked those of them which are passed constant queries.
+	Checked, ConstChecked int
+	ConstQueries          []ConstQuery
+	// Results are the findings, suppressed ones included, in the order
+	// they were found.
+	Results    []Result
+	Suppressed *SuppressionSummary
+	// BelowThreshold counts the findings left out by MinSeverity and
+	// MinConfidence.
+	BelowThreshold int
+	Suppressor     *Suppressor
+}
+
+// The errors Analyze returns when there's nothing to analyze.
+var (
+	errNoDatabase   = errors.New("no packages include a supported database driver")
+	errNoCommands   = errors.New("did not find any commands (i.e., main functions)")
+	errNoneAffected = errors.New("none of the commands are affected by the changed files")
+	errNoneBuilt    = errors.New("none of the commands could be built")
+)
+
+// Analyze builds the commands of p, finds what breaks each rule the
+// configuration enables, and decides which of the findings are suppressed, by
+// ignore comments, the configuration or opts. Suppressed findings, and
+// warnings about the analysis, are written to out. The Analysis returned has
+// the failures found so far, even if there's an error.
+func Analyze(p *loader.Program, config *Config, opts AnalysisOptions, out io.Writer) (*Analysis, error) {
+	a := &Analysis{Suppressed: &SuppressionSummary{}}
+	logger := opts.Logger
+	show := func(pos token.Position) token.Position {
+		return ShowPosition(opts.Root, pos)
+	}
+	inFile := func(pos token.Position) bool {
+		return opts.OnlyFile == "" || pos.Filename == opts.OnlyFile
+	}
+
+	imports := getImports(p)
+	existOne := false
+	for i := range sqlPackages {
+		if _, sqlPackages[i].enable = imports[sqlPackages[i].packageName]; sqlPackages[i].enable {
+			logger.Verbosef("Enabling support for %s", sqlPackages[i].packageName)
+			existOne = true
+		}
+	}
+	if !existOne {
+		return a, errNoDatabase
+	}
+
+	opts.Deadline.Progress("loaded %d packages", len(p.AllPackages))
+	phase := func(name string) {
+		opts.Deadline.Phase(name)
+		opts.Timings.Phase(name)
+	}
+	phase("building SSA")
+	s, mains, err := CreateMains(p, opts.Tests)
+	if err != nil {
+		return a, err
+	}
+	if len(mains) == 0 {
+		return a, errNoCommands
+	}
+	// Most commands in a large repository don't use a database at all, and
+	// needn't be built or analyzed.
+	if dbMains := DatabaseMains(mains); len(dbMains) < len(mains) {
+		logger.Verbosef("Skipping %d commands which don't use a supported database driver", len(mains)-len(dbMains))
+		mains = dbMains
+	}
+	if opts.ChangedFiles != nil {
+		affected := AffectedMains(mains, ChangedPackages(p, opts.ChangedFiles))
+		logger.Verbosef("Skipping %d commands which the changed files don't affect", len(mains)-len(affected))
+		if len(affected) == 0 {
+			return a, errNoneAffected
+		}
+		mains = affected
+	}
+	for _, m := range mains {
+		logger.Debugf("analyzing from main package %s", m.Pkg.Path())
+	}
+	s, mains, a.Failures, err = BuildMains(p, opts.Tests, s, mains, out)
+	if err != nil {
+		return a, err
+	}
+	if len(mains) == 0 {
+		return a, errNoneBuilt
+	}
+	a.SSA = s
+
+	qms := make([]*QueryMethod, 0)
+	for i := range sqlPackages {
+		if sqlPackages[i].enable {
+			qms = append(qms, FindQueryMethods(sqlPackages[i], p.Package(sqlPackages[i].packageName).Pkg, s)...)
+		}
+	}
+	// Instrumented drivers and in-house wrappers have their own DB types,
+	// whose callers are the ones which must pass constant queries.
+	if wrappers := FindWrapperMethods(p, s, qms); len(wrappers) > 0 {
+		logger.Verbosef("Found %d methods which pass their queries on to query methods", len(wrappers))
+		qms = append(qms, wrappers...)
+	}
+	a.QueryMethods = qms
+
+	opts.Deadline.Progress("found %d query methods", len(qms))
+	logger.Verbosef("database driver functions that accept queries:")
+	for _, m := range qms {
+		logger.Verbosef("- %s (param %d)", m.Func, m.Param)
+	}
+	logger.Verbosef("")
+
+	opts.Deadline.Progress("found %d main packages", len(mains))
+	phase(fmt.Sprintf("building the call graph at %s precision", opts.Precision))
+	cgStart := time.Now()
+	var cg *callgraph.Graph
+	var chans *Channels
+	built := opts.Precision
+	switch {
+	case opts.Precision == PrecisionMax && !NeedsPointerAnalysis(s, qms):
+		// Every call to a query method is a static call, so the pointer
+		// analysis wouldn't find any more of them.
+		logger.Verbosef("No calls can reach query methods dynamically, so skipping the pointer analysis")
+		cg = static.CallGraph(s)
+	case opts.Budget > 0:
+		cg, chans, built, err = BuildCallGraphWithin(opts.Budget-time.Since(opts.Start), s, mains, opts.Precision)
+	default:
+		cg, chans, err = BuildCallGraph(s, mains, opts.Precision)
+	}
+	if err != nil {
+		return a, fmt.Errorf("building the call graph: %v", err)
+	}
+	logger.Debugf("building the call graph took %s", time.Since(cgStart).Round(time.Millisecond))
+	if built != opts.Precision {
+		fmt.Fprintf(out, "warning: the call graph wasn't built within the budget of %s, so all packages were analyzed at %s precision instead of %s\n", opts.Budget, built, opts.Precision)
+	}
+	// Findings are attributed to the commands which can run them.
+	var commands *CommandIndex
+	if opts.Commands {
+		commands = NewCommandIndex(cg, mains)
+	}
+	if built < PrecisionMax && !opts.Quiet {
+		fmt.Fprintf(out, "Analyzed at %s precision, so some findings may be in code which is never run, and queries received from channels are always reported\n", built)
+	}
+	partial := make([]string, 0, len(p.Created))
+	for _, info := range p.Created {
+		if !isExternalTest(p, info) {
+			partial = append(partial, info.Pkg.Path())
+		}
+	}
+	if len(partial) > 0 && !opts.Quiet {
+		sort.Strings(partial)
+		fmt.Fprintf(out, "Analyzed %s partially, so findings in their files with errors are missing\n", strings.Join(partial, ", "))
+	}
+
+	cc := &ConstChecker{Chans: chans, Globals: NewGlobals(s), TrustMigrations: opts.TrustMigrations}
+	phase("checking queries")
+	bad, checked := FindNonConstCalls(cg, qms, cc)
+	a.Checked, a.ConstChecked = checked, checked-len(bad)
+	if logger.Enabled(LogDebug) {
+		for _, m := range qms {
+			for _, edge := range cg.CreateNode(m.SSA).In {
+				logger.Debugf("call graph: %s calls %s at %s", edge.Caller.Func, m.Func.FullName(), show(p.Fset.Position(edge.Site.Pos())))
+			}
+		}
+		for _, ci := range bad {
+			shape := "(unknown operand)"
+			if ci.Query != nil {
+				shape = cc.QueryShape(ci.Query)
+			}
+			logger.Debugf("non-constant query %s at %s", shape, show(p.Fset.Position(ci.Site.Pos())))
+		}
+	}
+
+	if opts.ConstQueries || opts.ValidateSQL {
+		for _, q := range FindConstQueries(cg, qms, cc) {
+			if inFile(p.Fset.Position(q.Site.Pos())) {
+				a.ConstQueries = append(a.ConstQueries, q)
+			}
+		}
+	}
+
+	if len(bad) > 0 {
+		logger.Verbosef("Found %d potentially unsafe SQL statements:", len(bad))
+		logger.Verbosef("Please ensure that all SQL queries you use are compile-time constants.")
+		logger.Verbosef("You should always use parameterized queries or prepared statements")
+		logger.Verbosef("instead of building queries from strings.")
+	}
+
+	files := make([]*ast.File, 0)
+	for _, info := range p.AllPackages {
+		files = append(files, info.Files...)
+	}
+	a.Suppressor = NewSuppressor(p.Fset, files)
+
+	// How queries are validated and the placeholders a fix can use depend
+	// on the database driver.
+	dialect, dialectOK := ProgramDialect(s)
+	quoter, quoterOK := ProgramQuoter(p)
+	if opts.Dialect != nil {
+		dialect, dialectOK = *opts.Dialect, true
+	} else if !dialectOK && opts.ValidateSQL {
+		fmt.Fprintln(out, "The program opens databases of several dialects, so its queries can't be validated without -dialect")
+	}
+	if dialectOK {
+		logger.Debugf("using the %s dialect", dialect.Name)
+	}
+
+	findings := make(Findings)
+	var ignoreErr error
+
+	// suppress reports whether result, for an issue which the message
+	// printed about it says is what, is suppressed, by the given ignore
+	// comment or otherwise, or added to the baseline, and records how.
+	suppress := func(result *Result, what string, ignored bool, fp Fingerprint) bool {
+		shown := show(result.Position)
+		if ignored {
+			fmt.Fprintf(out, "- %s %s %s but ignored by comment\n", shown, result.Rule, what)
+			a.Suppressed.Add(SuppressedByComment, shown.Filename)
+			result.Suppression = "inSource"
+		} else if sup := config.Suppression(result.Position.Filename, result.Rule, fp); sup != nil {
+			fmt.Fprintf(out, "- %s %s %s but ignored by configuration (owner: %s, reason: %s)\n", shown, result.Rule, what, sup.Owner, sup.Reason)
+			a.Suppressed.Add(SuppressedByConfig, shown.Filename)
+			result.Suppression, result.Justification = "external", sup.Reason
+		} else if id, ok := opts.Gosec.Covers(result.Position, result.Rule); ok {
+			fmt.Fprintf(out, "- %s %s %s but reported by gosec as %s\n", shown, result.Rule, what, id)
+			a.Suppressed.Add(SuppressedByGosec, shown.Filename)
+			result.Suppression, result.Justification = "external", "reported by gosec as "+id
+		} else if opts.BaselineMode == "write" {
+			opts.Baseline.Add(fp)
+			fmt.Fprintf(out, "- %s %s %s and added to the baseline\n", shown, result.Rule, what)
+		} else if opts.BaselineMode == "check" && opts.Baseline.Contains(fp) {
+			fmt.Fprintf(out, "- %s %s %s but in the baseline\n", shown, result.Rule, what)
+			a.Suppressed.Add(SuppressedByBaseline, shown.Filename)
+			result.BaselineState = "unchanged"
+		} else if opts.Changed != nil && !opts.Changed.Contains(result.Position) {
+			fmt.Fprintf(out, "- %s %s %s but not changed since %s\n", shown, result.Rule, what, opts.DiffRef)
+			a.Suppressed.Add(SuppressedByDiff, shown.Filename)
+			result.BaselineState = "unchanged"
+		} else {
+			return false
+		}
+		return true
+	}
+
+	// report adds result, the finding of an issue which the message printed
+	// about it says is what, to the results, with the severity the
+	// configuration gives its rule, and marked as suppressed if it is. It
+	// reports whether the finding was added: it isn't if it's outside the
+	// OnlyFile, its rule is disabled, it's already been found, or it's
+	// below the severity or confidence threshold.
+	report := func(result Result, what string, fp Fingerprint) bool {
+		pos := result.Position
+		if ignoreErr != nil || !inFile(pos) || !config.RuleEnabled(result.Rule) || !findings.First(result.Rule, pos) {
+			return false
+		}
+		// Checked first, so that the ignore comments of findings below
+		// the thresholds aren't unused.
+		ignored, err := a.Suppressor.Ignored(pos, result.Rule)
+		if err != nil {
+			ignoreErr = err
+			return false
+		}
+		result.Severity = config.Severity(result.Rule, result.Severity)
+		if result.Severity < opts.MinSeverity || result.Confidence < opts.MinConfidence {
+			logger.Verbosef("- %s %s %s but below the severity or confidence threshold (%s severity, %s confidence)", show(pos), result.Rule, what, result.Severity, result.Confidence)
+			a.BelowThreshold++
+			return false
+		}
+		if opts.BaselineMode == "check" || opts.Changed != nil {
+			result.BaselineState = "new"
+		}
+		result.Suppressed = suppress(&result, what, ignored, fp)
+		a.Results = append(a.Results, result)
+		return true
+	}
+
+	sort.SliceStable(bad, func(i, j int) bool {
+		return positionLess(p.Fset.Position(bad[i].Site.Pos()), p.Fset.Position(bad[j].Site.Pos()))
+	})
+	// An injection into any Exec can run statements of its own if a handle
+	// enables multi-statement execution, since which handle it's called on
+	// isn't known.
+	multiStatementOpens := FindMultiStatementOpens(s)
+	sort.Slice(multiStatementOpens, func(i, j int) bool {
+		return positionLess(p.Fset.Position(multiStatementOpens[i]), p.Fset.Position(multiStatementOpens[j]))
+	})
+	multiStatement := func(ci NonConstCall) bool {
+		return len(multiStatementOpens) > 0 && strings.HasPrefix(ci.Method.Func.Name(), "Exec")
+	}
+	warnMultiStatement := func(result *Result) {
+		result.Message += "; multi-statement execution is enabled, so an injection here can run arbitrary statements"
+		for _, open := range multiStatementOpens {
+			result.Related = append(result.Related, FlowStep{p.Fset.Position(open), "multi-statement execution is enabled here"})
+		}
+	}
+	unsafe := make([]NonConstCall, 0)
+	index := make(map[ssa.CallInstruction]int)
+	for _, ci := range bad {
+		// DDL statements have a rule of their own, so that they can be
+		// graded and suppressed separately.
+		rule := queryRule(cc, ci.Query)
+		severity, confidence := cc.Classify(ci.Query)
+		call, query := QueryCall(files, ci.Site, ci.Method)
+		result, fp := queryResult(p, cc, commands, ci, call, query, rule, severity, confidence)
+		// Placeholders can't stand for the identifiers of DDL, which are
+		// quoted instead, and a single one can't stand for a whole IN list.
+		if info := packageInfo(p, ci.Site); call != nil && info != nil && takesArgs(ci.Method) && dialectOK && rule == RuleNonConstQuery {
+			style := dialect.Placeholder
+			if spec, ok := lookupSQLPackage(ci.Method.Func.Pkg().Path()); ok && spec.rewritesPlaceholders {
+				style = "?"
+			}
+			result.Fix = SuggestFix(p.Fset, &info.Info, call, query, style)
+		} else if call != nil && info != nil && quoterOK && rule == RuleDynamicDDL {
+			result.Fix = SuggestQuoteFix(p.Fset, fileAt(info.Files, call.Pos()), &info.Info, call, query, quoter)
+		}
+		if multiStatement(ci) {
+			warnMultiStatement(&result)
+		}
+		if report(result, "is potentially unsafe", fp) && !a.Results[len(a.Results)-1].Suppressed {
+			unsafe = append(unsafe, ci)
+			index[ci.Site] = len(a.Results) - 1
+		}
+	}
+	// Calls which are passed the same query are reported once, with the
+	// others related to the first, which warns about multi-statement
+	// execution if any of them would.
+	grouped := make(map[int]bool)
+	for _, group := range GroupByQuery(unsafe) {
+		first := &a.Results[index[group[0].Site]]
+		warned := multiStatement(group[0])
+		for _, other := range group[1:] {
+			first.Related = append(first.Related, FlowStep{a.Results[index[other.Site]].Position, "same query passed to " + other.Method.Func.FullName()})
+			grouped[index[other.Site]] = true
+			if !warned && multiStatement(other) {
+				warnMultiStatement(first)
+				warned = true
+			}
+		}
+	}
+	kept := a.Results[:0]
+	for i, result := range a.Results {
+		if !grouped[i] {
+			kept = append(kept, result)
+		}
+	}
+	a.Results = kept
+
+	// Data source names built from requests are always of high severity,
+	// unless the configuration says otherwise, and confidence.
+	for _, site := range FindRequestDSNs(s) {
+		result, fp := dsnResult(p, cc, commands, site, LevelHigh)
+		report(result, "is built from an HTTP request", fp)
+	}
+
+	// Raw SQL stored in a struct isn't passed to a query method, but is run
+	// just the same when the struct is.
+	for _, store := range FindNonConstFields(s, cc) {
+		severity, confidence := cc.Classify(store.Val)
+		result, fp := fieldResult(p, cc, commands, store, severity, confidence)
+		report(result, "is potentially unsafe", fp)
+	}
+
+	// Handles escaping into reflection or unsafe code are informational, of
+	// low severity (unless the configuration says otherwise) and low
+	// confidence, since whether any queries are made through them at all
+	// is a guess.
+	unchecked := FindUncheckedUses(s, qms)
+	sort.Slice(unchecked, func(i, j int) bool {
+		return positionLess(p.Fset.Position(unchecked[i].Site.Pos()), p.Fset.Position(unchecked[j].Site.Pos()))
+	})
+	for _, u := range unchecked {
+		what := uncheckedWhat(u)
+		result, fp := uncheckedResult(p, commands, u, what, LevelLow)
+		report(result, what, fp)
+	}
+
+	// SQL built with Sprintf-like functions is an opt-in audit for database
+	// layers safesql doesn't know. Its findings are informational, of low
+	// severity (unless the configuration says otherwise) and low
+	// confidence, since what's built may never be run.
+	if config.RuleEnabled(RuleSQLFormat) {
+		// Commands which use such a layer rather than a database package
+		// safesql knows were skipped, and need building.
+		initial := InitialSSAPackages(p, s)
+		if err := BuildImported(initial); err != nil {
+			return a, err
+		}
+		queries := make([]ssa.Value, 0, len(bad))
+		for _, ci := range bad {
+			queries = append(queries, ci.Query)
+		}
+		sqlFormats := FindSQLFormats(s, initial, queries)
+		sort.Slice(sqlFormats, func(i, j int) bool {
+			return positionLess(p.Fset.Position(sqlFormats[i].Pos()), p.Fset.Position(sqlFormats[j].Pos()))
+		})
+		for _, call := range sqlFormats {
+			format, _ := sprintfFormat(call.Common())
+			result, fp := sqlFormatResult(p, commands, call, format, LevelLow)
+			report(result, "looks like SQL", fp)
+		}
+	}
+
+	// Invalid queries are always of medium severity, unless the configuration
+	// says otherwise, and confidence.
+	if opts.ValidateSQL && dialectOK {
+		for _, q := range a.ConstQueries {
+			if spec, ok := lookupSQLPackage(q.Method.Func.Pkg().Path()); ok && spec.fragments {
+				continue
+			}
+			for _, v := range q.Values {
+				verr := dialect.Validate(v)
+				if verr == nil {
+					continue
+				}
+				logger.Debugf("invalid query %q at %s: %v", v, show(p.Fset.Position(q.Site.Pos())), verr)
+				result, fp := invalidSQLResult(p, cc, commands, q, v, verr, LevelMedium)
+				report(result, "is not valid SQL", fp)
+				// A call which is passed several invalid queries is
+				// only reported once.
+				break
+			}
+		}
+	}
+
+	if ignoreErr != nil {
+		return a, fmt.Errorf("checking for ignore comments: %v", ignoreErr)
+	}
+	return a, nil
+}
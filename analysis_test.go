@@ -0,0 +1,126 @@
+package main
+
+import (
+	"go/build"
+	"go/parser"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/loader"
+)
+
+// loadFixture loads the want fixture pkg from testdata as a GOPATH.
+func loadFixture(t *testing.T, pkg string) *loader.Program {
+	t.Helper()
+	gopath, err := filepath.Abs(testDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Setenv("GO111MODULE", os.Getenv("GO111MODULE"))
+	os.Setenv("GO111MODULE", "off")
+	ctxt := build.Default
+	ctxt.GOPATH = gopath
+	c := loader.Config{Build: &ctxt, FindPackage: FindPackage, ParserMode: parser.ParseComments}
+	c.Import("want/" + pkg)
+	p, err := c.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+// TestAnalyzeOptions checks that the thresholds and the baseline in the
+// options decide which of the findings in the methods fixture are reported,
+// and which of those are suppressed.
+func TestAnalyzeOptions(t *testing.T) {
+	p := loadFixture(t, "methods")
+
+	analyze := func(opts AnalysisOptions) *Analysis {
+		opts.Precision, opts.TrustMigrations = PrecisionMax, true
+		a, err := Analyze(p, &Config{}, opts, ioutil.Discard)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return a
+	}
+	suppressed := func(a *Analysis) int {
+		n := 0
+		for _, result := range a.Results {
+			if result.Suppressed {
+				n++
+			}
+		}
+		return n
+	}
+
+	if a := analyze(AnalysisOptions{}); len(a.Results) != 4 || suppressed(a) != 0 {
+		t.Fatalf("expected 4 findings, none of them suppressed, got %v", a.Results)
+	}
+	// The queries passed in as parameters are of low confidence.
+	if a := analyze(AnalysisOptions{MinConfidence: LevelMedium}); len(a.Results) != 2 || a.BelowThreshold != 2 {
+		t.Errorf("expected 2 findings and 2 below the threshold, got %v and %d", a.Results, a.BelowThreshold)
+	}
+
+	written := &Baseline{}
+	analyze(AnalysisOptions{BaselineMode: "write", Baseline: written})
+	f, err := ioutil.TempFile("", "baseline")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+	if err := written.Write(f.Name()); err != nil {
+		t.Fatal(err)
+	}
+	baseline, err := ReadBaseline(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := analyze(AnalysisOptions{BaselineMode: "check", Baseline: baseline})
+	if len(a.Results) != 4 || suppressed(a) != 4 || a.Suppressed.Total() != 4 {
+		t.Errorf("expected the 4 findings to be in the baseline, got %v", a.Results)
+	}
+	for _, result := range a.Results {
+		if result.BaselineState != "unchanged" {
+			t.Errorf("%s: expected baseline state unchanged, got %q", result.Position, result.BaselineState)
+		}
+	}
+}
+
+// TestMultiStatementWarning checks that every finding about a query passed to
+// an Exec in the multistatement fixture, which opens a handle with
+// multi-statement execution enabled, warns about it, including the first of
+// a group which is only passed the query by another call.
+func TestMultiStatementWarning(t *testing.T) {
+	p := loadFixture(t, "multistatement")
+	a, err := Analyze(p, &Config{}, AnalysisOptions{Precision: PrecisionMax}, ioutil.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[int]bool{13: true, 14: false, 17: true}
+	if len(a.Results) != len(expected) {
+		t.Fatalf("expected %d findings, got %v", len(expected), a.Results)
+	}
+	for _, result := range a.Results {
+		warned, ok := expected[result.Position.Line]
+		if !ok {
+			t.Errorf("unexpected finding %v", result)
+			continue
+		}
+		if strings.Contains(result.Message, "multi-statement execution is enabled") != warned {
+			t.Errorf("line %d: expected a multi-statement warning %v, got %q", result.Position.Line, warned, result.Message)
+		}
+		opens := 0
+		for _, step := range result.Related {
+			if step.Position.Line == 10 {
+				opens++
+			}
+		}
+		if warned && opens != 1 {
+			t.Errorf("line %d: expected the open to be related, got %v", result.Position.Line, result.Related)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// An AuditEntry describes a single suppression, whether or not it currently
+// suppresses anything.
+type AuditEntry struct {
+	// Kind is "comment" for ignore comments and "configuration" for
+	// suppressions in the configuration file.
+	Kind   string `json:"kind"`
+	File   string `json:"file"`
+	Line   int    `json:"line,omitempty"`
+	Column int    `json:"column,omitempty"`
+	// Directive is the text of the ignore comment, or a description of the
+	// criteria of the configured suppression.
+	Directive string   `json:"directive"`
+	Rules     []string `json:"rules,omitempty"`
+	Owner     string   `json:"owner,omitempty"`
+	Reason    string   `json:"reason,omitempty"`
+	// Added and AgeDays are when the ignore comment was last changed,
+	// according to git blame.
+	Added   *time.Time `json:"added,omitempty"`
+	AgeDays *int       `json:"age_days,omitempty"`
+}
+
+// AuditSuppressions returns an inventory of the ignore comments in the given
+// files and the suppressions in the configuration.
+func AuditSuppressions(fset *token.FileSet, files []*ast.File, config *Config) []AuditEntry {
+	entries := make([]AuditEntry, 0)
+	for _, f := range files {
+		for _, cg := range f.Comments {
+			for _, c := range cg.List {
+				rules, ok := ParseIgnoreDirective(c.Text)
+				if !ok {
+					continue
+				}
+				pos := fset.Position(c.Pos())
+				entries = append(entries, AuditEntry{
+					Kind:      "comment",
+					File:      pos.Filename,
+					Line:      pos.Line,
+					Column:    pos.Column,
+					Directive: c.Text,
+					Rules:     rules,
+					Reason:    DirectiveReason(c.Text),
+				})
+			}
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return positionLess(token.Position{Filename: entries[i].File, Line: entries[i].Line, Column: entries[i].Column},
+			token.Position{Filename: entries[j].File, Line: entries[j].Line, Column: entries[j].Column})
+	})
+	for _, s := range config.Suppressions {
+		file := config.filename
+		if s.source != "" {
+			file = s.source
+		}
+		entries = append(entries, AuditEntry{
+			Kind:      "configuration",
+			File:      file,
+			Directive: s.String(),
+			Owner:     s.Owner,
+			Reason:    s.Reason,
+		})
+	}
+	return entries
+}
+
+// DirectiveReason returns the explanation given after an ignore directive, if
+// any.
+func DirectiveReason(text string) string {
+	var rest string
+	if strings.HasPrefix(text, "//nolint:") {
+		rest = strings.TrimPrefix(text, "//nolint:")
+		i := strings.IndexAny(rest, " \t/")
+		if i < 0 {
+			return ""
+		}
+		rest = rest[i:]
+	} else {
+		fields := strings.Fields(strings.TrimPrefix(text, IgnoreDirective))
+		if rules, _ := ParseIgnoreDirective(text); rules != nil {
+			fields = fields[1:]
+		}
+		rest = strings.Join(fields, " ")
+	}
+	rest = strings.TrimSpace(rest)
+	return strings.TrimSpace(strings.TrimPrefix(rest, "//"))
+}
+
+// AddBlameAges records when each ignore comment was last changed, for the
+// comments in files tracked by git. Others are left alone.
+func AddBlameAges(entries []AuditEntry, now time.Time) {
+	for i := range entries {
+		e := &entries[i]
+		if e.Kind != "comment" {
+			continue
+		}
+		added, err := blameTime(e.File, e.Line)
+		if err != nil {
+			continue
+		}
+		days := int(now.Sub(added).Hours() / 24)
+		e.Added, e.AgeDays = &added, &days
+	}
+}
+
+// blameTime returns the author time of the commit which last changed the given
+// line.
+func blameTime(filename string, line int) (time.Time, error) {
+	l := strconv.Itoa(line)
+	out, err := git("-C", filepath.Dir(filename), "blame", "--porcelain", "-L", l+","+l, "--", filepath.Base(filename))
+	if err != nil {
+		return time.Time{}, err
+	}
+	for _, field := range strings.Split(out, "\n") {
+		if strings.HasPrefix(field, "author-time ") {
+			sec, err := strconv.ParseInt(strings.TrimPrefix(field, "author-time "), 10, 64)
+			if err != nil {
+				return time.Time{}, err
+			}
+			return time.Unix(sec, 0).UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("git blame %s:%d: no author time", filename, line)
+}
+
+// WriteAudit writes the audit entries to the given file as JSON.
+func WriteAudit(path string, entries []AuditEntry) error {
+	data, err := json.MarshalIndent(struct {
+		Suppressions []AuditEntry `json:"suppressions"`
+	}{entries}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0644)
+}
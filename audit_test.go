@@ -0,0 +1,61 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"testing"
+)
+
+const auditSrc = `package p
+
+func f() {
+	g() //nolint:safesql // table names are fixed
+	g() //nolint:errcheck
+	//safesql:ignore SAFESQL001 reviewed in SEC-42
+	g()
+	g() //safesql:ignore
+}
+
+func g() error { return nil }
+`
+
+func TestAuditSuppressions(t *testing.T) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", auditSrc, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &Config{
+		Suppressions: []ConfigSuppression{{Package: "example.com/p/...", Function: "f", Owner: "alice", Reason: "legacy"}},
+		filename:     "/src/.safesql.yaml",
+	}
+
+	expected := []AuditEntry{
+		{Kind: "comment", File: "p.go", Line: 4, Column: 6, Directive: "//nolint:safesql // table names are fixed", Reason: "table names are fixed"},
+		{Kind: "comment", File: "p.go", Line: 6, Column: 2, Directive: "//safesql:ignore SAFESQL001 reviewed in SEC-42", Rules: []string{"SAFESQL001"}, Reason: "reviewed in SEC-42"},
+		{Kind: "comment", File: "p.go", Line: 8, Column: 6, Directive: "//safesql:ignore"},
+		{Kind: "configuration", File: "/src/.safesql.yaml", Directive: "package: example.com/p/..., function: f", Owner: "alice", Reason: "legacy"},
+	}
+	if actual := AuditSuppressions(fset, []*ast.File{f}, config); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("AuditSuppressions returned\n%+v\nexpected\n%+v", actual, expected)
+	}
+}
+
+func TestDirectiveReason(t *testing.T) {
+	tests := map[string]string{
+		"//nolint:safesql":                          "",
+		"//nolint:safesql // fixed list":            "fixed list",
+		"//nolint:errcheck,safesql fixed list":      "fixed list",
+		"//safesql:ignore":                          "",
+		"//safesql:ignore fixed list":               "fixed list",
+		"//safesql:ignore SAFESQL001":               "",
+		"//safesql:ignore SAFESQL001 // fixed list": "fixed list",
+	}
+	for text, expected := range tests {
+		if actual := DirectiveReason(text); actual != expected {
+			t.Errorf("DirectiveReason(%q) = %q, expected %q", text, actual, expected)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/ssa"
+)
+
+// A Fingerprint identifies a finding by what it is rather than where it is:
+// the function the call is in, the method being called, and the shape of the
+// query passed to it. Unlike a position, it survives unrelated edits to the
+// rest of the file.
+type Fingerprint struct {
+	Package  string `json:"package"`
+	Function string `json:"function"`
+	Method   string `json:"method"`
+	Query    string `json:"query"`
+}
+
+// NewFingerprint returns the fingerprint of the given call.
+func NewFingerprint(ci NonConstCall, cc *ConstChecker) Fingerprint {
+	fp := CallFingerprint(ci.Site, ci.Method.Func.FullName())
+	if ci.Query != nil {
+		fp.Query = cc.QueryShape(ci.Query)
+	}
+	return fp
+}
+
+// CallFingerprint returns the fingerprint of a call to the given method or
+// function, without a query.
+func CallFingerprint(site ssa.CallInstruction, method string) Fingerprint {
+	fp := Fingerprint{Method: method}
+	if fn := site.Parent(); fn != nil {
+		if fn.Pkg != nil {
+			fp.Package = fn.Pkg.Pkg.Path()
+			fp.Function = fn.RelString(fn.Pkg.Pkg)
+		} else {
+			fp.Function = fn.String()
+		}
+	}
+	return fp
+}
+
+// Hash returns a short, stable identifier for the fingerprint. It's computed
+// from the normalized fingerprint, so that it survives reformatting the query
+// and adding closures around the call.
+func (fp Fingerprint) Hash() string {
+	n := fp.normalize()
+	h := sha256.Sum256([]byte(strings.Join([]string{n.Package, n.Function, n.Method, n.Query}, "\x00")))
+	return hex.EncodeToString(h[:8])
+}
+
+// normalize returns the fingerprint without what can change while the finding
+// stays the same: the numbers of the closures the call is in (the $1 in
+// "(*Store).Search$1"), which change when closures are added before them, and
+// the layout of the query's constant text, whose runs of spaces, tabs and line
+// breaks become single spaces.
+func (fp Fingerprint) normalize() Fingerprint {
+	if i := strings.IndexByte(fp.Function, '$'); i >= 0 {
+		fp.Function = fp.Function[:i]
+	}
+	fp.Query = normalizeShape(fp.Query)
+	return fp
+}
+
+// normalizeShape collapses the whitespace in a query shape, as written by
+// QueryShape, in which the whitespace in constants may be escaped.
+func normalizeShape(shape string) string {
+	var b strings.Builder
+	space := false
+	for i := 0; i < len(shape); i++ {
+		c := shape[i]
+		if c == '\\' && i+1 < len(shape) {
+			switch shape[i+1] {
+			case 'n', 't', 'r':
+				c = ' '
+				i++
+			default:
+				// Some other escape, e.g. \" or \\, whose second
+				// character mustn't be taken for the start of one.
+				b.WriteByte(c)
+				b.WriteByte(shape[i+1])
+				i++
+				space = false
+				continue
+			}
+		}
+		if c == ' ' || c == '\t' || c == '\n' || c == '\r' {
+			if !space {
+				b.WriteByte(' ')
+			}
+			space = true
+			continue
+		}
+		b.WriteByte(c)
+		space = false
+	}
+	return b.String()
+}
+
+// A Baseline is a snapshot of known findings. Checking against a baseline
+// only fails on findings that aren't in it, which makes it possible to adopt
+// safesql on a large codebase without fixing everything first.
+type Baseline struct {
+	Findings []BaselineEntry `json:"findings"`
+
+	// remaining counts how many occurrences of each fingerprint haven't
+	// been matched by Contains yet.
+	remaining map[string]int
+}
+
+// A BaselineEntry records how many findings with a given fingerprint there
+// were when the baseline was written.
+type BaselineEntry struct {
+	Fingerprint
+	Hash  string `json:"fingerprint"`
+	Count int    `json:"count"`
+}
+
+// ReadBaseline reads a baseline written by Baseline.Write.
+func ReadBaseline(path string) (*Baseline, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	b := &Baseline{}
+	if err := json.Unmarshal(data, b); err != nil {
+		return nil, err
+	}
+	// The hashes are computed again, rather than read, so that baselines
+	// written before a change to how findings are normalized still match.
+	b.remaining = make(map[string]int, len(b.Findings))
+	for i, e := range b.Findings {
+		b.Findings[i].Hash = e.Fingerprint.Hash()
+		b.remaining[b.Findings[i].Hash] += e.Count
+	}
+	return b, nil
+}
+
+// Add records a finding in the baseline.
+func (b *Baseline) Add(fp Fingerprint) {
+	hash := fp.Hash()
+	for i := range b.Findings {
+		if b.Findings[i].Hash == hash {
+			b.Findings[i].Count++
+			return
+		}
+	}
+	b.Findings = append(b.Findings, BaselineEntry{Fingerprint: fp, Hash: hash, Count: 1})
+}
+
+// Contains reports whether a finding is in the baseline. Each finding in the
+// baseline only matches once, so that adding another copy of an existing
+// unsafe call is still reported.
+func (b *Baseline) Contains(fp Fingerprint) bool {
+	hash := fp.Hash()
+	if b.remaining[hash] == 0 {
+		return false
+	}
+	b.remaining[hash]--
+	return true
+}
+
+// Write writes the baseline to the given file, sorted so that it diffs well.
+func (b *Baseline) Write(path string) error {
+	sort.Slice(b.Findings, func(i, j int) bool {
+		fi, fj := b.Findings[i], b.Findings[j]
+		if fi.Package != fj.Package {
+			return fi.Package < fj.Package
+		}
+		if fi.Function != fj.Function {
+			return fi.Function < fj.Function
+		}
+		return fi.Hash < fj.Hash
+	})
+	if b.Findings == nil {
+		b.Findings = []BaselineEntry{}
+	}
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0644)
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/tools/go/ssa"
+)
+
+func TestBaseline(t *testing.T) {
+	dir, err := ioutil.TempDir("", "safesql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "baseline.json")
+
+	concat := Fingerprint{Package: "example.com/app", Function: "query", Method: "(*database/sql.DB).Query", Query: `"SELECT " + parameter arg`}
+	sprintf := Fingerprint{Package: "example.com/app", Function: "query", Method: "(*database/sql.DB).Query", Query: `fmt.Sprintf("SELECT %s", parameter arg)`}
+
+	b := &Baseline{}
+	b.Add(concat)
+	b.Add(concat)
+	b.Add(sprintf)
+	if err := b.Write(path); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err = ReadBaseline(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b.Findings) != 2 {
+		t.Fatalf("expected 2 baseline entries, got %d", len(b.Findings))
+	}
+
+	moved := concat
+	moved.Function = "otherQuery"
+	for i, expected := range []bool{true, true, false} {
+		if actual := b.Contains(concat); actual != expected {
+			t.Errorf("Contains(concat) #%d = %v, expected %v", i, actual, expected)
+		}
+	}
+	if !b.Contains(sprintf) {
+		t.Error("expected sprintf finding to be in the baseline")
+	}
+	if b.Contains(moved) {
+		t.Error("expected finding in a different function not to be in the baseline")
+	}
+}
+
+// TestFingerprintNormalization checks that a finding's fingerprint survives
+// reformatting the query and renumbering the closures it's in, but not changes
+// to what the query is built from.
+func TestFingerprintNormalization(t *testing.T) {
+	base := Fingerprint{Package: "example.com/app", Function: "(*Store).Search$1", Method: "(*database/sql.DB).Query", Query: `"SELECT * FROM users WHERE " + parameter where`}
+	tests := map[string]struct {
+		function, query string
+		same            bool
+	}{
+		"reindented":       {function: "(*Store).Search$1", query: `"SELECT *\n\t\tFROM users\n\t\tWHERE " + parameter where`, same: true},
+		"renumbered":       {function: "(*Store).Search$3", query: base.Query, same: true},
+		"nested":           {function: "(*Store).Search$1$2", query: base.Query, same: true},
+		"other_function":   {function: "(*Store).Get$1", query: base.Query},
+		"other_parameter":  {function: base.Function, query: `"SELECT * FROM users WHERE " + parameter filter`},
+		"escaped_newline":  {function: base.Function, query: `"SELECT * FROM users WHERE \\n" + parameter where`},
+		"different_column": {function: base.Function, query: `"SELECT id FROM users WHERE " + parameter where`},
+	}
+	for name, test := range tests {
+		fp := base
+		fp.Function, fp.Query = test.function, test.query
+		if same := fp.Hash() == base.Hash(); same != test.same {
+			t.Errorf("%s: expected the same hash to be %v", name, test.same)
+		}
+	}
+
+	// Baselines are matched by their fingerprints, even if the hashes
+	// recorded in them were computed differently.
+	dir, err := ioutil.TempDir("", "safesql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "baseline.json")
+	b := &Baseline{Findings: []BaselineEntry{{Fingerprint: base, Hash: "0123456789abcdef", Count: 1}}}
+	if err := b.Write(path); err != nil {
+		t.Fatal(err)
+	}
+	if b, err = ReadBaseline(path); err != nil {
+		t.Fatal(err)
+	}
+	if !b.Contains(base) {
+		t.Error("expected the finding to be in a baseline with a stale hash")
+	}
+}
+
+const queryShapeSrc = `package main
+
+import "fmt"
+
+type DB struct{}
+
+func (*DB) Exec(query string) {}
+
+func run(db *DB, table string) {
+	db.Exec("SELECT * FROM " + table)
+	db.Exec(fmt.Sprintf("SELECT %v FROM %s", 1, table))
+	db.Exec(fmt.Sprintf("SELECT %v FROM %s", nil, table))
+}
+`
+
+// queryShapeFmt stubs the part of package fmt queryShapeSrc uses, so that its
+// SSA can be built without the standard library's.
+const queryShapeFmt = `package fmt
+
+func Sprintf(format string, a ...interface{}) string { return format }
+`
+
+// TestQueryShape checks how the queries fingerprints are computed from are
+// rendered.
+func TestQueryShape(t *testing.T) {
+	fset := token.NewFileSet()
+	imp := importerFunc(func(path string) (*types.Package, error) {
+		stub, err := parser.ParseFile(fset, "fmt.go", queryShapeFmt, 0)
+		if err != nil {
+			return nil, err
+		}
+		return (&types.Config{}).Check(path, fset, []*ast.File{stub}, nil)
+	})
+	pkg := buildSource(t, fset, "main.go", queryShapeSrc, types.NewPackage("main", ""), imp)
+	cc := &ConstChecker{Globals: NewGlobals(pkg.Prog)}
+
+	expected := []string{
+		`"SELECT * FROM " + parameter table`,
+		`fmt.Sprintf("SELECT %v FROM %s", 1, parameter table)`,
+		`fmt.Sprintf("SELECT %v FROM %s", nil, parameter table)`,
+	}
+	var shapes []string
+	for _, b := range pkg.Func("run").Blocks {
+		for _, instr := range b.Instrs {
+			if call, ok := instr.(*ssa.Call); ok && call.Common().StaticCallee().Name() == "Exec" {
+				shapes = append(shapes, cc.QueryShape(call.Common().Args[1]))
+			}
+		}
+	}
+	if len(shapes) != len(expected) {
+		t.Fatalf("expected %d queries, got %q", len(expected), shapes)
+	}
+	for i, shape := range shapes {
+		if shape != expected[i] {
+			t.Errorf("expected %s, got %s", expected[i], shape)
+		}
+	}
+}
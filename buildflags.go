@@ -0,0 +1,76 @@
+package main
+
+import (
+	"go/build"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/loader"
+)
+
+// BuildContext returns the build context to load packages with: the default
+// one, with the given comma- (or, as in old versions of Go, space-) separated
+// build tags, for the given operating system and architecture if they aren't
+// empty. As with go build, cgo is disabled when cross-compiling unless
+// CGO_ENABLED says otherwise.
+func BuildContext(tags, goos, goarch string) *build.Context {
+	ctxt := build.Default
+	if tags != "" {
+		ctxt.BuildTags = strings.FieldsFunc(tags, func(r rune) bool {
+			return r == ',' || r == ' '
+		})
+	}
+	if goos != "" {
+		ctxt.GOOS = goos
+	}
+	if goarch != "" {
+		ctxt.GOARCH = goarch
+	}
+	if (ctxt.GOOS != runtime.GOOS || ctxt.GOARCH != runtime.GOARCH) && os.Getenv("CGO_ENABLED") == "" {
+		ctxt.CgoEnabled = false
+	}
+	return &ctxt
+}
+
+// goflagsTags returns the build tags set with -tags in GOFLAGS, if any, which
+// are the default for -tags like they are for go build.
+func goflagsTags() string {
+	tags := ""
+	for _, f := range strings.Fields(os.Getenv("GOFLAGS")) {
+		if strings.HasPrefix(f, "-tags=") {
+			tags = strings.TrimPrefix(f, "-tags=")
+		} else if strings.HasPrefix(f, "--tags=") {
+			tags = strings.TrimPrefix(f, "--tags=")
+		}
+	}
+	return tags
+}
+
+// CgoPackages returns the import paths of the packages in p outside the
+// standard library which have files that use cgo, sorted. If ctxt has cgo
+// disabled, as when cross-compiling, those files were left out, so the code
+// in them wasn't analyzed.
+func CgoPackages(ctxt *build.Context, p *loader.Program) []string {
+	cgoCtxt := *ctxt
+	cgoCtxt.CgoEnabled = true
+	paths := make([]string, 0)
+	for pkg, info := range p.AllPackages {
+		// A package whose files all use cgo has none.
+		var dir string
+		if len(info.Files) > 0 {
+			dir = filepath.Dir(p.Fset.File(info.Files[0].Pos()).Name())
+		} else if bp, err := ctxt.Import(pkg.Path(), "", build.FindOnly); err == nil {
+			dir = bp.Dir
+		} else {
+			continue
+		}
+		if bp, err := cgoCtxt.ImportDir(dir, 0); err == nil && !bp.Goroot && len(bp.CgoFiles) > 0 {
+			paths = append(paths, pkg.Path())
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
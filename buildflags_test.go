@@ -0,0 +1,60 @@
+package main
+
+import (
+	"go/build"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"golang.org/x/tools/go/loader"
+)
+
+func TestBuildContext(t *testing.T) {
+	ctxt := BuildContext("integration,postgres", "plan9", "arm")
+	if !reflect.DeepEqual(ctxt.BuildTags, []string{"integration", "postgres"}) {
+		t.Errorf("unexpected tags %q", ctxt.BuildTags)
+	}
+	if ctxt.GOOS != "plan9" || ctxt.GOARCH != "arm" {
+		t.Errorf("expected plan9/arm, got %s/%s", ctxt.GOOS, ctxt.GOARCH)
+	}
+	if os.Getenv("CGO_ENABLED") == "" && ctxt.CgoEnabled {
+		t.Errorf("expected cgo to be disabled when cross-compiling")
+	}
+}
+
+func TestGoflagsTags(t *testing.T) {
+	defer os.Setenv("GOFLAGS", os.Getenv("GOFLAGS"))
+	os.Setenv("GOFLAGS", "-mod=mod -tags=integration,postgres")
+	if tags := goflagsTags(); tags != "integration,postgres" {
+		t.Errorf("expected the tags in GOFLAGS, got %q", tags)
+	}
+	os.Setenv("GOFLAGS", "-mod=mod")
+	if tags := goflagsTags(); tags != "" {
+		t.Errorf("expected no tags, got %q", tags)
+	}
+}
+
+func TestCgoPackages(t *testing.T) {
+	gopath, err := filepath.Abs(testDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Setenv("GO111MODULE", os.Getenv("GO111MODULE"))
+	os.Setenv("GO111MODULE", "off")
+	ctxt := build.Default
+	ctxt.GOPATH = gopath
+	ctxt.CgoEnabled = false
+
+	// Without cgo, the fixture's only file is left out.
+	c := loader.Config{Build: &ctxt, AllowErrors: true}
+	c.TypeChecker.Error = func(error) {}
+	c.Import("want/cgo")
+	p, err := c.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if paths := CgoPackages(&ctxt, p); !reflect.DeepEqual(paths, []string{"want/cgo"}) {
+		t.Errorf("expected the fixture which uses cgo, got %q", paths)
+	}
+}
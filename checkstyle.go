@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/xml"
+	"io"
+	"sort"
+)
+
+// A CheckstyleReport accumulates findings to write in the Checkstyle XML
+// format understood by Jenkins and other CI report collectors. Checkstyle has
+// no notion of suppressions, so only the findings which fail the run are
+// included.
+type CheckstyleReport struct {
+	root  string
+	files map[string][]Result
+}
+
+// NewCheckstyleReport returns an empty report whose file names are relative
+// to the given directory.
+func NewCheckstyleReport(root string) *CheckstyleReport {
+	return &CheckstyleReport{root: root, files: make(map[string][]Result)}
+}
+
+// Add adds a finding to the report.
+func (r *CheckstyleReport) Add(result Result) {
+	if result.Suppressed {
+		return
+	}
+	name := result.Position.Filename
+	if rel, ok := relPath(r.root, name); ok {
+		name = rel
+	}
+	r.files[name] = append(r.files[name], result)
+}
+
+type checkstyleLog struct {
+	XMLName xml.Name         `xml:"checkstyle"`
+	Version string           `xml:"version,attr"`
+	Files   []checkstyleFile `xml:"file"`
+}
+
+type checkstyleFile struct {
+	Name   string            `xml:"name,attr"`
+	Errors []checkstyleError `xml:"error"`
+}
+
+type checkstyleError struct {
+	Line     int    `xml:"line,attr"`
+	Column   int    `xml:"column,attr,omitempty"`
+	Severity string `xml:"severity,attr"`
+	Message  string `xml:"message,attr"`
+	Source   string `xml:"source,attr"`
+}
+
+// Write writes the report to w, with files sorted by name.
+func (r *CheckstyleReport) Write(w io.Writer) error {
+	names := make([]string, 0, len(r.files))
+	for name := range r.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	log := checkstyleLog{Version: "5.0"}
+	for _, name := range names {
+		f := checkstyleFile{Name: name}
+		for _, res := range r.files[name] {
+			f.Errors = append(f.Errors, checkstyleError{
+				Line:     res.Position.Line,
+				Column:   res.Position.Column,
+				Severity: severityName(res.Severity, "error", "warning", "info"),
+				Message:  res.Message,
+				Source:   "safesql." + res.Rule,
+			})
+		}
+		log.Files = append(log.Files, f)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(log); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
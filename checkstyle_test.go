@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"go/token"
+	"testing"
+)
+
+func TestCheckstyleReport(t *testing.T) {
+	r := NewCheckstyleReport("/src/app")
+	r.Add(Result{
+		Rule:     RuleNonConstQuery,
+		Position: token.Position{Filename: "/src/app/db/db.go", Line: 14, Column: 19},
+		Message:  `Query passed to "Query" is not a compile-time constant`,
+	})
+	r.Add(Result{
+		Rule:       RuleNonConstQuery,
+		Position:   token.Position{Filename: "/src/app/db/db.go", Line: 20, Column: 19},
+		Suppressed: true,
+	})
+	r.Add(Result{
+		Rule:     RuleNonConstQuery,
+		Position: token.Position{Filename: "/src/app/api/api.go", Line: 3, Column: 1},
+		Message:  "Query is not a compile-time constant",
+	})
+
+	var buf bytes.Buffer
+	if err := r.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	expected := `<?xml version="1.0" encoding="UTF-8"?>
+<checkstyle version="5.0">
+  <file name="api/api.go">
+    <error line="3" column="1" severity="error" message="Query is not a compile-time constant" source="safesql.SAFESQL001"></error>
+  </file>
+  <file name="db/db.go">
+    <error line="14" column="19" severity="error" message="Query passed to &#34;Query&#34; is not a compile-time constant" source="safesql.SAFESQL001"></error>
+  </file>
+</checkstyle>
+`
+	if buf.String() != expected {
+		t.Errorf("Write wrote:\n%s\nexpected:\n%s", buf.String(), expected)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"strconv"
+)
+
+// A CodeClimateReport accumulates findings to write in the Code Climate JSON
+// format, which GitLab shows in merge requests as code quality
+// degradations. Only the findings which fail the run are included.
+type CodeClimateReport struct {
+	root   string
+	issues []codeClimateIssue
+	// seen counts the issues with each fingerprint, to keep them unique
+	// when there are several identical findings.
+	seen map[string]int
+}
+
+// NewCodeClimateReport returns an empty report whose paths are relative to the
+// given directory.
+func NewCodeClimateReport(root string) *CodeClimateReport {
+	return &CodeClimateReport{root: root, issues: make([]codeClimateIssue, 0), seen: make(map[string]int)}
+}
+
+type codeClimateIssue struct {
+	Type        string              `json:"type"`
+	CheckName   string              `json:"check_name"`
+	Description string              `json:"description"`
+	Content     *codeClimateContent `json:"content,omitempty"`
+	Categories  []string            `json:"categories"`
+	Severity    string              `json:"severity"`
+	Fingerprint string              `json:"fingerprint"`
+	Location    codeClimateLocation `json:"location"`
+}
+
+// codeClimateContent is the explanation of an issue, in Markdown.
+type codeClimateContent struct {
+	Body string `json:"body"`
+}
+
+type codeClimateLocation struct {
+	Path  string           `json:"path"`
+	Lines codeClimateLines `json:"lines"`
+}
+
+type codeClimateLines struct {
+	Begin int `json:"begin"`
+}
+
+// Add adds a finding to the report.
+func (r *CodeClimateReport) Add(result Result) {
+	if result.Suppressed {
+		return
+	}
+	path := result.Position.Filename
+	if rel, ok := relPath(r.root, path); ok {
+		path = rel
+	}
+	category := "Security"
+	var content *codeClimateContent
+	if rule, ok := LookupRule(result.Rule); ok {
+		category = rule.Category
+		content = &codeClimateContent{codeClimateBody(rule)}
+	}
+	fingerprint := result.Fingerprint
+	if r.seen[fingerprint]++; r.seen[fingerprint] > 1 {
+		fingerprint += "-" + strconv.Itoa(r.seen[fingerprint])
+	}
+	r.issues = append(r.issues, codeClimateIssue{
+		Type:        "issue",
+		CheckName:   result.Rule,
+		Description: result.Message,
+		Content:     content,
+		Categories:  []string{category},
+		Severity:    severityName(result.Severity, "critical", "major", "minor"),
+		Fingerprint: fingerprint,
+		Location: codeClimateLocation{
+			Path:  path,
+			Lines: codeClimateLines{Begin: result.Position.Line},
+		},
+	})
+}
+
+// codeClimateBody returns the explanation of the rule's issues: its help,
+// followed by its CWE and OWASP identifiers and a link to its documentation.
+func codeClimateBody(rule Rule) string {
+	body := rule.Help + "\n\n"
+	if refs := rule.References(); refs != "" {
+		body += refs + ". "
+	}
+	return body + "See " + rule.HelpURI() + "."
+}
+
+// Write writes the report to w.
+func (r *CodeClimateReport) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r.issues)
+}
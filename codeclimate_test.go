@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"go/token"
+	"testing"
+)
+
+func TestCodeClimateReport(t *testing.T) {
+	r := NewCodeClimateReport("/src/app")
+	for _, line := range []int{14, 20} {
+		r.Add(Result{
+			Rule:        RuleNonConstQuery,
+			Position:    token.Position{Filename: "/src/app/db/db.go", Line: line, Column: 19},
+			Message:     "Query is not a compile-time constant",
+			Fingerprint: "0123456789abcdef",
+		})
+	}
+	r.Add(Result{
+		Rule:        RuleNonConstQuery,
+		Position:    token.Position{Filename: "/src/app/db/db.go", Line: 30, Column: 19},
+		Fingerprint: "fedcba9876543210",
+		Suppressed:  true,
+	})
+
+	var buf bytes.Buffer
+	if err := r.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	expected := `[
+  {
+    "type": "issue",
+    "check_name": "SAFESQL001",
+    "description": "Query is not a compile-time constant",
+    "content": {
+      "body": "Queries built from strings at runtime, e.g. with fmt.Sprintf or string concatenation, may be subverted by user-supplied data. Use a constant query with placeholders for the values instead.\n\nCWE-89, A03:2021 - Injection. See https://github.com/stripe/safesql#safesql001."
+    },
+    "categories": [
+      "Security"
+    ],
+    "severity": "critical",
+    "fingerprint": "0123456789abcdef",
+    "location": {
+      "path": "db/db.go",
+      "lines": {
+        "begin": 14
+      }
+    }
+  },
+  {
+    "type": "issue",
+    "check_name": "SAFESQL001",
+    "description": "Query is not a compile-time constant",
+    "content": {
+      "body": "Queries built from strings at runtime, e.g. with fmt.Sprintf or string concatenation, may be subverted by user-supplied data. Use a constant query with placeholders for the values instead.\n\nCWE-89, A03:2021 - Injection. See https://github.com/stripe/safesql#safesql001."
+    },
+    "categories": [
+      "Security"
+    ],
+    "severity": "critical",
+    "fingerprint": "0123456789abcdef-2",
+    "location": {
+      "path": "db/db.go",
+      "lines": {
+        "begin": 20
+      }
+    }
+  }
+]
+`
+	if buf.String() != expected {
+		t.Errorf("Write wrote:\n%s\nexpected:\n%s", buf.String(), expected)
+	}
+}
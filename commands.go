@@ -0,0 +1,84 @@
+package main
+
+import (
+	"go/build"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/callgraph"
+	"golang.org/x/tools/go/ssa"
+)
+
+// ModuleCommands returns the main packages of the module rooted at root, as
+// directories relative to wd, so that they can all be analyzed together in
+// one program: the libraries they share are then only loaded, built and
+// analyzed once, rather than once for each command. If tests is true, it also
+// returns the packages with tests, whose test binaries are commands too.
+// Directories are left out as by ExpandPatterns.
+func ModuleCommands(ctxt *build.Context, root, wd string, exclude []string, tests bool) ([]string, error) {
+	rel, err := filepath.Rel(wd, root)
+	if err != nil {
+		return nil, err
+	}
+	pattern := filepath.ToSlash(rel)
+	if pattern != "." && !strings.HasPrefix(pattern, "../") {
+		pattern = "./" + pattern
+	}
+	cmds := make([]string, 0)
+	for _, pkg := range ExpandPatterns(ctxt, wd, []string{pattern + "/..."}, exclude) {
+		bp, err := ctxt.ImportDir(filepath.Join(wd, filepath.FromSlash(pkg)), 0)
+		if err == nil && (bp.Name == "main" || tests && len(bp.TestGoFiles)+len(bp.XTestGoFiles) > 0) {
+			cmds = append(cmds, pkg)
+		}
+	}
+	return cmds, nil
+}
+
+// CommandIndex records which commands can run each function, according to a
+// call graph, so that the findings of a program made of several commands can
+// be attributed to the binaries they ship in.
+type CommandIndex struct {
+	commands map[*ssa.Function][]string
+}
+
+// NewCommandIndex returns the index of the functions reachable in cg from the
+// main and init functions of each of the given main packages.
+func NewCommandIndex(cg *callgraph.Graph, mains []*ssa.Package) *CommandIndex {
+	sorted := append([]*ssa.Package(nil), mains...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Pkg.Path() < sorted[j].Pkg.Path() })
+
+	idx := &CommandIndex{commands: make(map[*ssa.Function][]string)}
+	for _, m := range sorted {
+		path := m.Pkg.Path()
+		seen := make(map[*callgraph.Node]bool)
+		var queue []*callgraph.Node
+		for _, fn := range []*ssa.Function{m.Func("init"), m.Func("main")} {
+			if n := cg.Nodes[fn]; fn != nil && n != nil && !seen[n] {
+				seen[n] = true
+				queue = append(queue, n)
+			}
+		}
+		for len(queue) > 0 {
+			n := queue[0]
+			queue = queue[1:]
+			idx.commands[n.Func] = append(idx.commands[n.Func], path)
+			for _, e := range n.Out {
+				if !seen[e.Callee] {
+					seen[e.Callee] = true
+					queue = append(queue, e.Callee)
+				}
+			}
+		}
+	}
+	return idx
+}
+
+// Commands returns the import paths of the commands which can run fn, in
+// order. A nil index knows of no commands.
+func (idx *CommandIndex) Commands(fn *ssa.Function) []string {
+	if idx == nil {
+		return nil
+	}
+	return idx.commands[fn]
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"go/build"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"golang.org/x/tools/go/callgraph/static"
+	"golang.org/x/tools/go/loader"
+	"golang.org/x/tools/go/ssa/ssautil"
+)
+
+func TestModuleCommands(t *testing.T) {
+	dir, err := ioutil.TempDir("", "safesql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"go.mod":                  "module example.com/app\n",
+		"cmd/api/main.go":         "package main\n\nfunc main() {}\n",
+		"cmd/worker/main.go":      "package main\n\nfunc main() {}\n",
+		"cmd/tools/gen/main.go":   "package main\n\nfunc main() {}\n",
+		"store/store.go":          "package store\n",
+		"store/store_test.go":     "package store\n",
+		"store/testdata/main.go":  "package main\n\nfunc main() {}\n",
+		"internal/cli/command.go": "package cli\n",
+	}
+	for name, src := range files {
+		name = filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(name, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctxt := build.Default
+	wd := filepath.Join(dir, "store")
+	cmds, err := ModuleCommands(&ctxt, dir, wd, []string{"../cmd/tools/**"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"../cmd/api", "../cmd/worker"}
+	if !reflect.DeepEqual(cmds, expected) {
+		t.Errorf("expected %q, got %q", expected, cmds)
+	}
+
+	// With tests, packages with tests are commands too.
+	if cmds, err = ModuleCommands(&ctxt, dir, wd, []string{"../cmd/tools/**"}, true); err != nil {
+		t.Fatal(err)
+	}
+	expected = []string{"../cmd/api", "../cmd/worker", "."}
+	if !reflect.DeepEqual(cmds, expected) {
+		t.Errorf("with tests, expected %q, got %q", expected, cmds)
+	}
+}
+
+func TestCommandIndex(t *testing.T) {
+	gopath, err := filepath.Abs(testDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Setenv("GO111MODULE", os.Getenv("GO111MODULE"))
+	os.Setenv("GO111MODULE", "off")
+	ctxt := build.Default
+	ctxt.GOPATH = gopath
+
+	c := loader.Config{Build: &ctxt}
+	c.Import("example.com/commands/api")
+	c.Import("example.com/commands/worker")
+	p, err := c.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := ssautil.CreateProgram(p, 0)
+	s.Build()
+	idx := NewCommandIndex(static.CallGraph(s), FindMains(p, s))
+
+	store := s.Package(p.Package("example.com/commands/store").Pkg)
+	for name, expected := range map[string][]string{
+		// The worker calls it while it's initialized.
+		"Shared": {"example.com/commands/api", "example.com/commands/worker"},
+		"Search": {"example.com/commands/api"},
+		"query":  {"example.com/commands/api", "example.com/commands/worker"},
+		"Unused": nil,
+	} {
+		if commands := idx.Commands(store.Func(name)); !reflect.DeepEqual(commands, expected) {
+			t.Errorf("%s: expected %q, got %q", name, expected, commands)
+		}
+	}
+
+	var none *CommandIndex
+	if commands := none.Commands(store.Func("Shared")); commands != nil {
+		t.Errorf("expected no commands from a nil index, got %q", commands)
+	}
+}
+
+func TestTestMains(t *testing.T) {
+	gopath, err := filepath.Abs(testDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Setenv("GO111MODULE", os.Getenv("GO111MODULE"))
+	os.Setenv("GO111MODULE", "off")
+	ctxt := build.Default
+	ctxt.GOPATH = gopath
+
+	c := loader.Config{Build: &ctxt}
+	c.ImportWithTests("example.com/tested")
+	p, err := c.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := ssautil.CreateProgram(p, 0)
+	mains, err := TestMains(p, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mains) != 1 || mains[0].Pkg.Path() != "example.com/tested.test" || mains[0].Func("main") == nil {
+		t.Fatalf("expected the main package of example.com/tested.test, got %v", mains)
+	}
+	if err := BuildImported(mains); err != nil {
+		// e.g. package testing, with a version of Go newer than the
+		// golang.org/x/tools safesql is built with.
+		t.Skipf("package ssa can't build the program: %v", err)
+	}
+	idx := NewCommandIndex(static.CallGraph(s), mains)
+
+	tested := s.Package(p.Package("example.com/tested").Pkg)
+	for name, expected := range map[string][]string{
+		"Find":            {"example.com/tested.test"},
+		"OnlyBenchmarked": {"example.com/tested.test"},
+		"TestMain":        {"example.com/tested.test"},
+		"NotTested":       nil,
+	} {
+		if commands := idx.Commands(tested.Func(name)); !reflect.DeepEqual(commands, expected) {
+			t.Errorf("%s: expected %q, got %q", name, expected, commands)
+		}
+	}
+}
@@ -0,0 +1,372 @@
+package main
+
+import (
+	"fmt"
+	"go/build"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// DefaultConfigFile is the configuration file used when -config isn't given.
+const DefaultConfigFile = ".safesql.yaml"
+
+// Config is the contents of a configuration file.
+type Config struct {
+	// Bundles are the import paths of packages whose BundleFile has more
+	// packages and suppressions, e.g. ones maintained by a security team
+	// for all of an organization's repositories.
+	Bundles      []string            `yaml:"bundles"`
+	Packages     []ConfigPackage     `yaml:"packages"`
+	Suppressions []ConfigSuppression `yaml:"suppressions"`
+	// Severities override the severities of the findings of rules, by rule
+	// identifier, e.g. to grade dynamic DDL (SAFESQL005) below other
+	// queries.
+	Severities map[string]string `yaml:"severities"`
+	// Disabled are the identifiers of the rules whose findings aren't
+	// reported at all, e.g. to adopt safesql one rule at a time.
+	Disabled []string `yaml:"disabled"`
+	// Enabled are the identifiers of the opt-in rules whose findings are
+	// reported, which aren't by default, e.g. SAFESQL007.
+	Enabled []string `yaml:"enabled"`
+
+	// filename is the configuration file, and dir the directory containing
+	// it, which file globs are relative to.
+	filename, dir string
+}
+
+// A ConfigPackage is a database package to check calls to, in addition to the
+// ones safesql knows, e.g. an in-house wrapper around database/sql. Calls to
+// the methods of its types which have a parameter with one of the given names
+// must be passed compile-time constants there.
+type ConfigPackage struct {
+	Package string   `yaml:"package"`
+	Params  []string `yaml:"params"`
+}
+
+// A ConfigSuppression ignores the findings matching all of the criteria it
+// specifies. Keeping these in a single reviewed file, rather than scattered
+// around in ignore comments, lets a security team own the list of waivers.
+type ConfigSuppression struct {
+	// Path is a glob matched against the slash-separated path of the file
+	// relative to the configuration file. "**" matches any number of
+	// directories.
+	Path string `yaml:"path"`
+	// Package is an import path, or an import path followed by "/..." to
+	// match it and all packages below it.
+	Package string `yaml:"package"`
+	// Function is the name of a function or method, e.g. "Search" or
+	// "(*Store).Search". Closures inside it match too.
+	Function string `yaml:"function"`
+	// Fingerprint is the fingerprint of a single finding, as recorded in a
+	// baseline file.
+	Fingerprint string `yaml:"fingerprint"`
+	// Rule is the identifier of the rule of the findings, e.g. SAFESQL005.
+	Rule string `yaml:"rule"`
+
+	Owner  string `yaml:"owner"`
+	Reason string `yaml:"reason"`
+
+	// source is the file the suppression is in, if it's in a bundle.
+	source string
+}
+
+// LoadConfig reads the configuration file at the given path. If the path is
+// the default and the file doesn't exist, an empty configuration is returned.
+func LoadConfig(filename string) (*Config, error) {
+	c := &Config{}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		if filename == DefaultConfigFile && os.IsNotExist(err) {
+			return c, nil
+		}
+		return nil, err
+	}
+	if err := yaml.UnmarshalStrict(data, c); err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	if c.filename, err = filepath.Abs(filename); err != nil {
+		return nil, err
+	}
+	c.dir = filepath.Dir(c.filename)
+	if err := c.check(filename); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// check returns an error if any of the entries in c, read from the given
+// file, are incomplete.
+func (c *Config) check(filename string) error {
+	for i, p := range c.Packages {
+		if p.Package == "" || len(p.Params) == 0 {
+			return fmt.Errorf("%s: package %d must have a package and params", filename, i+1)
+		}
+		if spec, ok := lookupSQLPackage(p.Package); ok && spec.mock {
+			return fmt.Errorf("%s: package %d, %s, is a mock database, whose expectations aren't queries", filename, i+1, p.Package)
+		}
+	}
+	for i, s := range c.Suppressions {
+		if s.Path == "" && s.Package == "" && s.Function == "" && s.Fingerprint == "" && s.Rule == "" {
+			return fmt.Errorf("%s: suppression %d doesn't specify what to suppress", filename, i+1)
+		}
+		if s.Owner == "" || s.Reason == "" {
+			return fmt.Errorf("%s: suppression %d must have an owner and a reason", filename, i+1)
+		}
+		if _, ok := LookupRule(s.Rule); s.Rule != "" && !ok {
+			return fmt.Errorf("%s: suppression %d is for an unknown rule %s", filename, i+1, s.Rule)
+		}
+	}
+	for _, rule := range c.Disabled {
+		if _, ok := LookupRule(rule); !ok {
+			return fmt.Errorf("%s: can't disable unknown rule %s", filename, rule)
+		}
+	}
+	for _, rule := range c.Enabled {
+		if _, ok := LookupRule(rule); !ok {
+			return fmt.Errorf("%s: can't enable unknown rule %s", filename, rule)
+		}
+	}
+	for rule, level := range c.Severities {
+		if _, ok := LookupRule(rule); !ok {
+			return fmt.Errorf("%s: severity for unknown rule %s", filename, rule)
+		}
+		if _, err := ParseLevel(level); err != nil {
+			return fmt.Errorf("%s: severity for %s: %v", filename, rule, err)
+		}
+	}
+	return nil
+}
+
+// RuleEnabled reports whether the findings of the given rule are reported:
+// unless it's disabled, or if it's an opt-in rule, only if it's enabled.
+func (c *Config) RuleEnabled(rule string) bool {
+	if contains(c.Disabled, rule) {
+		return false
+	}
+	if r, ok := LookupRule(rule); ok && r.OptIn {
+		return contains(c.Enabled, rule)
+	}
+	return true
+}
+
+// SetRules enables and disables rules, and overrides their severities, on top
+// of the configuration file, as given by the -enable and -disable flags, which
+// are comma-separated lists of rule identifiers, and the -severity flag, a
+// comma-separated list of rule=level pairs. Enabling a rule also turns it on
+// if it's opt-in.
+func (c *Config) SetRules(enable, disable, severities string) error {
+	for _, rule := range splitList(enable) {
+		if _, ok := LookupRule(rule); !ok {
+			return fmt.Errorf("-enable: unknown rule %s", rule)
+		}
+		disabled := c.Disabled[:0]
+		for _, r := range c.Disabled {
+			if r != rule {
+				disabled = append(disabled, r)
+			}
+		}
+		c.Disabled = disabled
+		if !contains(c.Enabled, rule) {
+			c.Enabled = append(c.Enabled, rule)
+		}
+	}
+	for _, rule := range splitList(disable) {
+		if _, ok := LookupRule(rule); !ok {
+			return fmt.Errorf("-disable: unknown rule %s", rule)
+		}
+		if !contains(c.Disabled, rule) {
+			c.Disabled = append(c.Disabled, rule)
+		}
+	}
+	for _, pair := range splitList(severities) {
+		i := strings.IndexByte(pair, '=')
+		if i < 0 {
+			return fmt.Errorf("-severity: expected rule=level, got %q", pair)
+		}
+		rule, level := pair[:i], pair[i+1:]
+		if _, ok := LookupRule(rule); !ok {
+			return fmt.Errorf("-severity: unknown rule %s", rule)
+		}
+		if _, err := ParseLevel(level); err != nil {
+			return fmt.Errorf("-severity: %s: %v", rule, err)
+		}
+		if c.Severities == nil {
+			c.Severities = make(map[string]string)
+		}
+		c.Severities[rule] = level
+	}
+	return nil
+}
+
+// splitList splits a comma-separated list, ignoring empty elements.
+func splitList(list string) []string {
+	elems := make([]string, 0)
+	for _, e := range strings.Split(list, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			elems = append(elems, e)
+		}
+	}
+	return elems
+}
+
+// Severity returns the severity of a finding of the given rule, which is
+// graded as computed unless the configuration overrides it.
+func (c *Config) Severity(rule string, computed Level) Level {
+	if level, err := ParseLevel(c.Severities[rule]); err == nil {
+		return level
+	}
+	return computed
+}
+
+// BundleFile is the name of the file in a bundle's package directory which
+// has its entries. It's in the same format as the configuration file, except
+// that it can't include other bundles.
+const BundleFile = "safesql.yaml"
+
+// LoadBundles adds the packages, suppressions and rule settings of each of c's
+// bundles to c. Bundles are found like the packages they're in would be
+// imported from the directory of the configuration file, so in module mode,
+// their modules must be required in its go.mod. Paths in their suppressions
+// are relative to that directory too. A bundle's rule settings only apply to
+// the rules which neither the configuration file nor the bundles listed before
+// it set.
+func (c *Config) LoadBundles(ctxt *build.Context) error {
+	for _, path := range c.Bundles {
+		bp, err := FindPackage(ctxt, path, c.dir, build.FindOnly)
+		if err != nil {
+			return fmt.Errorf("bundle %s: %v", path, err)
+		}
+		filename := filepath.Join(bp.Dir, BundleFile)
+		data, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return fmt.Errorf("bundle %s: %v", path, err)
+		}
+		var bundle Config
+		if err := yaml.UnmarshalStrict(data, &bundle); err != nil {
+			return fmt.Errorf("%s: %v", filename, err)
+		}
+		if len(bundle.Bundles) > 0 {
+			return fmt.Errorf("%s: bundles can't include other bundles", filename)
+		}
+		if err := bundle.check(filename); err != nil {
+			return err
+		}
+		c.Packages = append(c.Packages, bundle.Packages...)
+		for _, s := range bundle.Suppressions {
+			s.source = filename
+			c.Suppressions = append(c.Suppressions, s)
+		}
+		c.mergeRules(&bundle)
+	}
+	return nil
+}
+
+// mergeRules adds the rules b disables or enables, and the severities it
+// gives them, to those c doesn't already set.
+func (c *Config) mergeRules(b *Config) {
+	for _, rule := range b.Disabled {
+		if !contains(c.Disabled, rule) && !contains(c.Enabled, rule) {
+			c.Disabled = append(c.Disabled, rule)
+		}
+	}
+	for _, rule := range b.Enabled {
+		if !contains(c.Disabled, rule) && !contains(c.Enabled, rule) {
+			c.Enabled = append(c.Enabled, rule)
+		}
+	}
+	for rule, level := range b.Severities {
+		if _, ok := c.Severities[rule]; ok {
+			continue
+		}
+		if c.Severities == nil {
+			c.Severities = make(map[string]string)
+		}
+		c.Severities[rule] = level
+	}
+}
+
+// Suppression returns the first suppression matching a finding of the given
+// rule with the given position and fingerprint, or nil if there isn't one.
+func (c *Config) Suppression(filename, rule string, fp Fingerprint) *ConfigSuppression {
+	for i := range c.Suppressions {
+		if c.Suppressions[i].matches(c.dir, filename, rule, fp) {
+			return &c.Suppressions[i]
+		}
+	}
+	return nil
+}
+
+func (s *ConfigSuppression) matches(dir, filename, rule string, fp Fingerprint) bool {
+	if s.Rule != "" && s.Rule != rule {
+		return false
+	}
+	if s.Path != "" {
+		rel, err := filepath.Rel(dir, filename)
+		if err != nil || !matchGlob(fileKey(s.Path), filepath.ToSlash(fileKey(rel))) {
+			return false
+		}
+	}
+	if s.Package != "" && s.Package != fp.Package {
+		prefix := strings.TrimSuffix(s.Package, "/...")
+		if prefix == s.Package || (fp.Package != prefix && !strings.HasPrefix(fp.Package, prefix+"/")) {
+			return false
+		}
+	}
+	if s.Function != "" && s.Function != fp.Function && !strings.HasPrefix(fp.Function, s.Function+"$") {
+		return false
+	}
+	if s.Fingerprint != "" && s.Fingerprint != fp.Hash() {
+		return false
+	}
+	return true
+}
+
+// String describes the criteria of the suppression.
+func (s *ConfigSuppression) String() string {
+	var criteria []string
+	for _, c := range []struct{ name, value string }{
+		{"path", s.Path},
+		{"package", s.Package},
+		{"function", s.Function},
+		{"fingerprint", s.Fingerprint},
+		{"rule", s.Rule},
+	} {
+		if c.value != "" {
+			criteria = append(criteria, c.name+": "+c.value)
+		}
+	}
+	return strings.Join(criteria, ", ")
+}
+
+// matchGlob reports whether the slash-separated name matches the pattern,
+// which is a path.Match pattern in which a "**" element matches zero or more
+// path elements.
+func matchGlob(pattern, name string) bool {
+	return matchElems(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchElems(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchElems(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, err := path.Match(pattern[0], name[0]); err != nil || !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
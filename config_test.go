@@ -0,0 +1,254 @@
+package main
+
+import (
+	"go/build"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testConfig = `
+suppressions:
+  - path: internal/legacy/**
+    owner: security@example.com
+    reason: Legacy reporting code, scheduled for removal.
+  - package: example.com/app/admin/...
+    function: (*Store).Search
+    owner: security@example.com
+    reason: Column names come from a fixed list.
+  - fingerprint: 0123456789abcdef
+    owner: alice
+    reason: Reviewed in SEC-42.
+`
+
+func TestConfigSuppression(t *testing.T) {
+	dir, err := ioutil.TempDir("", "safesql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, ".safesql.yaml")
+	if err := ioutil.WriteFile(filename, []byte(testConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := LoadConfig(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		file     string
+		fp       Fingerprint
+		expected int
+	}{
+		"legacy_file":        {file: "internal/legacy/reports/db.go", fp: Fingerprint{Package: "example.com/app/internal/legacy/reports"}, expected: 1},
+		"not_legacy_file":    {file: "internal/legacyx/db.go", fp: Fingerprint{Package: "example.com/app/internal/legacyx"}, expected: 0},
+		"admin_method":       {file: "admin/db.go", fp: Fingerprint{Package: "example.com/app/admin", Function: "(*Store).Search"}, expected: 2},
+		"admin_closure":      {file: "admin/users/db.go", fp: Fingerprint{Package: "example.com/app/admin/users", Function: "(*Store).Search$1"}, expected: 2},
+		"admin_other_method": {file: "admin/db.go", fp: Fingerprint{Package: "example.com/app/admin", Function: "(*Store).Get"}, expected: 0},
+		"other_package":      {file: "api/db.go", fp: Fingerprint{Package: "example.com/app/administrator", Function: "(*Store).Search"}, expected: 0},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			sup := c.Suppression(filepath.Join(dir, filepath.FromSlash(test.file)), RuleNonConstQuery, test.fp)
+			switch {
+			case test.expected == 0 && sup != nil:
+				t.Errorf("expected no suppression, got %+v", sup)
+			case test.expected != 0 && sup != &c.Suppressions[test.expected-1]:
+				t.Errorf("expected suppression %d, got %+v", test.expected, sup)
+			}
+		})
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "safesql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := map[string]string{
+		"no_reason":     "suppressions:\n  - path: a.go\n    owner: alice\n",
+		"no_criteria":   "suppressions:\n  - owner: alice\n    reason: because\n",
+		"unknown_field": "suppresions: []\n",
+		"unknown_rule":  "suppressions:\n  - rule: SAFESQL999\n    owner: alice\n    reason: because\n",
+		"bad_severity":  "severities:\n  SAFESQL005: critical\n",
+		"bad_disabled":  "disabled: [SAFESQL999]\n",
+		"bad_enabled":   "enabled: [SAFESQL999]\n",
+		"mock":          "packages:\n  - package: github.com/DATA-DOG/go-sqlmock\n    params: [expectedSQL]\n",
+	}
+	for name, config := range tests {
+		filename := filepath.Join(dir, name+".yaml")
+		if err := ioutil.WriteFile(filename, []byte(config), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadConfig(filename); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	if _, err := LoadConfig(filepath.Join(dir, "missing.yaml")); err == nil {
+		t.Error("expected an error for a missing configuration file")
+	}
+}
+
+// TestConfigRules checks that suppressions can be limited to a rule, and that
+// rules' severities can be overridden.
+func TestConfigRules(t *testing.T) {
+	dir, err := ioutil.TempDir("", "safesql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, ".safesql.yaml")
+	config := `
+suppressions:
+  - rule: SAFESQL005
+    package: example.com/app/tenants
+    owner: platform@example.com
+    reason: Tenant names are validated when tenants are created.
+severities:
+  SAFESQL005: low
+enabled: [SAFESQL007]
+`
+	if err := ioutil.WriteFile(filename, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c, err := LoadConfig(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fp := Fingerprint{Package: "example.com/app/tenants"}
+	file := filepath.Join(dir, "tenants", "schema.go")
+	if sup := c.Suppression(file, RuleDynamicDDL, fp); sup != &c.Suppressions[0] {
+		t.Errorf("expected the suppression for %s, got %+v", RuleDynamicDDL, sup)
+	}
+	if sup := c.Suppression(file, RuleNonConstQuery, fp); sup != nil {
+		t.Errorf("expected no suppression for %s, got %+v", RuleNonConstQuery, sup)
+	}
+	if severity := c.Severity(RuleDynamicDDL, LevelHigh); severity != LevelLow {
+		t.Errorf("expected the severity of %s to be low, got %s", RuleDynamicDDL, severity)
+	}
+	if severity := c.Severity(RuleNonConstQuery, LevelHigh); severity != LevelHigh {
+		t.Errorf("expected the severity of %s to be unchanged, got %s", RuleNonConstQuery, severity)
+	}
+	if !c.RuleEnabled(RuleSQLFormat) {
+		t.Errorf("expected %s to be enabled", RuleSQLFormat)
+	}
+}
+
+// TestSetRules checks that the flags enabling and disabling rules and setting
+// their severities apply on top of the configuration file.
+func TestSetRules(t *testing.T) {
+	c := &Config{Disabled: []string{RuleUncheckedHandle, RuleDynamicDDL}, Severities: map[string]string{RuleDynamicDDL: "low"}}
+	if err := c.SetRules("SAFESQL005", "SAFESQL003, SAFESQL003", "SAFESQL005=medium,SAFESQL001=high"); err != nil {
+		t.Fatal(err)
+	}
+	for rule, expected := range map[string]bool{
+		RuleNonConstQuery:   true,
+		RuleRequestDSN:      false,
+		RuleUncheckedHandle: false,
+		RuleDynamicDDL:      true,
+	} {
+		if c.RuleEnabled(rule) != expected {
+			t.Errorf("%s: expected enabled to be %v", rule, expected)
+		}
+	}
+	if c.RuleEnabled(RuleSQLFormat) {
+		t.Errorf("expected %s to be off unless it's enabled", RuleSQLFormat)
+	}
+	if err := c.SetRules("SAFESQL007", "", ""); err != nil || !c.RuleEnabled(RuleSQLFormat) {
+		t.Errorf("expected -enable to turn %s on, got %v", RuleSQLFormat, err)
+	}
+	if len(c.Disabled) != 2 {
+		t.Errorf("expected each rule to be disabled once, got %v", c.Disabled)
+	}
+	if severity := c.Severity(RuleDynamicDDL, LevelHigh); severity != LevelMedium {
+		t.Errorf("expected the flag to override the severity of %s, got %s", RuleDynamicDDL, severity)
+	}
+
+	for _, args := range [][3]string{
+		{"SAFESQL999", "", ""},
+		{"", "SAFESQL999", ""},
+		{"", "", "SAFESQL005"},
+		{"", "", "SAFESQL005=critical"},
+	} {
+		if err := (&Config{}).SetRules(args[0], args[1], args[2]); err == nil {
+			t.Errorf("%q: expected an error", args)
+		}
+	}
+}
+
+func TestLoadBundles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "safesql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	bundleDir := filepath.Join(dir, "gopath", "src", "acme.dev", "safesql-rules")
+	if err := os.MkdirAll(bundleDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	bundle := `
+packages:
+  - package: acme.dev/db
+    params: [query]
+suppressions:
+  - path: "**/generated/**"
+    owner: security@acme.dev
+    reason: Generated code is reviewed at the generator.
+disabled: [SAFESQL003, SAFESQL004]
+enabled: [SAFESQL007]
+severities:
+  SAFESQL005: low
+  SAFESQL006: low
+`
+	if err := ioutil.WriteFile(filepath.Join(bundleDir, BundleFile), []byte(bundle), 0644); err != nil {
+		t.Fatal(err)
+	}
+	filename := filepath.Join(dir, ".safesql.yaml")
+	if err := ioutil.WriteFile(filename, []byte("bundles: [acme.dev/safesql-rules]\nenabled: [SAFESQL004]\nseverities: {SAFESQL005: high}\n"+testConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := LoadConfig(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctxt := build.Default
+	ctxt.GOPATH = filepath.Join(dir, "gopath")
+	defer os.Setenv("GO111MODULE", os.Getenv("GO111MODULE"))
+	os.Setenv("GO111MODULE", "off")
+	if err := c.LoadBundles(&ctxt); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Packages) != 1 || c.Packages[0].Package != "acme.dev/db" {
+		t.Errorf("expected the bundle's package, got %+v", c.Packages)
+	}
+	if len(c.Suppressions) != 4 {
+		t.Fatalf("expected the bundle's suppression after the others, got %+v", c.Suppressions)
+	}
+	if sup := c.Suppression(filepath.Join(dir, "api", "generated", "db.go"), RuleNonConstQuery, Fingerprint{}); sup != &c.Suppressions[3] {
+		t.Errorf("expected the bundle's suppression to match relative to the configuration file, got %+v", sup)
+	}
+	// The configuration file's rule settings override the bundle's.
+	if c.RuleEnabled(RuleRequestDSN) || !c.RuleEnabled(RuleUncheckedHandle) || !c.RuleEnabled(RuleSQLFormat) {
+		t.Errorf("expected the bundle to disable SAFESQL003 and enable SAFESQL007, but not disable SAFESQL004, got %v and %v", c.Disabled, c.Enabled)
+	}
+	if got := c.Severity(RuleDynamicDDL, LevelMedium); got != LevelHigh {
+		t.Errorf("expected the configuration file's severity for SAFESQL005, got %s", got)
+	}
+	if got := c.Severity(RuleInList, LevelMedium); got != LevelLow {
+		t.Errorf("expected the bundle's severity for SAFESQL006, got %s", got)
+	}
+
+	c.Bundles = []string{"acme.dev/missing"}
+	if err := c.LoadBundles(&ctxt); err == nil {
+		t.Errorf("expected an error for a missing bundle")
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// csvHeader is the header row of the CSV format. The columns, and their
+// order, are part of the format: spreadsheets and scripts refer to them by
+// position, so new ones may only be added at the end.
+var csvHeader = []string{"file", "line", "col", "rule", "severity", "message", "fingerprint"}
+
+// A CSVReport accumulates findings to write as CSV, a row per finding, for
+// triage in spreadsheets. Only the findings which fail the run are included.
+type CSVReport struct {
+	root    string
+	results []Result
+}
+
+// NewCSVReport returns an empty report whose file names are relative to the
+// given directory.
+func NewCSVReport(root string) *CSVReport {
+	return &CSVReport{root: root}
+}
+
+// Add adds a finding to the report.
+func (r *CSVReport) Add(result Result) {
+	if !result.Suppressed {
+		r.results = append(r.results, result)
+	}
+}
+
+// Write writes the report to w: the header row, followed by the findings
+// sorted by position.
+func (r *CSVReport) Write(w io.Writer) error {
+	SortResults(r.results)
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, res := range r.results {
+		name := res.Position.Filename
+		if rel, ok := relPath(r.root, name); ok {
+			name = rel
+		}
+		err := cw.Write([]string{
+			csvCell(name),
+			strconv.Itoa(res.Position.Line),
+			strconv.Itoa(res.Position.Column),
+			res.Rule,
+			severityName(res.Severity, "high", "medium", "low"),
+			csvCell(res.Message),
+			res.Fingerprint,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// csvCell returns s as a cell which spreadsheets won't evaluate as a formula:
+// one starting with =, +, - or @ is prefixed with a single quote, so that
+// e.g. a file named to look like a formula can't run one when the report is
+// opened.
+func csvCell(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"go/token"
+	"testing"
+)
+
+func TestCSVReport(t *testing.T) {
+	r := NewCSVReport("/src/app")
+	r.Add(Result{
+		Rule:        RuleNonConstQuery,
+		Position:    token.Position{Filename: "/src/app/db/db.go", Line: 14, Column: 19},
+		Message:     `Query passed to "Query" is not a compile-time constant`,
+		Severity:    LevelHigh,
+		Fingerprint: "5f1b2c3d4e5f6a7b",
+	})
+	r.Add(Result{
+		Rule:       RuleNonConstQuery,
+		Position:   token.Position{Filename: "/src/app/db/db.go", Line: 20, Column: 19},
+		Suppressed: true,
+	})
+	r.Add(Result{
+		Rule:        RuleDynamicDDL,
+		Position:    token.Position{Filename: "/src/app/api/api.go", Line: 3, Column: 1},
+		Message:     "DDL statement passed to Exec is not a compile-time constant; check or quote the identifiers it's built from",
+		Severity:    LevelMedium,
+		Fingerprint: "0123456789abcdef",
+	})
+	r.Add(Result{
+		Rule:     RuleUncheckedHandle,
+		Position: token.Position{Filename: "/src/app/=cmd()/main.go", Line: 7, Column: 2},
+		Message:  "Database handle is passed to package reflect",
+		Severity: LevelLow,
+	})
+
+	var buf bytes.Buffer
+	if err := r.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	expected := `file,line,col,rule,severity,message,fingerprint
+'=cmd()/main.go,7,2,SAFESQL004,low,Database handle is passed to package reflect,
+api/api.go,3,1,SAFESQL005,medium,DDL statement passed to Exec is not a compile-time constant; check or quote the identifiers it's built from,0123456789abcdef
+db/db.go,14,19,SAFESQL001,high,"Query passed to ""Query"" is not a compile-time constant",5f1b2c3d4e5f6a7b
+`
+	if buf.String() != expected {
+		t.Errorf("Write wrote:\n%s\nexpected:\n%s", buf.String(), expected)
+	}
+}
@@ -0,0 +1,969 @@
+package main
+
+import (
+	"fmt"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/pointer"
+	"golang.org/x/tools/go/ssa"
+	"golang.org/x/tools/go/ssa/ssautil"
+)
+
+// Channels keeps track of the strings sent on every string channel in the
+// program, so that a query received from a channel (e.g. by a worker goroutine
+// executing queries on behalf of others) can be checked against everything
+// that was sent on it.
+type Channels struct {
+	// sends holds the channel operand and sent value of every send.
+	sends [][2]ssa.Value
+	// sent maps each channel, identified by the instruction that made it,
+	// to the values sent on it. It is populated by Resolve.
+	sent    map[ssa.Value][]ssa.Value
+	queries map[ssa.Value]pointer.Pointer
+}
+
+// AddChannelQueries registers pointer queries for the channel operands of every
+// send and receive of a string in the program. It must be called before the
+// pointer analysis is run, and the result resolved afterwards.
+func AddChannelQueries(s *ssa.Program, config *pointer.Config) *Channels {
+	c := &Channels{}
+	query := func(ch ssa.Value) {
+		if !isStringChan(ch.Type()) {
+			return
+		}
+		config.AddQuery(ch)
+	}
+	for fn := range ssautil.AllFunctions(s) {
+		if fn.Pkg == nil || isSQLPackage(fn.Pkg.Pkg.Path()) {
+			continue
+		}
+		for _, b := range fn.Blocks {
+			for _, instr := range b.Instrs {
+				switch instr := instr.(type) {
+				case *ssa.Send:
+					query(instr.Chan)
+					c.sends = append(c.sends, [2]ssa.Value{instr.Chan, instr.X})
+				case *ssa.UnOp:
+					if instr.Op == token.ARROW {
+						query(instr.X)
+					}
+				case *ssa.Select:
+					for _, st := range instr.States {
+						query(st.Chan)
+						if st.Dir == types.SendOnly {
+							c.sends = append(c.sends, [2]ssa.Value{st.Chan, st.Send})
+						}
+					}
+				}
+			}
+		}
+	}
+	return c
+}
+
+// Resolve matches up sends and receives using the results of the pointer
+// analysis.
+func (c *Channels) Resolve(res *pointer.Result) {
+	c.queries = res.Queries
+	c.sent = make(map[ssa.Value][]ssa.Value)
+	for _, send := range c.sends {
+		ptr, ok := c.queries[send[0]]
+		if !ok {
+			continue
+		}
+		for _, l := range ptr.PointsTo().Labels() {
+			c.sent[l.Value()] = append(c.sent[l.Value()], send[1])
+		}
+	}
+}
+
+// Sent returns the values that may have been sent on the given channel, or
+// false if we don't know where the channel came from.
+func (c *Channels) Sent(ch ssa.Value) ([]ssa.Value, bool) {
+	if c == nil || c.queries == nil {
+		return nil, false
+	}
+	ptr, ok := c.queries[ch]
+	if !ok {
+		return nil, false
+	}
+	labels := ptr.PointsTo().Labels()
+	if len(labels) == 0 {
+		return nil, false
+	}
+	values := make([]ssa.Value, 0)
+	for _, l := range labels {
+		if _, ok := l.Value().(*ssa.MakeChan); !ok {
+			// Channels made by reflection or in code we didn't analyze.
+			return nil, false
+		}
+		values = append(values, c.sent[l.Value()]...)
+	}
+	return values, true
+}
+
+func isStringChan(t types.Type) bool {
+	ch, ok := t.Underlying().(*types.Chan)
+	if !ok {
+		return false
+	}
+	b, ok := ch.Elem().Underlying().(*types.Basic)
+	return ok && b.Info()&types.IsString != 0
+}
+
+// Globals indexes the uses of the program's package-level variables, so that
+// a query loaded from one (often initialized in a var block or in an init
+// function) can be checked against everything that was ever stored in it.
+type Globals struct {
+	prog *ssa.Program
+	uses map[*ssa.Global][]ssa.Instruction
+}
+
+// NewGlobals returns an index of the uses of the package-level variables in
+// the given program. The index itself is built the first time it is needed.
+func NewGlobals(s *ssa.Program) *Globals {
+	return &Globals{prog: s}
+}
+
+// Stored returns the values that may have been stored in the given variable,
+// or false if its address escapes in a way that lets it be modified behind
+// our back.
+func (g *Globals) Stored(global *ssa.Global) ([]ssa.Value, bool) {
+	if g == nil {
+		return nil, false
+	}
+	if g.uses == nil {
+		g.index()
+	}
+	values := make([]ssa.Value, 0)
+	for _, instr := range g.uses[global] {
+		switch instr := instr.(type) {
+		case *ssa.Store:
+			if instr.Addr == global {
+				values = append(values, instr.Val)
+				continue
+			}
+		case *ssa.UnOp:
+			if instr.Op == token.MUL {
+				continue
+			}
+		}
+		return nil, false
+	}
+	return values, true
+}
+
+// WrittenOnce returns the value stored in the given variable if it's stored
+// exactly once, when its package is initialized, and its address doesn't
+// escape, so that it never changes once the program runs.
+func (g *Globals) WrittenOnce(global *ssa.Global) (ssa.Value, bool) {
+	if g == nil {
+		return nil, false
+	}
+	if g.uses == nil {
+		g.index()
+	}
+	var stored *ssa.Store
+	for _, instr := range g.uses[global] {
+		switch instr := instr.(type) {
+		case *ssa.Store:
+			if instr.Addr == global && stored == nil && isPackageInit(instr.Parent(), global.Pkg) {
+				stored = instr
+				continue
+			}
+		case *ssa.UnOp:
+			if instr.Op == token.MUL {
+				continue
+			}
+		}
+		return nil, false
+	}
+	if stored == nil {
+		return nil, false
+	}
+	return stored.Val, true
+}
+
+// isPackageInit reports whether fn is run once, when pkg is initialized: it's
+// the package initializer, in which var blocks are, or one of its init
+// functions.
+func isPackageInit(fn *ssa.Function, pkg *ssa.Package) bool {
+	if fn.Pkg != pkg || fn.Parent() != nil || fn.Signature.Recv() != nil {
+		return false
+	}
+	return fn.Name() == "init" || strings.HasPrefix(fn.Name(), "init#")
+}
+
+// Loads returns every load of the given variable.
+func (g *Globals) Loads(global *ssa.Global) []*ssa.UnOp {
+	if g == nil {
+		return nil
+	}
+	if g.uses == nil {
+		g.index()
+	}
+	loads := make([]*ssa.UnOp, 0)
+	for _, instr := range g.uses[global] {
+		if load, ok := instr.(*ssa.UnOp); ok && load.Op == token.MUL {
+			loads = append(loads, load)
+		}
+	}
+	return loads
+}
+
+func (g *Globals) index() {
+	g.uses = make(map[*ssa.Global][]ssa.Instruction)
+	var ops []*ssa.Value
+	for fn := range ssautil.AllFunctions(g.prog) {
+		for _, b := range fn.Blocks {
+			for _, instr := range b.Instrs {
+				ops = instr.Operands(ops[:0])
+				for _, op := range ops {
+					if global, ok := (*op).(*ssa.Global); ok {
+						g.uses[global] = append(g.uses[global], instr)
+					}
+				}
+			}
+		}
+	}
+}
+
+// ConstChecker decides whether SSA values are compile-time constants, or can
+// only ever hold compile-time constants. Besides constants themselves, this
+// looks through conversions to interfaces, phi nodes, strings received from
+// channels on which only constants are sent, and package-level variables and
+// maps in which only constants are stored. Either field may be nil, in which
+// case values received from channels or loaded from variables respectively
+// are never considered constant.
+type ConstChecker struct {
+	Chans   *Channels
+	Globals *Globals
+	// TrustMigrations is whether the contents of files embedded in the
+	// binary, such as migrations, count as constants.
+	TrustMigrations bool
+}
+
+// IsConst reports whether v only ever holds compile-time constants.
+func (c *ConstChecker) IsConst(v ssa.Value) bool {
+	return c.isConst(v, make(map[ssa.Value]bool), nil)
+}
+
+// ConstValues returns the strings a value which only ever holds compile-time
+// constants can hold, or false if it isn't constant.
+func (c *ConstChecker) ConstValues(v ssa.Value) ([]string, bool) {
+	values := make([]string, 0)
+	seen := make(map[string]bool)
+	isConst := c.isConst(v, make(map[ssa.Value]bool), func(k *ssa.Const) {
+		if k.Value == nil || k.Value.Kind() != constant.String {
+			return
+		}
+		if s := constant.StringVal(k.Value); !seen[s] {
+			seen[s] = true
+			values = append(values, s)
+		}
+	})
+	if !isConst {
+		return nil, false
+	}
+	return values, true
+}
+
+func (c *ConstChecker) isConst(v ssa.Value, visiting map[ssa.Value]bool, found func(*ssa.Const)) bool {
+	// Values we're already looking at are part of a cycle (e.g. a phi in a
+	// loop, or a worker which sends what it receives). The cycle can't
+	// introduce anything non-constant on its own, so it's up to the other
+	// inputs to decide.
+	if visiting[v] {
+		return true
+	}
+	visiting[v] = true
+
+	switch v := v.(type) {
+	case *ssa.Const:
+		if found != nil {
+			found(v)
+		}
+		return true
+	case *ssa.MakeInterface:
+		return c.isConst(v.X, visiting, found)
+	case *ssa.Convert:
+		// string(b), where b is an embedded file.
+		return c.TrustMigrations && c.isEmbedded(v.X)
+	case *ssa.Phi:
+		for _, e := range v.Edges {
+			if !c.isConst(e, visiting, found) {
+				return false
+			}
+		}
+		return true
+	case *ssa.UnOp:
+		switch {
+		case v.Op == token.ARROW && !v.CommaOk:
+			return c.isConstRecv(v.X, visiting, found)
+		case v.Op == token.MUL:
+			if global, ok := v.X.(*ssa.Global); ok {
+				return c.isConstGlobal(global, visiting, found)
+			}
+		}
+	case *ssa.Lookup:
+		// m[k], where m is a map of constants.
+		if _, ok := v.X.Type().Underlying().(*types.Map); ok && !v.CommaOk {
+			return c.isConstMap(v.X, visiting, found)
+		}
+	case *ssa.Extract:
+		switch t := v.Tuple.(type) {
+		case *ssa.UnOp:
+			// v, ok := <-ch
+			if t.Op == token.ARROW && v.Index == 0 {
+				return c.isConstRecv(t.X, visiting, found)
+			}
+		case *ssa.Lookup:
+			// v, ok := m[k]
+			if v.Index == 0 {
+				return c.isConstMap(t.X, visiting, found)
+			}
+		case *ssa.Next:
+			// for _, v := range m
+			if !t.IsString && v.Index == 2 {
+				if r, ok := t.Iter.(*ssa.Range); ok {
+					return c.isConstMap(r.X, visiting, found)
+				}
+			}
+		case *ssa.Select:
+			// The received values follow the index and the recvOk.
+			recv := 2
+			for _, st := range t.States {
+				if st.Dir != types.RecvOnly {
+					continue
+				}
+				if recv == v.Index {
+					return c.isConstRecv(st.Chan, visiting, found)
+				}
+				recv++
+			}
+		}
+	}
+	return false
+}
+
+func (c *ConstChecker) isConstRecv(ch ssa.Value, visiting map[ssa.Value]bool, found func(*ssa.Const)) bool {
+	sent, ok := c.Chans.Sent(ch)
+	if !ok {
+		return false
+	}
+	return c.allConst(sent, visiting, found)
+}
+
+func (c *ConstChecker) isConstGlobal(global *ssa.Global, visiting map[ssa.Value]bool, found func(*ssa.Const)) bool {
+	stored, ok := c.Globals.Stored(global)
+	if !ok {
+		return false
+	}
+	if c.allConst(stored, visiting, found) {
+		return true
+	}
+	// A string variable written once, when its package is initialized, is
+	// constant too if it's built from constants, e.g. by concatenating
+	// others or with a helper which formats a query. Its value is only
+	// known if it's a concatenation.
+	value, ok := c.Globals.WrittenOnce(global)
+	if !ok || !isString(value.Type()) || !c.builtFromConsts(value, make(map[ssa.Value]bool)) {
+		return false
+	}
+	if s, ok := c.foldString(value, make(map[ssa.Value]bool)); ok && found != nil {
+		found(ssa.NewConst(constant.MakeString(s), value.Type()))
+	}
+	return true
+}
+
+// pureFuncs are the functions of the standard library whose results only
+// depend on their arguments, so that they're constant if their arguments are.
+var pureFuncs = map[string]bool{
+	"fmt.Sprint":         true,
+	"fmt.Sprintf":        true,
+	"strconv.Itoa":       true,
+	"strings.Join":       true,
+	"strings.Repeat":     true,
+	"strings.Replace":    true,
+	"strings.ReplaceAll": true,
+	"strings.ToLower":    true,
+	"strings.ToUpper":    true,
+	"strings.TrimSpace":  true,
+}
+
+// builtFromConsts reports whether v, computed when a package is initialized,
+// is built only from constants: by concatenating them, or by calling pure
+// functions, or functions which build their results from constants and their
+// parameters, with them.
+func (c *ConstChecker) builtFromConsts(v ssa.Value, visiting map[ssa.Value]bool) bool {
+	if visiting[v] {
+		return true
+	}
+	visiting[v] = true
+
+	switch v := v.(type) {
+	case *ssa.Const:
+		return true
+	case *ssa.Parameter:
+		// Functions are only looked into from calls whose arguments are
+		// built from constants.
+		return true
+	case *ssa.BinOp:
+		return v.Op == token.ADD && c.builtFromConsts(v.X, visiting) && c.builtFromConsts(v.Y, visiting)
+	case *ssa.MakeInterface:
+		return c.builtFromConsts(v.X, visiting)
+	case *ssa.ChangeType:
+		return c.builtFromConsts(v.X, visiting)
+	case *ssa.Phi:
+		for _, e := range v.Edges {
+			if !c.builtFromConsts(e, visiting) {
+				return false
+			}
+		}
+		return true
+	case *ssa.UnOp:
+		if v.Op != token.MUL {
+			break
+		}
+		switch x := v.X.(type) {
+		case *ssa.Global:
+			return c.isConstGlobal(x, visiting, nil)
+		case *ssa.IndexAddr:
+			// An element of a slice built from constants, e.g. of one
+			// ranged over.
+			return c.builtFromConsts(x.X, visiting)
+		}
+	case *ssa.Slice:
+		// The operands of a variadic function, or a slice literal.
+		return c.constArray(v.X, visiting)
+	case *ssa.Call:
+		callee := v.Common().StaticCallee()
+		if callee == nil {
+			return false
+		}
+		for _, arg := range v.Common().Args {
+			if !c.builtFromConsts(arg, visiting) {
+				return false
+			}
+		}
+		if fn, ok := callee.Object().(*types.Func); ok && pureFuncs[fn.FullName()] {
+			return true
+		}
+		if len(callee.Blocks) == 0 {
+			// An external function, or one we didn't build.
+			return false
+		}
+		for _, b := range callee.Blocks {
+			ret, ok := b.Instrs[len(b.Instrs)-1].(*ssa.Return)
+			if !ok {
+				continue
+			}
+			for _, r := range ret.Results {
+				if !c.builtFromConsts(r, visiting) {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	// isConst takes values it's already looking at to be constant, so v
+	// mustn't be one of them.
+	delete(visiting, v)
+	return c.isConst(v, visiting, nil)
+}
+
+// constArray reports whether the array allocated by a is only ever filled in
+// with values built from constants, as the array behind a slice literal is.
+func (c *ConstChecker) constArray(a ssa.Value, visiting map[ssa.Value]bool) bool {
+	alloc, ok := a.(*ssa.Alloc)
+	if !ok {
+		return false
+	}
+	for _, ref := range *alloc.Referrers() {
+		switch ref := ref.(type) {
+		case *ssa.Slice:
+		case *ssa.IndexAddr:
+			for _, r := range *ref.Referrers() {
+				if store, ok := r.(*ssa.Store); !ok || store.Addr != ref || !c.builtFromConsts(store.Val, visiting) {
+					return false
+				}
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// foldString returns the value of v if it's a concatenation of constants and
+// of variables which are written once with such concatenations.
+func (c *ConstChecker) foldString(v ssa.Value, visiting map[ssa.Value]bool) (string, bool) {
+	if visiting[v] {
+		return "", false
+	}
+	visiting[v] = true
+
+	switch v := v.(type) {
+	case *ssa.Const:
+		return stringConst(v)
+	case *ssa.BinOp:
+		if v.Op != token.ADD {
+			return "", false
+		}
+		x, ok := c.foldString(v.X, visiting)
+		if !ok {
+			return "", false
+		}
+		y, ok := c.foldString(v.Y, visiting)
+		return x + y, ok
+	case *ssa.UnOp:
+		global, ok := v.X.(*ssa.Global)
+		if !ok || v.Op != token.MUL {
+			return "", false
+		}
+		if stored, ok := c.Globals.Stored(global); ok && len(stored) == 1 {
+			return c.foldString(stored[0], visiting)
+		}
+	}
+	return "", false
+}
+
+// isConstMap reports whether the map m only ever holds constant values. We
+// only know how to answer this for maps which live in package-level
+// variables, which are made and filled in with constants (typically by a
+// composite literal in a var block), and which are only ever read from
+// elsewhere.
+func (c *ConstChecker) isConstMap(m ssa.Value, visiting map[ssa.Value]bool, found func(*ssa.Const)) bool {
+	load, ok := m.(*ssa.UnOp)
+	if !ok || load.Op != token.MUL {
+		return false
+	}
+	global, ok := load.X.(*ssa.Global)
+	if !ok {
+		return false
+	}
+	stored, ok := c.Globals.Stored(global)
+	if !ok {
+		return false
+	}
+	maps := make([]ssa.Value, 0, len(stored))
+	for _, v := range stored {
+		if _, ok := v.(*ssa.MakeMap); !ok {
+			return false
+		}
+		maps = append(maps, v)
+	}
+	for _, l := range c.Globals.Loads(global) {
+		maps = append(maps, l)
+	}
+	for _, m := range maps {
+		for _, ref := range *m.Referrers() {
+			switch ref := ref.(type) {
+			case *ssa.MapUpdate:
+				if !c.isConst(ref.Value, visiting, found) {
+					return false
+				}
+			case *ssa.Lookup, *ssa.Range, *ssa.Store:
+				// Reads, and storing the map in its variable.
+				if s, ok := ref.(*ssa.Store); ok && s.Addr != global {
+					return false
+				}
+			default:
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (c *ConstChecker) allConst(values []ssa.Value, visiting map[ssa.Value]bool, found func(*ssa.Const)) bool {
+	for _, v := range values {
+		if !c.isConst(v, visiting, found) {
+			return false
+		}
+	}
+	return true
+}
+
+// A DynamicPart is a non-constant value that a query is built from.
+type DynamicPart struct {
+	Value       ssa.Value
+	Description string
+	// Pos is the position of the expression which uses the value to build
+	// the query.
+	Pos token.Pos
+	// Decl is the position of the declaration of the parameter, variable,
+	// field or function the value comes from, if it is known.
+	Decl token.Pos
+}
+
+// DynamicParts breaks a non-constant query built by concatenation or by
+// fmt.Sprintf and friends down into its parts, and returns the ones that
+// aren't constant. This saves having to dig through long query-building
+// functions to find the one bit of user input that made it in.
+func (c *ConstChecker) DynamicParts(v ssa.Value) []DynamicPart {
+	if v == nil {
+		return nil
+	}
+	return c.dynamicParts(v, v.Pos(), nil, make(map[ssa.Value]bool))
+}
+
+func (c *ConstChecker) dynamicParts(v ssa.Value, pos token.Pos, parts []DynamicPart, seen map[ssa.Value]bool) []DynamicPart {
+	if seen[v] || c.IsConst(v) {
+		return parts
+	}
+	seen[v] = true
+
+	switch v := v.(type) {
+	case *ssa.BinOp:
+		if v.Op == token.ADD {
+			parts = c.dynamicParts(v.X, v.Pos(), parts, seen)
+			return c.dynamicParts(v.Y, v.Pos(), parts, seen)
+		}
+	case *ssa.MakeInterface:
+		return c.dynamicParts(v.X, pos, parts, seen)
+	case *ssa.ChangeType:
+		return c.dynamicParts(v.X, pos, parts, seen)
+	case *ssa.Convert:
+		return c.dynamicParts(v.X, pos, parts, seen)
+	case *ssa.Phi:
+		for _, e := range v.Edges {
+			parts = c.dynamicParts(e, pos, parts, seen)
+		}
+		return parts
+	case *ssa.Call:
+		if args, ok := formatArgs(v.Common()); ok {
+			n := len(parts)
+			for _, arg := range args {
+				parts = c.dynamicParts(arg, v.Pos(), parts, seen)
+			}
+			if len(parts) > n {
+				return parts
+			}
+			// Everything that went into the call is constant, but its
+			// result still isn't a compile-time constant.
+		}
+		pos = v.Pos()
+	}
+	return append(parts, DynamicPart{Value: v, Description: describe(v), Pos: pos, Decl: declPos(v)})
+}
+
+// declPos returns the position of the declaration of what v comes from, or
+// token.NoPos if there's no such thing or it's not known.
+func declPos(v ssa.Value) token.Pos {
+	switch v := v.(type) {
+	case *ssa.Parameter, *ssa.FreeVar:
+		return v.Pos()
+	case *ssa.Call:
+		if callee := v.Common().StaticCallee(); callee != nil {
+			return callee.Pos()
+		}
+	case *ssa.UnOp:
+		switch x := v.X.(type) {
+		case *ssa.Global:
+			return x.Pos()
+		case *ssa.FieldAddr:
+			return fieldPos(x.X.Type(), x.Field)
+		}
+	case *ssa.Field:
+		return fieldPos(v.X.Type(), v.Field)
+	}
+	return token.NoPos
+}
+
+func fieldPos(t types.Type, field int) token.Pos {
+	if s, ok := deref(t).Underlying().(*types.Struct); ok {
+		return s.Field(field).Pos()
+	}
+	return token.NoPos
+}
+
+// QueryShape returns a rendering of how a query is built, e.g.
+// `"SELECT * FROM t WHERE a = " + parameter a`, which doesn't depend on where
+// in its file the query is.
+func (c *ConstChecker) QueryShape(v ssa.Value) string {
+	return c.queryShape(v, make(map[ssa.Value]bool))
+}
+
+func (c *ConstChecker) queryShape(v ssa.Value, seen map[ssa.Value]bool) string {
+	if seen[v] {
+		return "..."
+	}
+	seen[v] = true
+
+	switch v := v.(type) {
+	case *ssa.Const:
+		if v.Value != nil && v.Value.Kind() == constant.String {
+			return strconv.Quote(constant.StringVal(v.Value))
+		}
+		if v.Value == nil {
+			// The zero value of a type which isn't basic, e.g. nil.
+			return "nil"
+		}
+		return v.Value.ExactString()
+	case *ssa.BinOp:
+		if v.Op == token.ADD {
+			return c.queryShape(v.X, seen) + " + " + c.queryShape(v.Y, seen)
+		}
+	case *ssa.MakeInterface:
+		return c.queryShape(v.X, seen)
+	case *ssa.ChangeType:
+		return c.queryShape(v.X, seen)
+	case *ssa.Convert:
+		return c.queryShape(v.X, seen)
+	case *ssa.Call:
+		if args, ok := formatArgs(v.Common()); ok {
+			shapes := make([]string, 0, len(args))
+			for _, arg := range args {
+				shapes = append(shapes, c.queryShape(arg, seen))
+			}
+			return fmt.Sprintf("%s(%s)", v.Common().StaticCallee(), strings.Join(shapes, ", "))
+		}
+	}
+	return describe(v)
+}
+
+// QueryPrefix returns the constant text a query starts with, as far as it's
+// known: the query itself if it's constant, or the start of a concatenation or
+// of a format string, up to its first verb.
+func (c *ConstChecker) QueryPrefix(v ssa.Value) string {
+	switch v := v.(type) {
+	case *ssa.Const:
+		if v.Value != nil && v.Value.Kind() == constant.String {
+			return constant.StringVal(v.Value)
+		}
+	case *ssa.BinOp:
+		if v.Op == token.ADD {
+			return c.QueryPrefix(v.X)
+		}
+	case *ssa.MakeInterface:
+		return c.QueryPrefix(v.X)
+	case *ssa.ChangeType:
+		return c.QueryPrefix(v.X)
+	case *ssa.Convert:
+		return c.QueryPrefix(v.X)
+	case *ssa.Call:
+		if args, ok := formatArgs(v.Common()); ok && len(args) > 0 {
+			prefix := c.QueryPrefix(args[0])
+			if v.Common().StaticCallee().Name() == "Sprintf" {
+				if i := strings.IndexByte(prefix, '%'); i >= 0 {
+					prefix = prefix[:i]
+				}
+			}
+			return prefix
+		}
+	}
+	return ""
+}
+
+// ddlKeywords are the keywords DDL statements start with.
+var ddlKeywords = []string{"CREATE", "ALTER", "DROP", "TRUNCATE", "RENAME"}
+
+// IsDDL reports whether the query is a DDL statement, going by its constant
+// prefix.
+func (c *ConstChecker) IsDDL(v ssa.Value) bool {
+	fields := strings.Fields(c.QueryPrefix(v))
+	if len(fields) == 0 {
+		return false
+	}
+	for _, kw := range ddlKeywords {
+		if strings.EqualFold(fields[0], kw) {
+			return true
+		}
+	}
+	return false
+}
+
+// inListRE matches an IN list whose values are all filled in by a single %s
+// verb of a format string, e.g. "IN (%s)" or "in ('%s')".
+var inListRE = regexp.MustCompile(`(?i)\bIN\s*\(\s*'?%s'?\s*\)`)
+
+// InListJoin returns the call to strings.Join which fills in the IN list of a
+// query built with fmt.Sprintf, as in
+//
+//	fmt.Sprintf("SELECT * FROM users WHERE id IN (%s)", strings.Join(ids, ","))
+//
+// or nil if the query isn't built that way. Such lists are injectable whenever
+// the values are strings, and are better passed as parameters.
+func (c *ConstChecker) InListJoin(v ssa.Value) *ssa.Call {
+	switch v := v.(type) {
+	case *ssa.BinOp:
+		if v.Op == token.ADD {
+			if join := c.InListJoin(v.X); join != nil {
+				return join
+			}
+			return c.InListJoin(v.Y)
+		}
+	case *ssa.MakeInterface:
+		return c.InListJoin(v.X)
+	case *ssa.ChangeType:
+		return c.InListJoin(v.X)
+	case *ssa.Convert:
+		return c.InListJoin(v.X)
+	case *ssa.Call:
+		args, ok := formatArgs(v.Common())
+		if !ok || v.Common().StaticCallee().Name() != "Sprintf" || len(args) == 0 {
+			return nil
+		}
+		format, ok := args[0].(*ssa.Const)
+		if !ok || format.Value == nil || format.Value.Kind() != constant.String {
+			return nil
+		}
+		text := constant.StringVal(format.Value)
+		for _, loc := range inListRE.FindAllStringIndex(text, -1) {
+			// The verb's operand follows the format and those of the
+			// verbs before it.
+			i := 1 + countVerbs(text[:loc[0]])
+			if i >= len(args) {
+				continue
+			}
+			arg := args[i]
+			if mi, ok := arg.(*ssa.MakeInterface); ok {
+				arg = mi.X
+			}
+			join, ok := arg.(*ssa.Call)
+			if !ok {
+				continue
+			}
+			if callee := join.Common().StaticCallee(); callee != nil && callee.Pkg != nil && callee.Pkg.Pkg.Path() == "strings" && callee.Name() == "Join" {
+				return join
+			}
+		}
+	}
+	return nil
+}
+
+// countVerbs returns the number of verbs in a format string, which don't
+// include %%.
+func countVerbs(format string) int {
+	n := 0
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			continue
+		}
+		if i+1 < len(format) && format[i+1] == '%' {
+			i++
+			continue
+		}
+		n++
+	}
+	return n
+}
+
+// formatArgs returns the operands of a call to one of the fmt.Sprint
+// functions, including the individual values passed to its variadic
+// parameter.
+func formatArgs(cc *ssa.CallCommon) ([]ssa.Value, bool) {
+	callee := cc.StaticCallee()
+	if callee == nil || callee.Pkg == nil || callee.Pkg.Pkg.Path() != "fmt" {
+		return nil, false
+	}
+	switch callee.Name() {
+	case "Sprintf", "Sprint", "Sprintln":
+	default:
+		return nil, false
+	}
+
+	args := make([]ssa.Value, 0, len(cc.Args))
+	args = append(args, cc.Args[:len(cc.Args)-1]...)
+	variadic := cc.Args[len(cc.Args)-1]
+	// The variadic arguments are stored into an array which is then sliced.
+	slice, ok := variadic.(*ssa.Slice)
+	if !ok {
+		return append(args, variadic), true
+	}
+	alloc, ok := slice.X.(*ssa.Alloc)
+	if !ok {
+		return append(args, variadic), true
+	}
+	for _, ref := range *alloc.Referrers() {
+		addr, ok := ref.(*ssa.IndexAddr)
+		if !ok {
+			continue
+		}
+		for _, ref := range *addr.Referrers() {
+			if store, ok := ref.(*ssa.Store); ok && store.Addr == addr {
+				args = append(args, store.Val)
+			}
+		}
+	}
+	return args, true
+}
+
+// describe returns a short description of a value for use in messages.
+func describe(v ssa.Value) string {
+	switch v := v.(type) {
+	case *ssa.Parameter:
+		return fmt.Sprintf("parameter %s", v.Name())
+	case *ssa.FreeVar:
+		return fmt.Sprintf("captured variable %s", v.Name())
+	case *ssa.Call:
+		if callee := v.Common().StaticCallee(); callee != nil {
+			return fmt.Sprintf("result of %s", callee)
+		}
+		if v.Common().IsInvoke() {
+			return fmt.Sprintf("result of %s method", v.Common().Method.Name())
+		}
+		return "result of function call"
+	case *ssa.UnOp:
+		switch x := v.X.(type) {
+		case *ssa.Global:
+			return fmt.Sprintf("package variable %s", x.Name())
+		case *ssa.FieldAddr:
+			return fmt.Sprintf("field %s", fieldName(x.X.Type(), x.Field))
+		}
+		if v.Op == token.ARROW {
+			return "value received from channel"
+		}
+	case *ssa.Field:
+		return fmt.Sprintf("field %s", fieldName(v.X.Type(), v.Field))
+	case *ssa.Lookup:
+		return "map or string element"
+	case *ssa.TypeAssert:
+		return fmt.Sprintf("%s asserted from %s", v.AssertedType, v.X.Type())
+	case *ssa.Extract:
+		if t, ok := v.Tuple.(*ssa.TypeAssert); ok && v.Index == 0 {
+			return describe(t)
+		}
+	}
+	return fmt.Sprintf("non-constant %s", v.Type())
+}
+
+// FromTypeAssertion reports whether the non-constant parts of a query all come
+// from type assertions, e.g. q := job.Payload.(string), which say nothing
+// about where the value in the interface came from, so that whether the query
+// is constant can't be verified.
+func (c *ConstChecker) FromTypeAssertion(query ssa.Value) bool {
+	parts := c.DynamicParts(query)
+	for _, part := range parts {
+		if !isTypeAssertion(part.Value) {
+			return false
+		}
+	}
+	return len(parts) > 0
+}
+
+// isTypeAssertion reports whether v is the result of a type assertion, with
+// or without a comma-ok.
+func isTypeAssertion(v ssa.Value) bool {
+	switch v := v.(type) {
+	case *ssa.TypeAssert:
+		return true
+	case *ssa.Extract:
+		_, ok := v.Tuple.(*ssa.TypeAssert)
+		return ok && v.Index == 0
+	}
+	return false
+}
+
+func fieldName(t types.Type, field int) string {
+	if s, ok := deref(t).Underlying().(*types.Struct); ok {
+		return s.Field(field).Name()
+	}
+	return fmt.Sprintf("#%d", field)
+}
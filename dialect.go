@@ -0,0 +1,222 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/tools/go/ssa"
+)
+
+// A Dialect is the flavor of SQL a database speaks, which determines how
+// queries are validated and which placeholders fixes use.
+type Dialect struct {
+	Name string
+	// Placeholder is the style of the placeholders the database understands:
+	// "?", or "$", "@p" or ":" for numbered placeholders such as $1.
+	Placeholder string
+	// QuotedIdentifiers is true if double quotes quote identifiers, as in
+	// standard SQL, rather than strings.
+	QuotedIdentifiers bool
+	// Extensions are keywords and operators of the dialect which the SQL
+	// parser doesn't understand. Queries using them aren't validated.
+	Extensions []string
+	// Drivers are the names database/sql drivers for the database are
+	// registered as.
+	Drivers []string
+	// Packages are the import paths of database packages which talk to the
+	// database themselves, rather than through database/sql.
+	Packages []string
+}
+
+// Dialects lists the dialects safesql knows. The first is the default.
+var Dialects = []Dialect{
+	{
+		Name:        "mysql",
+		Placeholder: "?",
+		Extensions:  []string{"WITH"},
+		Drivers:     []string{"mysql", "nrmysql", "cloudsqlmysql"},
+	},
+	{
+		Name:              "postgres",
+		Placeholder:       "$",
+		QuotedIdentifiers: true,
+		Extensions:        []string{"::", "RETURNING", "ILIKE", "ON CONFLICT", "DISTINCT ON", "WITH"},
+		Drivers:           []string{"postgres", "pgx", "cloudsqlpostgres", "nrpostgres", "cockroach"},
+		Packages:          []string{"github.com/jackc/pgx/v4", "github.com/jackc/pgx/v5"},
+	},
+	{
+		Name:              "sqlite",
+		Placeholder:       "?",
+		QuotedIdentifiers: true,
+		Extensions:        []string{"RETURNING", "ON CONFLICT", "GLOB", "PRAGMA", "WITH"},
+		Drivers:           []string{"sqlite3", "sqlite"},
+		Packages:          []string{"crawshaw.io/sqlite", "zombiezen.com/go/sqlite"},
+	},
+	{
+		Name:              "clickhouse",
+		Placeholder:       "?",
+		QuotedIdentifiers: true,
+		Extensions:        []string{"PREWHERE", "FINAL", "ARRAY JOIN", "SAMPLE", "FORMAT", "SETTINGS", "WITH"},
+		Drivers:           []string{"clickhouse"},
+	},
+	{
+		Name:              "sqlserver",
+		Placeholder:       "@p",
+		QuotedIdentifiers: true,
+		Extensions:        []string{"TOP", "OUTPUT", "MERGE", "WITH"},
+		Drivers:           []string{"sqlserver", "mssql"},
+	},
+	{
+		Name:              "oracle",
+		Placeholder:       ":",
+		QuotedIdentifiers: true,
+		Extensions:        []string{"ROWNUM", "FETCH FIRST", "CONNECT BY", "MERGE", "WITH"},
+		Drivers:           []string{"godror", "oracle", "oci8"},
+		Packages:          []string{"github.com/sijms/go-ora/v2", "github.com/godror/godror"},
+	},
+}
+
+// ParseDialect returns the dialect with the given name.
+func ParseDialect(name string) (Dialect, error) {
+	names := make([]string, 0, len(Dialects))
+	for _, d := range Dialects {
+		if d.Name == name {
+			return d, nil
+		}
+		names = append(names, d.Name)
+	}
+	return Dialect{}, fmt.Errorf("unknown dialect %q, expected one of %s", name, strings.Join(names, ", "))
+}
+
+// DriverDialect returns the dialect of the given database driver, or false if
+// it isn't one safesql knows.
+func DriverDialect(driver string) (Dialect, bool) {
+	for _, d := range Dialects {
+		if contains(d.Drivers, driver) {
+			return d, true
+		}
+	}
+	return Dialect{}, false
+}
+
+// PackageDialect returns the dialect of the given database package, or false
+// if it isn't one which talks to the database itself.
+func PackageDialect(path string) (Dialect, bool) {
+	for _, d := range Dialects {
+		if contains(d.Packages, path) {
+			return d, true
+		}
+	}
+	return Dialect{}, false
+}
+
+// ProgramDialect returns the dialect of the drivers the program opens
+// connections with, and of the database packages it uses which talk to the
+// database themselves, or false if they don't agree. Drivers safesql doesn't
+// know are disregarded, and programs which don't name any others get the
+// default.
+func ProgramDialect(s *ssa.Program) (Dialect, bool) {
+	var dialect *Dialect
+	agrees := func(d Dialect) bool {
+		if dialect == nil {
+			dialect = &d
+		}
+		return d.Name == dialect.Name
+	}
+	for _, site := range openCalls(s) {
+		driver, ok := stringConst(site.Common().Args[0])
+		if !ok {
+			continue
+		}
+		if d, ok := DriverDialect(driver); ok && !agrees(d) {
+			return Dialect{}, false
+		}
+	}
+	for _, pkg := range s.AllPackages() {
+		if d, ok := PackageDialect(pkg.Pkg.Path()); ok && !agrees(d) {
+			return Dialect{}, false
+		}
+	}
+	if dialect == nil {
+		return Dialects[0], true
+	}
+	return *dialect, true
+}
+
+// Validate returns an error if the given query doesn't parse. Since the parser
+// understands MySQL's grammar, the query's placeholders and quoted identifiers
+// are first rewritten into MySQL's, and queries using the dialect's other
+// extensions aren't checked at all.
+func (d Dialect) Validate(query string) error {
+	upper := strings.ToUpper(query)
+	for _, ext := range d.Extensions {
+		if containsWord(upper, ext) {
+			return nil
+		}
+	}
+	return ValidateSQL(d.rewrite(query))
+}
+
+// rewrite returns the query with its placeholders replaced by ? (or, for
+// placeholders like :1, by :v1) and its identifiers quoted with backquotes
+// rather than double quotes, leaving string literals alone.
+func (d Dialect) rewrite(query string) string {
+	var b strings.Builder
+	var quote byte
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+			if c == '"' && d.QuotedIdentifiers {
+				c = '`'
+			}
+		case c == '\'' || c == '`':
+			quote = c
+		case c == '"':
+			quote = c
+			if d.QuotedIdentifiers {
+				c = '`'
+			}
+		case d.Placeholder != "?" && strings.HasPrefix(query[i:], d.Placeholder):
+			j := i + len(d.Placeholder)
+			for j < len(query) && query[j] >= '0' && query[j] <= '9' {
+				j++
+			}
+			if j == i+len(d.Placeholder) {
+				break
+			}
+			if d.Placeholder == ":" {
+				b.WriteString(":v" + query[i+1:j])
+			} else {
+				b.WriteByte('?')
+			}
+			i = j - 1
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// containsWord reports whether s contains word, not as part of a longer word.
+// Words which don't start and end with letters, like "::", match anywhere.
+func containsWord(s, word string) bool {
+	isWordByte := func(c byte) bool {
+		return c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '_'
+	}
+	for i := 0; ; {
+		j := strings.Index(s[i:], word)
+		if j < 0 {
+			return false
+		}
+		start, end := i+j, i+j+len(word)
+		if (start == 0 || !isWordByte(s[start-1]) || !isWordByte(word[0])) &&
+			(end == len(s) || !isWordByte(s[end]) || !isWordByte(word[len(word)-1])) {
+			return true
+		}
+		i = start + 1
+	}
+}
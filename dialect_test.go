@@ -0,0 +1,73 @@
+package main
+
+import "testing"
+
+func TestDriverDialect(t *testing.T) {
+	tests := map[string]string{
+		"postgres":   "postgres",
+		"pgx":        "postgres",
+		"mysql":      "mysql",
+		"sqlite3":    "sqlite",
+		"clickhouse": "clickhouse",
+		"mssql":      "sqlserver",
+		"unknown":    "",
+	}
+	for driver, expected := range tests {
+		d, ok := DriverDialect(driver)
+		if ok != (expected != "") || d.Name != expected {
+			t.Errorf("%s: expected %q, got %q (%v)", driver, expected, d.Name, ok)
+		}
+	}
+	if _, err := ParseDialect("cobol"); err == nil {
+		t.Errorf("expected an error for an unknown dialect")
+	}
+}
+
+func TestPackageDialect(t *testing.T) {
+	tests := map[string]string{
+		"github.com/jackc/pgx/v5":    "postgres",
+		"github.com/jackc/pgx/v4":    "postgres",
+		"github.com/sijms/go-ora/v2": "oracle",
+		"zombiezen.com/go/sqlite":    "sqlite",
+		"database/sql":               "",
+	}
+	for path, expected := range tests {
+		d, ok := PackageDialect(path)
+		if ok != (expected != "") || d.Name != expected {
+			t.Errorf("%s: expected %q, got %q (%v)", path, expected, d.Name, ok)
+		}
+	}
+}
+
+func TestDialectValidate(t *testing.T) {
+	tests := []struct {
+		dialect string
+		query   string
+		valid   bool
+	}{
+		{"mysql", "SELECT * FROM users WHERE id = ?", true},
+		{"mysql", "SELECT * FROM users WHERE id = $1", false},
+		{"postgres", "SELECT * FROM users WHERE id = $1 AND name = $2", true},
+		{"postgres", `SELECT "name" FROM "users" WHERE id = $1`, true},
+		{"postgres", "SELECT * FROM users WHERE name = '$1'", true},
+		{"postgres", "SELECT * FROM users WHERE id = $1 AND", false},
+		{"postgres", "INSERT INTO users (name) VALUES ($1) RETURNING id", true},
+		{"postgres", "SELECT id::text FROM users", true},
+		{"sqlserver", "SELECT * FROM users WHERE id = @p1", true},
+		{"oracle", "SELECT * FROM users WHERE id = :1", true},
+		{"sqlite", `SELECT "name" FROM users WHERE id = ? ORDER`, false},
+		{"clickhouse", "SELECT * FROM events FINAL WHERE id = ?", true},
+	}
+	for _, test := range tests {
+		d, err := ParseDialect(test.dialect)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = d.Validate(test.query)
+		if test.valid && err != nil {
+			t.Errorf("%s: %q: unexpected error %v", test.dialect, test.query, err)
+		} else if !test.valid && err == nil {
+			t.Errorf("%s: %q: expected an error", test.dialect, test.query)
+		}
+	}
+}
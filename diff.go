@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"go/token"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ChangedLines records the lines which were added or modified in each file,
+// keyed by absolute file name. A nil set of lines means the whole file is new.
+type ChangedLines map[string]map[int]bool
+
+// GitChangedLines returns the lines changed in the working tree of the git
+// repository containing the current directory relative to the given ref.
+// Untracked files count as changed in their entirety.
+func GitChangedLines(ref string) (ChangedLines, error) {
+	root, err := git("rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, err
+	}
+	root = strings.TrimSpace(root)
+
+	diff, err := git("-C", root, "diff", "--no-color", "--no-ext-diff", "--unified=0", ref, "--")
+	if err != nil {
+		return nil, err
+	}
+	changed, err := ParseUnifiedDiff(root, strings.NewReader(diff))
+	if err != nil {
+		return nil, err
+	}
+
+	untracked, err := git("-C", root, "ls-files", "--others", "--exclude-standard")
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range strings.Split(strings.TrimSpace(untracked), "\n") {
+		if name != "" {
+			changed[filepath.Join(root, filepath.FromSlash(name))] = nil
+		}
+	}
+	return changed, nil
+}
+
+// GitChangedFiles returns the absolute names of the files changed in the
+// working tree of the git repository containing the current directory relative
+// to the given ref, including deleted and untracked files.
+func GitChangedFiles(ref string) (map[string]bool, error) {
+	root, err := git("rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, err
+	}
+	root = strings.TrimSpace(root)
+
+	diff, err := git("-C", root, "diff", "--name-only", "--no-renames", ref, "--")
+	if err != nil {
+		return nil, err
+	}
+	untracked, err := git("-C", root, "ls-files", "--others", "--exclude-standard")
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]bool)
+	for _, name := range strings.Split(diff+"\n"+untracked, "\n") {
+		if name = strings.TrimSpace(name); name != "" {
+			files[filepath.Join(root, filepath.FromSlash(name))] = true
+		}
+	}
+	return files, nil
+}
+
+func git(args ...string) (string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("git", args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %v: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return string(out), nil
+}
+
+// ParseUnifiedDiff parses the output of git diff into the set of changed lines
+// of each file, relative to the given root.
+func ParseUnifiedDiff(root string, r io.Reader) (ChangedLines, error) {
+	changed := make(ChangedLines)
+	var lines map[int]bool
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "+++ "):
+			name := strings.TrimPrefix(line, "+++ ")
+			if name == "/dev/null" {
+				// The file was deleted.
+				lines = nil
+				continue
+			}
+			name = strings.TrimPrefix(name, "b/")
+			lines = make(map[int]bool)
+			changed[filepath.Join(root, filepath.FromSlash(name))] = lines
+		case strings.HasPrefix(line, "@@ "):
+			if lines == nil {
+				continue
+			}
+			// @@ -start,count +start,count @@
+			fields := strings.Fields(line)
+			if len(fields) < 3 || !strings.HasPrefix(fields[2], "+") {
+				return nil, fmt.Errorf("malformed hunk header %q", line)
+			}
+			start, count, err := parseRange(strings.TrimPrefix(fields[2], "+"))
+			if err != nil {
+				return nil, fmt.Errorf("malformed hunk header %q: %v", line, err)
+			}
+			for i := start; i < start+count; i++ {
+				lines[i] = true
+			}
+		}
+	}
+	return changed, scanner.Err()
+}
+
+func parseRange(s string) (start, count int, err error) {
+	count = 1
+	if i := strings.IndexByte(s, ','); i >= 0 {
+		if count, err = strconv.Atoi(s[i+1:]); err != nil {
+			return 0, 0, err
+		}
+		s = s[:i]
+	}
+	start, err = strconv.Atoi(s)
+	return start, count, err
+}
+
+// Contains reports whether the given position is on a changed line.
+func (c ChangedLines) Contains(pos token.Position) bool {
+	lines, ok := c[filepath.Clean(pos.Filename)]
+	if !ok {
+		return false
+	}
+	return lines == nil || lines[pos.Line]
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"go/token"
+	"strings"
+	"testing"
+)
+
+const testDiff = `diff --git a/db.go b/db.go
+index 3b18e51..a9c4d2b 100644
+--- a/db.go
++++ b/db.go
+@@ -3 +3 @@ import "database/sql"
+-func a() {}
++func a() { db.Query(q) }
+@@ -10,0 +11,2 @@ func b() {
++	q := "SELECT " + name
++	db.Query(q)
+@@ -20,3 +22,0 @@ func c() {
+diff --git a/old.go b/old.go
+deleted file mode 100644
+--- a/old.go
++++ /dev/null
+@@ -1,2 +0,0 @@
+-package main
+-
+`
+
+func TestParseUnifiedDiff(t *testing.T) {
+	changed, err := ParseUnifiedDiff("/src/app", strings.NewReader(testDiff))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[int]bool{2: false, 3: true, 4: false, 10: false, 11: true, 12: true, 13: false, 22: false}
+	for line, expected := range tests {
+		pos := token.Position{Filename: "/src/app/db.go", Line: line}
+		if actual := changed.Contains(pos); actual != expected {
+			t.Errorf("line %d: Contains = %v, expected %v", line, actual, expected)
+		}
+	}
+	if changed.Contains(token.Position{Filename: "/src/app/old.go", Line: 1}) {
+		t.Error("expected deleted file not to contain changes")
+	}
+	if changed.Contains(token.Position{Filename: "/src/app/other.go", Line: 3}) {
+		t.Error("expected unchanged file not to contain changes")
+	}
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/parser"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/tools/go/loader"
+	"golang.org/x/tools/go/ssa"
+	"golang.org/x/tools/go/ssa/ssautil"
+)
+
+// doctorExample is a program with exactly one unsafe query, which the analysis
+// must find for the doctor's self-test to pass.
+const doctorExample = `package main
+
+import (
+	"database/sql"
+	"os"
+)
+
+func main() {
+	db, _ := sql.Open("mysql", "")
+	db.Query("SELECT 1")
+	db.Query("SELECT * FROM users WHERE name = '" + os.Args[1] + "'")
+}
+`
+
+// A Diagnosis is the result of one of the doctor's checks.
+type Diagnosis struct {
+	Check string
+	OK    bool
+	// Detail says what was found, and Fix, for failed checks, what to do
+	// about it.
+	Detail string
+	Fix    string
+}
+
+// An analysis is what the doctor learns about a program by analyzing it the
+// way safesql does, up to finding the calls to query methods.
+type analysis struct {
+	sinks []string
+	qms   []*QueryMethod
+	mains []*ssa.Package
+	edges int
+	bad   []NonConstCall
+	// fields are the stores of non-constant SQL to the sqlFields.
+	fields []*ssa.Store
+}
+
+// analyzeProgram analyzes p the way safesql does. Since the packages it relies
+// on have been known to panic on programs they don't support, panics are
+// returned as errors.
+func analyzeProgram(p *loader.Program) (a analysis, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	imports := getImports(p)
+	for _, pkg := range sqlPackages {
+		if _, ok := imports[pkg.packageName]; ok {
+			a.sinks = append(a.sinks, pkg.packageName)
+		}
+	}
+	// Built serially, so that panics are in this goroutine.
+	s := ssautil.CreateProgram(p, ssa.BuildSerially)
+	s.Build()
+	for _, pkg := range sqlPackages {
+		if _, ok := imports[pkg.packageName]; ok {
+			a.qms = append(a.qms, FindQueryMethods(pkg, p.Package(pkg.packageName).Pkg, s)...)
+		}
+	}
+	a.qms = append(a.qms, FindWrapperMethods(p, s, a.qms)...)
+	a.mains = FindMains(p, s)
+	if len(a.qms) == 0 || len(a.mains) == 0 {
+		return a, nil
+	}
+
+	cg, chans, err := BuildCallGraph(s, a.mains, PrecisionMax)
+	if err != nil {
+		return a, err
+	}
+	for _, m := range a.qms {
+		a.edges += len(cg.CreateNode(m.SSA).In)
+	}
+	cc := &ConstChecker{Chans: chans, Globals: NewGlobals(s), TrustMigrations: true}
+	a.bad, _ = FindNonConstCalls(cg, a.qms, cc)
+	a.fields = FindNonConstFields(s, cc)
+	return a, nil
+}
+
+// selfTest checks that the analysis finds the unsafe query in doctorExample.
+func selfTest() Diagnosis {
+	d := Diagnosis{Check: "self-test"}
+	c := loader.Config{ParserMode: parser.ParseComments}
+	f, err := c.ParseFile("example.go", doctorExample)
+	if err != nil {
+		d.Detail = err.Error()
+		return d
+	}
+	c.CreateFromFiles("main", f)
+	p, err := c.Load()
+	if err != nil {
+		d.Detail = fmt.Sprintf("couldn't load the example program: %v", err)
+		d.Fix = "check that the Go installation safesql was built with is the one in your PATH"
+		return d
+	}
+	a, err := analyzeProgram(p)
+	switch {
+	case err != nil:
+		d.Detail = fmt.Sprintf("analyzing the example program failed: %v", err)
+	case len(a.bad) != 1:
+		d.Detail = fmt.Sprintf("expected to find 1 unsafe query in the example program, found %d", len(a.bad))
+	default:
+		d.OK, d.Detail = true, "found the unsafe query in the example program"
+		return d
+	}
+	d.Fix = "safesql doesn't work with this version of Go; rebuild it with a newer golang.org/x/tools, or run it with an older Go"
+	return d
+}
+
+// Doctor checks that safesql can actually analyze the given packages, rather
+// than passing because it doesn't see anything: that its analysis works at
+// all, and that the packages use database packages it knows, have main
+// packages, and call query methods which end up in the call graph.
+func Doctor(c loader.Config, pkgs []string, warn io.Writer) []Diagnosis {
+	diagnoses := []Diagnosis{selfTest()}
+
+	load := Diagnosis{Check: "packages"}
+	p, failures, err := LoadPackages(c, pkgs, false, warn)
+	if err != nil {
+		load.Detail = err.Error()
+		load.Fix = "check that the packages build with go build"
+		return append(diagnoses, load)
+	}
+	load.OK, load.Detail = true, fmt.Sprintf("loaded %d packages", len(p.AllPackages))
+	if len(failures) > 0 {
+		load.Detail += fmt.Sprintf(", skipping %d which have errors", len(failures))
+	}
+	diagnoses = append(diagnoses, load)
+
+	a, err := analyzeProgram(p)
+
+	sinks := Diagnosis{Check: "database packages"}
+	if len(a.sinks) == 0 {
+		sinks.Detail = "none of the packages use a database package safesql knows"
+		sinks.Fix = "add the packages you use to the configuration file; -suggest-sinks lists candidates"
+	} else if err != nil {
+		sinks.Detail = fmt.Sprintf("analyzing the packages failed: %v", err)
+		sinks.Fix = "report this, with the output of -debug"
+	} else {
+		sinks.OK = len(a.qms) > 0
+		sinks.Detail = fmt.Sprintf("%s, with %d query methods", strings.Join(a.sinks, ", "), len(a.qms))
+		if !sinks.OK {
+			sinks.Fix = "check the params of the packages in the configuration file"
+		}
+	}
+	diagnoses = append(diagnoses, sinks)
+	if !sinks.OK {
+		return diagnoses
+	}
+
+	mains := Diagnosis{Check: "main packages", OK: len(a.mains) > 0, Detail: fmt.Sprintf("found %d", len(a.mains))}
+	if !mains.OK {
+		mains.Fix = "pass the commands (packages main) which use the packages you want to check, since only code reachable from them is analyzed"
+	}
+	diagnoses = append(diagnoses, mains)
+	if !mains.OK {
+		return diagnoses
+	}
+
+	calls := Diagnosis{Check: "call graph"}
+	if a.edges == 0 {
+		calls.Detail = "no calls to query methods are reachable from the main packages"
+		calls.Fix = "if the program does run queries, the analysis is blind to them: report this, with the output of -debug"
+	} else {
+		calls.OK, calls.Detail = true, fmt.Sprintf("%d calls to query methods", a.edges)
+	}
+	return append(diagnoses, calls)
+}
+
+// WriteDiagnoses writes the diagnoses, and reports whether they all passed.
+func WriteDiagnoses(w io.Writer, diagnoses []Diagnosis) bool {
+	ok := true
+	for _, d := range diagnoses {
+		status := "ok  "
+		if !d.OK {
+			status, ok = "FAIL", false
+		}
+		fmt.Fprintf(w, "%s %s: %s\n", status, d.Check, d.Detail)
+		if d.Fix != "" {
+			fmt.Fprintf(w, "     %s\n", d.Fix)
+		}
+	}
+	return ok
+}
+
+// doctorMain runs `safesql doctor` with the given arguments, and returns the
+// status to exit with.
+func doctorMain(args []string) int {
+	fs := flag.NewFlagSet("doctor", flag.ExitOnError)
+	var configFile, tags, goos, goarch string
+	fs.StringVar(&configFile, "config", DefaultConfigFile, "Configuration file")
+	fs.StringVar(&tags, "tags", goflagsTags(), "Comma-separated build tags to load the packages with")
+	fs.StringVar(&goos, "goos", "", "Operating system to load the packages for, if not GOOS")
+	fs.StringVar(&goarch, "goarch", "", "Architecture to load the packages for, if not GOARCH")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s doctor [flags] package1 [package2 ...]\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	c := loader.Config{
+		Build:       BuildContext(tags, goos, goarch),
+		FindPackage: FindPackage,
+		ParserMode:  parser.ParseComments,
+	}
+	config, err := LoadConfig(configFile)
+	if err == nil {
+		err = config.LoadBundles(c.Build)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error loading configuration: %v\n", err)
+		return 2
+	}
+	for _, pkg := range config.Packages {
+		sqlPackages = append(sqlPackages, sqlPackage{packageName: pkg.Package, paramNames: pkg.Params})
+	}
+
+	wd, _ := os.Getwd()
+	pkgs := ExpandPatterns(c.Build, wd, fs.Args(), nil)
+	if !WriteDiagnoses(os.Stdout, Doctor(c, pkgs, os.Stderr)) {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteDiagnoses(t *testing.T) {
+	var buf bytes.Buffer
+	ok := WriteDiagnoses(&buf, []Diagnosis{
+		{Check: "self-test", OK: true, Detail: "found the unsafe query"},
+		{Check: "main packages", Detail: "found 0", Fix: "pass the commands"},
+	})
+	if ok {
+		t.Errorf("expected a failed check to fail")
+	}
+	expected := "ok   self-test: found the unsafe query\nFAIL main packages: found 0\n     pass the commands\n"
+	if buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+}
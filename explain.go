@@ -0,0 +1,117 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// explainWidth is the width explanations are wrapped to, so that they fit in
+// an 80 column terminal.
+const explainWidth = 79
+
+// explainMain runs safesql explain, which describes rules in the terminal, or
+// lists them all if none are given.
+func explainMain(args []string) int {
+	fs := flag.NewFlagSet("explain", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s explain [rule ...]\n", os.Args[0])
+		fmt.Fprintln(os.Stderr, "Rules are given by identifier, e.g. SAFESQL001, or name, e.g. NonConstantQuery.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		WriteRuleList(os.Stdout)
+		return 0
+	}
+
+	rules := make([]Rule, 0, fs.NArg())
+	for _, arg := range fs.Args() {
+		rule, ok := FindRule(arg)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "unknown rule %s; safesql explain lists the rules\n", arg)
+			return 2
+		}
+		rules = append(rules, rule)
+	}
+	for i, rule := range rules {
+		if i > 0 {
+			fmt.Fprintln(os.Stdout)
+		}
+		WriteExplanation(os.Stdout, rule)
+	}
+	return 0
+}
+
+// FindRule returns the rule with the given identifier or name, in any case.
+func FindRule(idOrName string) (Rule, bool) {
+	for _, rule := range Rules {
+		if strings.EqualFold(rule.ID, idOrName) || strings.EqualFold(rule.Name, idOrName) {
+			return rule, true
+		}
+	}
+	return Rule{}, false
+}
+
+// WriteRuleList writes the identifier, name and description of each rule to
+// w, one per line.
+func WriteRuleList(w io.Writer) {
+	for _, rule := range Rules {
+		fmt.Fprintf(w, "%s %-26s %s\n", rule.ID, rule.Name, rule.Description)
+	}
+}
+
+// WriteExplanation writes what the rule checks, examples of code which breaks
+// it and of the same code fixed, and how to fix its findings, to w.
+func WriteExplanation(w io.Writer, rule Rule) {
+	writeWrapped(w, "", fmt.Sprintf("%s %s: %s", rule.ID, rule.Name, rule.Description))
+	var notes []string
+	if refs := rule.References(); refs != "" {
+		notes = append(notes, refs+".")
+	}
+	if rule.Informational {
+		notes = append(notes, "Informational: its findings never fail the run.")
+	}
+	if rule.OptIn {
+		notes = append(notes, fmt.Sprintf("Opt-in: enable it with -enable %s or in the configuration file.", rule.ID))
+	}
+	if len(notes) > 0 {
+		writeWrapped(w, "", strings.Join(notes, " "))
+	}
+	fmt.Fprintln(w)
+	writeWrapped(w, "", rule.Help)
+
+	if rule.Unsafe != "" {
+		fmt.Fprintf(w, "\nUnsafe:\n\n    %s\n", rule.Unsafe)
+	}
+	if rule.Safe != "" {
+		fmt.Fprintf(w, "\nSafe:\n\n    %s\n", rule.Safe)
+	}
+	if len(rule.Remediation) > 0 {
+		fmt.Fprintf(w, "\nHow to fix it:\n\n")
+		for _, r := range rule.Remediation {
+			writeWrapped(w, "  - ", r)
+		}
+	}
+	fmt.Fprintf(w, "\nSee %s\n", rule.HelpURI())
+}
+
+// writeWrapped writes text to w, wrapped at word boundaries to explainWidth,
+// with its first line starting with prefix and the others indented to match.
+func writeWrapped(w io.Writer, prefix, text string) {
+	indent := strings.Repeat(" ", len(prefix))
+	line := prefix
+	for _, word := range strings.Fields(text) {
+		if len(line) > len(indent) && len(line)+1+len(word) > explainWidth {
+			fmt.Fprintln(w, line)
+			line = indent
+		}
+		if len(line) > len(indent) {
+			line += " "
+		}
+		line += word
+	}
+	fmt.Fprintln(w, line)
+}
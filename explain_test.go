@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestFindRule(t *testing.T) {
+	for _, name := range []string{"SAFESQL005", "safesql005", "DynamicDDL", "dynamicddl"} {
+		if rule, ok := FindRule(name); !ok || rule.ID != RuleDynamicDDL {
+			t.Errorf("%s: expected %s, got %v, %v", name, RuleDynamicDDL, rule.ID, ok)
+		}
+	}
+	if _, ok := FindRule("SAFESQL999"); ok {
+		t.Errorf("expected an unknown rule not to be found")
+	}
+}
+
+// TestRulesExplained checks that every rule has what safesql explain shows.
+func TestRulesExplained(t *testing.T) {
+	for _, rule := range Rules {
+		if rule.Unsafe == "" || rule.Safe == "" || len(rule.Remediation) == 0 {
+			t.Errorf("%s: expected examples and remediation, got %q, %q, %q", rule.ID, rule.Unsafe, rule.Safe, rule.Remediation)
+		}
+	}
+}
+
+func TestWriteExplanation(t *testing.T) {
+	rule, _ := FindRule(RuleSQLFormat)
+	var buf bytes.Buffer
+	WriteExplanation(&buf, rule)
+	out := buf.String()
+	for _, expected := range []string{
+		"SAFESQL007 SQLFormatString: Format string looks like SQL",
+		"CWE-89, A03:2021 - Injection. Informational: its findings never fail the run.",
+		"Opt-in: enable it with -enable SAFESQL007",
+		"Unsafe:\n\n    " + rule.Unsafe + "\n",
+		"Safe:\n\n    " + rule.Safe + "\n",
+		"How to fix it:\n\n  - Add the package",
+		"See https://github.com/stripe/safesql#safesql007\n",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("expected %q in the explanation, got:\n%s", expected, out)
+		}
+	}
+	for _, line := range strings.Split(out, "\n") {
+		if len(line) > explainWidth && !strings.HasPrefix(line, "    ") {
+			t.Errorf("expected lines other than examples to be wrapped, got %q", line)
+		}
+	}
+}
+
+func TestWriteWrapped(t *testing.T) {
+	var buf bytes.Buffer
+	writeWrapped(&buf, "  - ", strings.Repeat("word ", 20))
+	expected := "  - word word word word word word word word word word word word word word word\n    word word word word word\n"
+	if buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"go/build"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/go/buildutil"
+)
+
+// FilePackage returns the absolute name of the given file and the package it
+// is in, as a path relative to wd which the loader accepts.
+func FilePackage(file, wd string) (abs, pkg string, err error) {
+	if abs, err = filepath.Abs(file); err != nil {
+		return "", "", err
+	}
+	rel, err := filepath.Rel(wd, filepath.Dir(abs))
+	if err != nil {
+		return "", "", err
+	}
+	pkg = filepath.ToSlash(rel)
+	if pkg != "." && !strings.HasPrefix(pkg, "../") {
+		pkg = "./" + pkg
+	}
+	return abs, pkg, nil
+}
+
+// OverlayFile returns a build context in which the contents of the given file
+// are read from r, e.g. an editor's unsaved buffer, rather than from disk.
+func OverlayFile(ctxt *build.Context, abs string, r io.Reader) (*build.Context, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return buildutil.OverlayContext(ctxt, map[string][]byte{abs: data}), nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"go/build"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/buildutil"
+)
+
+func TestFilePackage(t *testing.T) {
+	wd := filepath.FromSlash("/src/app")
+	tests := map[string]string{
+		"main.go":       ".",
+		"db/db.go":      "./db",
+		"../lib/lib.go": "../lib",
+	}
+	for file, expected := range tests {
+		abs, pkg, err := FilePackage(filepath.Join(wd, filepath.FromSlash(file)), wd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pkg != expected {
+			t.Errorf("%s: expected package %s, got %s", file, expected, pkg)
+		}
+		if !filepath.IsAbs(abs) {
+			t.Errorf("%s: expected an absolute name, got %s", file, abs)
+		}
+	}
+}
+
+func TestOverlayFile(t *testing.T) {
+	abs, err := filepath.Abs("testdata/multiple_files/main.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctxt, err := OverlayFile(&build.Default, abs, strings.NewReader("package main\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := buildutil.OpenFile(ctxt, abs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "package main\n" {
+		t.Errorf("expected the contents from the reader, got %q", data)
+	}
+}
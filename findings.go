@@ -0,0 +1,206 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/loader"
+	"golang.org/x/tools/go/ssa"
+)
+
+// queryResult returns the finding of rule, as returned by queryRule, about the
+// non-constant query passed in ci, and its fingerprint. call is the call
+// expression of ci, if it was found, and query the index of its query
+// argument. The finding is suppressed until its caller decides otherwise.
+func queryResult(p *loader.Program, cc *ConstChecker, commands *CommandIndex, ci NonConstCall, call *ast.CallExpr, query int, rule string, severity, confidence Level) (Result, Fingerprint) {
+	fp := NewFingerprint(ci, cc)
+	message := fmt.Sprintf("Query passed to %s is not a compile-time constant", ci.Method.Func.FullName())
+	if rule == RuleDynamicDDL {
+		message = fmt.Sprintf("DDL statement passed to %s is not a compile-time constant; check or quote the identifiers it's built from", ci.Method.Func.FullName())
+	} else if rule == RuleInList {
+		message = fmt.Sprintf("Query passed to %s builds an IN list with fmt.Sprintf and strings.Join; %s", ci.Method.Func.FullName(), inListAdvice(p))
+	} else if cc.FromTypeAssertion(ci.Query) {
+		message = fmt.Sprintf("Query passed to %s comes from a type assertion, so where it came from can't be verified", ci.Method.Func.FullName())
+	}
+	result := Result{
+		Rule:        rule,
+		Position:    p.Fset.Position(ci.Site.Pos()),
+		Package:     fp.Package,
+		Message:     message,
+		Severity:    severity,
+		Confidence:  confidence,
+		Fingerprint: fp.Hash(),
+		Suppressed:  true,
+		Commands:    commands.Commands(ci.Site.Parent()),
+	}
+	if call != nil {
+		arg := call.Args[query]
+		result.Argument, result.ArgumentEnd = p.Fset.Position(arg.Pos()), p.Fset.Position(arg.End())
+	}
+	result.Flow = dynamicFlow(p, cc, ci.Query)
+	return result, fp
+}
+
+// queryRule returns the rule which the non-constant query passed to a query
+// method breaks: SAFESQL005 if it's a DDL statement, SAFESQL006 if its IN list
+// is spliced in with strings.Join, and SAFESQL001 otherwise.
+func queryRule(cc *ConstChecker, query ssa.Value) string {
+	switch {
+	case query == nil:
+		return RuleNonConstQuery
+	case cc.IsDDL(query):
+		return RuleDynamicDDL
+	case cc.InListJoin(query) != nil:
+		return RuleInList
+	}
+	return RuleNonConstQuery
+}
+
+// inListAdvice says how to pass the values of an IN list as parameters
+// instead, with the database packages p uses.
+func inListAdvice(p *loader.Program) string {
+	if p.Package("github.com/jmoiron/sqlx") != nil {
+		return "expand a placeholder for the values with sqlx.In instead"
+	}
+	for pkg := range p.AllPackages {
+		switch path := pkg.Path(); {
+		case strings.HasPrefix(path, "github.com/jackc/pgx"):
+			return "pass the values as an array parameter instead, e.g. id = ANY($1)"
+		case path == "github.com/lib/pq":
+			return "pass the values as an array parameter with pq.Array instead, e.g. id = ANY($1)"
+		}
+	}
+	return "pass each value as a parameter instead"
+}
+
+// dsnResult returns the SAFESQL003 finding about the data source name built
+// from an HTTP request which is passed in site, and its fingerprint.
+func dsnResult(p *loader.Program, cc *ConstChecker, commands *CommandIndex, site ssa.CallInstruction, severity Level) (Result, Fingerprint) {
+	callee := site.Common().StaticCallee()
+	fp := CallFingerprint(site, callee.String())
+	fp.Query = cc.QueryShape(site.Common().Args[1])
+	result := Result{
+		Rule:        RuleRequestDSN,
+		Position:    p.Fset.Position(site.Pos()),
+		Package:     fp.Package,
+		Message:     fmt.Sprintf("Data source name passed to %s is built from an HTTP request", callee),
+		Severity:    severity,
+		Confidence:  LevelHigh,
+		Fingerprint: fp.Hash(),
+		Suppressed:  true,
+		Commands:    commands.Commands(site.Parent()),
+	}
+	for _, part := range cc.DynamicParts(site.Common().Args[1]) {
+		result.Flow = append(result.Flow, FlowStep{p.Fset.Position(part.Pos), "non-constant part: " + part.Description})
+	}
+	return result, fp
+}
+
+// fieldResult returns the SAFESQL001 finding about the non-constant SQL which
+// store stores in a field, and its fingerprint.
+func fieldResult(p *loader.Program, cc *ConstChecker, commands *CommandIndex, store *ssa.Store, severity, confidence Level) (Result, Fingerprint) {
+	addr := store.Addr.(*ssa.FieldAddr)
+	t := deref(addr.X.Type())
+	field := fmt.Sprintf("%s.%s", t, t.Underlying().(*types.Struct).Field(addr.Field).Name())
+	fp := Fingerprint{Method: field, Query: cc.QueryShape(store.Val)}
+	if fn := store.Parent(); fn.Pkg != nil {
+		fp.Package, fp.Function = fn.Pkg.Pkg.Path(), fn.RelString(fn.Pkg.Pkg)
+	}
+	result := Result{
+		Rule:        RuleNonConstQuery,
+		Position:    p.Fset.Position(store.Pos()),
+		Package:     fp.Package,
+		Message:     fmt.Sprintf("SQL stored in %s is not a compile-time constant", field),
+		Severity:    severity,
+		Confidence:  confidence,
+		Fingerprint: fp.Hash(),
+		Suppressed:  true,
+		Commands:    commands.Commands(store.Parent()),
+	}
+	result.Flow = dynamicFlow(p, cc, store.Val)
+	return result, fp
+}
+
+// uncheckedResult returns the SAFESQL004 finding about the database handle
+// which u lets escape, and its fingerprint. what says how it escapes.
+func uncheckedResult(p *loader.Program, commands *CommandIndex, u UncheckedUse, what string, severity Level) (Result, Fingerprint) {
+	fp := Fingerprint{Method: u.Via, Query: u.Type.String()}
+	if fn := u.Site.Parent(); fn.Pkg != nil {
+		fp.Package, fp.Function = fn.Pkg.Pkg.Path(), fn.RelString(fn.Pkg.Pkg)
+	}
+	result := Result{
+		Rule:        RuleUncheckedHandle,
+		Position:    p.Fset.Position(u.Site.Pos()),
+		Package:     fp.Package,
+		Message:     fmt.Sprintf("Database handle of type %s %s, so the queries passed to it from there can't be checked", u.Type, what),
+		Severity:    severity,
+		Confidence:  LevelLow,
+		Fingerprint: fp.Hash(),
+		Suppressed:  true,
+		Commands:    commands.Commands(u.Site.Parent()),
+	}
+	return result, fp
+}
+
+// sqlFormatResult returns the SAFESQL007 finding about the call which builds
+// what looks like SQL with a Sprintf-like function, and its fingerprint.
+func sqlFormatResult(p *loader.Program, commands *CommandIndex, call *ssa.Call, format string, severity Level) (Result, Fingerprint) {
+	callee := call.Common().StaticCallee()
+	fp := CallFingerprint(call, callee.String())
+	fp.Query = format
+	result := Result{
+		Rule:        RuleSQLFormat,
+		Position:    p.Fset.Position(call.Pos()),
+		Package:     fp.Package,
+		Message:     fmt.Sprintf("SQL is built with %s but isn't passed to a database package safesql knows; if it's run by another, add that package to the configuration file", callee),
+		Severity:    severity,
+		Confidence:  LevelLow,
+		Fingerprint: fp.Hash(),
+		Suppressed:  true,
+		Commands:    commands.Commands(call.Parent()),
+	}
+	return result, fp
+}
+
+// invalidSQLResult returns the SAFESQL002 finding about the constant query
+// which q is passed, and which verr says is not valid SQL, and its
+// fingerprint.
+func invalidSQLResult(p *loader.Program, cc *ConstChecker, commands *CommandIndex, q ConstQuery, query string, verr error, severity Level) (Result, Fingerprint) {
+	fp := NewFingerprint(NonConstCall{Site: q.Site, Method: q.Method}, cc)
+	fp.Query = query
+	result := Result{
+		Rule:        RuleInvalidSQL,
+		Position:    p.Fset.Position(q.Site.Pos()),
+		Package:     fp.Package,
+		Message:     fmt.Sprintf("Query passed to %s is not valid SQL: %v", q.Method.Func.FullName(), verr),
+		Severity:    severity,
+		Confidence:  LevelMedium,
+		Fingerprint: fp.Hash(),
+		Suppressed:  true,
+		Commands:    commands.Commands(q.Site.Parent()),
+	}
+	return result, fp
+}
+
+// uncheckedWhat says how u lets its database handle escape.
+func uncheckedWhat(u UncheckedUse) string {
+	if u.Via == "unsafe" {
+		return "is converted to an unsafe.Pointer"
+	}
+	return "is passed to package reflect"
+}
+
+// dynamicFlow returns the steps by which the non-constant parts of query,
+// and the variables they're declared as, flow into it.
+func dynamicFlow(p *loader.Program, cc *ConstChecker, query ssa.Value) []FlowStep {
+	var flow []FlowStep
+	for _, part := range cc.DynamicParts(query) {
+		if part.Decl.IsValid() {
+			flow = append(flow, FlowStep{p.Fset.Position(part.Decl), part.Description + " declared here"})
+		}
+		flow = append(flow, FlowStep{p.Fset.Position(part.Pos), "non-constant part: " + part.Description})
+	}
+	return flow
+}
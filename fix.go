@@ -0,0 +1,523 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/loader"
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+// A Fix is a rewrite of the source which resolves a finding.
+type Fix struct {
+	Message string
+	Edits   []TextEdit
+}
+
+// A TextEdit replaces the text from Pos up to End with NewText.
+type TextEdit struct {
+	Pos, End token.Position
+	NewText  string
+}
+
+// PlaceholderStyle returns the style of the placeholders understood by the
+// given database driver: "?", or "$", "@p" or ":" for numbered placeholders
+// such as $1.
+func PlaceholderStyle(driver string) string {
+	if d, ok := DriverDialect(driver); ok {
+		return d.Placeholder
+	}
+	return "?"
+}
+
+// placeholder returns the n-th placeholder, counting from 1, in the given
+// style.
+func placeholder(style string, n int) string {
+	if style == "?" {
+		return style
+	}
+	return style + strconv.Itoa(n)
+}
+
+// takesArgs reports whether the query of m is followed by the variadic
+// arguments for its placeholders.
+func takesArgs(m *QueryMethod) bool {
+	sig := m.Func.Type().(*types.Signature)
+	return sig.Variadic() && m.Param == sig.Params().Len()-2
+}
+
+// SuggestFix returns a fix for a call whose query operand (the query-th
+// argument) is built in the call itself, with fmt.Sprintf or by concatenating
+// string literals and values, which puts placeholders in the query and passes
+// the values as arguments instead. It returns nil for anything but the simple
+// cases: the call mustn't have any arguments after the query yet, the query
+// mustn't contain placeholders already, and every value must be compared with
+// something (e.g. "id = %d" or "LIMIT %d") rather than, say, name a column,
+// which can't be a placeholder. Values which aren't numbers or booleans must
+// be quoted in the query, so that we don't pass one which is quoted some
+// other way.
+func SuggestFix(fset *token.FileSet, info *types.Info, call *ast.CallExpr, query int, style string) *Fix {
+	if call.Ellipsis.IsValid() || query != len(call.Args)-1 {
+		return nil
+	}
+	arg := call.Args[query]
+	texts, values, raw, ok := sprintfParts(info, arg)
+	if !ok {
+		texts, values, raw, ok = concatParts(info, arg)
+	}
+	if !ok || len(values) == 0 {
+		return nil
+	}
+	for _, text := range texts {
+		if strings.ContainsAny(text, "?$") {
+			return nil
+		}
+	}
+
+	var q bytes.Buffer
+	srcs := make([]string, 0, len(values))
+	for i, v := range values {
+		before, after := texts[i], texts[i+1]
+		quoted := strings.HasSuffix(before, "'") && strings.HasPrefix(after, "'")
+		if quoted {
+			before, texts[i+1] = before[:len(before)-1], after[1:]
+		} else if !isScalarType(info.TypeOf(v)) {
+			return nil
+		}
+		if !comparedWith(before) {
+			return nil
+		}
+		var src bytes.Buffer
+		if err := format.Node(&src, fset, v); err != nil {
+			return nil
+		}
+		q.WriteString(before)
+		q.WriteString(placeholder(style, i+1))
+		srcs = append(srcs, src.String())
+	}
+	q.WriteString(texts[len(values)])
+
+	lit := strconv.Quote(q.String())
+	if raw && !strings.Contains(q.String(), "`") {
+		lit = "`" + q.String() + "`"
+	}
+	message := fmt.Sprintf("Pass %s as a query argument", srcs[0])
+	if len(srcs) > 1 {
+		message = fmt.Sprintf("Pass %s as query arguments", strings.Join(srcs, ", "))
+	}
+	return &Fix{
+		Message: message,
+		Edits: []TextEdit{{
+			Pos:     fset.Position(arg.Pos()),
+			End:     fset.Position(arg.End()),
+			NewText: lit + ", " + strings.Join(srcs, ", "),
+		}},
+	}
+}
+
+// An IdentifierQuoter is a function of a database package which quotes
+// identifiers, so that they can be spliced into statements safely.
+type IdentifierQuoter struct {
+	// Path is the import path of the package, and Name the name it
+	// declares.
+	Path, Name string
+	// Func is the name of the function, or method, which quotes.
+	Func string
+	// Format is the Go expression which quotes an identifier, with %[1]s
+	// standing for the package's name and %[2]s for the identifier.
+	Format string
+}
+
+// IdentifierQuoters lists the identifier quoters safesql knows, in order of
+// preference.
+var IdentifierQuoters = []IdentifierQuoter{
+	{"github.com/lib/pq", "pq", "QuoteIdentifier", "%[1]s.QuoteIdentifier(%[2]s)"},
+	{"github.com/jackc/pgx/v5", "pgx", "Sanitize", "%[1]s.Identifier{%[2]s}.Sanitize()"},
+	{"github.com/jackc/pgx/v4", "pgx", "Sanitize", "%[1]s.Identifier{%[2]s}.Sanitize()"},
+}
+
+// ProgramQuoter returns the identifier quoter of the first of the packages
+// which have one that the program uses, or false if it uses none.
+func ProgramQuoter(p *loader.Program) (IdentifierQuoter, bool) {
+	for _, q := range IdentifierQuoters {
+		if p.Package(q.Path) != nil {
+			return q, true
+		}
+	}
+	return IdentifierQuoter{}, false
+}
+
+// isQuoteFunc reports whether fn is the function of one of the
+// IdentifierQuoters.
+func isQuoteFunc(fn *types.Func) bool {
+	if fn == nil || fn.Pkg() == nil {
+		return false
+	}
+	for _, q := range IdentifierQuoters {
+		if fn.Pkg().Path() == q.Path && fn.Name() == q.Func {
+			return true
+		}
+	}
+	return false
+}
+
+// SuggestQuoteFix returns a fix for a call whose DDL statement operand (the
+// query-th argument) is built in the call itself, with fmt.Sprintf or by
+// concatenating string literals and values, which quotes the values it's
+// built from with q, since placeholders can't stand for identifiers. Double
+// quotes around a value in the statement are dropped, since q adds its own,
+// and q's package is imported into file if it isn't already. It returns nil if
+// any of the values is quoted as a string in the statement, or none of them
+// are strings which aren't quoted with q already.
+func SuggestQuoteFix(fset *token.FileSet, file *ast.File, info *types.Info, call *ast.CallExpr, query int, q IdentifierQuoter) *Fix {
+	if file == nil || query >= len(call.Args) {
+		return nil
+	}
+	arg := call.Args[query]
+	var texts []string
+	var values []ast.Expr
+	var verbs []byte
+	var fun ast.Expr
+	raw, ok := false, false
+	if sprintf, isCall := astutil.Unparen(arg).(*ast.CallExpr); isCall {
+		if texts, values, raw, ok = sprintfParts(info, sprintf); ok {
+			layout, _, _ := stringLit(sprintf.Args[0])
+			_, verbs, _ = splitFormat(layout)
+			fun = sprintf.Fun
+		}
+	}
+	if !ok {
+		texts, values, raw, ok = concatParts(info, arg)
+	}
+	if !ok || len(values) == 0 {
+		return nil
+	}
+
+	name, imported := importName(file, q.Path, q.Name)
+	if !imported {
+		// The name must be free in the file for the import to add it.
+		if scope := info.Scopes[file]; scope == nil || scope.Lookup(q.Name) != nil || scope.Parent().Lookup(q.Name) != nil {
+			return nil
+		}
+		name = q.Name
+	}
+	srcs := make([]string, len(values))
+	quoted := make([]string, 0, len(values))
+	for i, v := range values {
+		var src bytes.Buffer
+		if err := format.Node(&src, fset, v); err != nil {
+			return nil
+		}
+		srcs[i] = src.String()
+		before, after := texts[i], texts[i+1]
+		if strings.HasSuffix(before, "'") && strings.HasPrefix(after, "'") {
+			return nil
+		}
+		if !isString(info.TypeOf(v)) || isQuoteCall(info, v) {
+			continue
+		}
+		if strings.HasSuffix(before, `"`) && strings.HasPrefix(after, `"`) {
+			texts[i], texts[i+1] = before[:len(before)-1], after[1:]
+		}
+		quoted = append(quoted, srcs[i])
+		srcs[i] = fmt.Sprintf(q.Format, name, srcs[i])
+	}
+	if len(quoted) == 0 {
+		return nil
+	}
+
+	literal := func(s string) string {
+		if raw && !strings.Contains(s, "`") {
+			return "`" + s + "`"
+		}
+		return strconv.Quote(s)
+	}
+	var expr bytes.Buffer
+	if fun != nil {
+		if err := format.Node(&expr, fset, fun); err != nil {
+			return nil
+		}
+		var layout strings.Builder
+		for i, text := range texts {
+			layout.WriteString(strings.Replace(text, "%", "%%", -1))
+			if i < len(verbs) {
+				layout.WriteByte('%')
+				layout.WriteByte(verbs[i])
+			}
+		}
+		fmt.Fprintf(&expr, "(%s, %s)", literal(layout.String()), strings.Join(srcs, ", "))
+	} else {
+		operands := make([]string, 0, len(texts)+len(srcs))
+		for i, text := range texts {
+			if text != "" {
+				operands = append(operands, literal(text))
+			}
+			if i < len(srcs) {
+				operands = append(operands, srcs[i])
+			}
+		}
+		expr.WriteString(strings.Join(operands, " + "))
+	}
+
+	fix := &Fix{
+		Message: fmt.Sprintf("Quote %s with %s.%s", strings.Join(quoted, ", "), q.Name, q.Func),
+		Edits: []TextEdit{{
+			Pos:     fset.Position(arg.Pos()),
+			End:     fset.Position(arg.End()),
+			NewText: expr.String(),
+		}},
+	}
+	if !imported {
+		fix.Edits = append(fix.Edits, importEdit(fset, file, q.Path))
+	}
+	return fix
+}
+
+// fileAt returns the one of files which pos is in, or nil.
+func fileAt(files []*ast.File, pos token.Pos) *ast.File {
+	for _, f := range files {
+		if f.Pos() <= pos && pos < f.End() {
+			return f
+		}
+	}
+	return nil
+}
+
+// isQuoteCall reports whether e is a call to the function of one of the
+// IdentifierQuoters.
+func isQuoteCall(info *types.Info, e ast.Expr) bool {
+	call, ok := astutil.Unparen(e).(*ast.CallExpr)
+	if !ok {
+		return false
+	}
+	fn, _ := typeutil.Callee(info, call).(*types.Func)
+	return isQuoteFunc(fn)
+}
+
+// importName returns the name the package with the given import path, which
+// declares the given name, is imported as in file, if it's imported by a name
+// which can be used.
+func importName(file *ast.File, path, name string) (string, bool) {
+	for _, spec := range file.Imports {
+		if p, err := strconv.Unquote(spec.Path.Value); err != nil || p != path {
+			continue
+		}
+		if spec.Name == nil {
+			return name, true
+		}
+		if spec.Name.Name != "_" && spec.Name.Name != "." {
+			return spec.Name.Name, true
+		}
+	}
+	return "", false
+}
+
+// importEdit returns the edit which imports the package with the given path
+// into file, at the end of its last import declaration, or after its package
+// clause if it has none.
+func importEdit(fset *token.FileSet, file *ast.File, path string) TextEdit {
+	var last *ast.GenDecl
+	for _, decl := range file.Decls {
+		if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.IMPORT {
+			last = gen
+		}
+	}
+	switch {
+	case last == nil:
+		pos := fset.Position(file.Name.End())
+		return TextEdit{Pos: pos, End: pos, NewText: "\n\nimport " + strconv.Quote(path)}
+	case last.Rparen.IsValid() && fset.Position(last.Rparen).Column == 1:
+		pos := fset.Position(last.Rparen)
+		return TextEdit{Pos: pos, End: pos, NewText: "\t" + strconv.Quote(path) + "\n"}
+	case last.Rparen.IsValid():
+		pos := fset.Position(last.Rparen)
+		return TextEdit{Pos: pos, End: pos, NewText: "; " + strconv.Quote(path)}
+	}
+	pos := fset.Position(last.End())
+	return TextEdit{Pos: pos, End: pos, NewText: "\nimport " + strconv.Quote(path)}
+}
+
+// sprintfParts splits a call to fmt.Sprintf with a literal format into the
+// text around its verbs and the values formatted by them. Only the plain %d,
+// %s and %v verbs are allowed. raw reports whether the format is a raw string
+// literal.
+func sprintfParts(info *types.Info, e ast.Expr) (texts []string, values []ast.Expr, raw, ok bool) {
+	call, isCall := astutil.Unparen(e).(*ast.CallExpr)
+	if !isCall || call.Ellipsis.IsValid() || len(call.Args) == 0 {
+		return nil, nil, false, false
+	}
+	if fn := typeutil.StaticCallee(info, call); fn == nil || fn.FullName() != "fmt.Sprintf" {
+		return nil, nil, false, false
+	}
+	layout, raw, ok := stringLit(call.Args[0])
+	if !ok {
+		return nil, nil, false, false
+	}
+	texts, _, ok = splitFormat(layout)
+	if !ok || len(texts)-1 != len(call.Args)-1 {
+		return nil, nil, false, false
+	}
+	return texts, call.Args[1:], raw, true
+}
+
+// splitFormat splits a format string into the text around its verbs and the
+// verbs. Only the plain %d, %s and %v verbs are allowed.
+func splitFormat(layout string) (texts []string, verbs []byte, ok bool) {
+	var text strings.Builder
+	for i := 0; i < len(layout); i++ {
+		if layout[i] != '%' {
+			text.WriteByte(layout[i])
+			continue
+		}
+		if i++; i == len(layout) {
+			return nil, nil, false
+		}
+		switch layout[i] {
+		case '%':
+			text.WriteByte('%')
+		case 'd', 's', 'v':
+			texts = append(texts, text.String())
+			verbs = append(verbs, layout[i])
+			text.Reset()
+		default:
+			return nil, nil, false
+		}
+	}
+	return append(texts, text.String()), verbs, true
+}
+
+// concatParts splits a concatenation of string literals and non-constant
+// values into the text between the values and the values. raw reports whether
+// all of the literals are raw string literals.
+func concatParts(info *types.Info, e ast.Expr) (texts []string, values []ast.Expr, raw, ok bool) {
+	var operands []ast.Expr
+	var flatten func(e ast.Expr) bool
+	flatten = func(e ast.Expr) bool {
+		e = astutil.Unparen(e)
+		if bin, isBin := e.(*ast.BinaryExpr); isBin {
+			return bin.Op == token.ADD && flatten(bin.X) && flatten(bin.Y)
+		}
+		operands = append(operands, e)
+		return true
+	}
+	if b, isBin := astutil.Unparen(e).(*ast.BinaryExpr); !isBin || !flatten(b) {
+		return nil, nil, false, false
+	}
+
+	raw = true
+	var text strings.Builder
+	for _, op := range operands {
+		if s, r, isLit := stringLit(op); isLit {
+			text.WriteString(s)
+			raw = raw && r
+			continue
+		}
+		if tv, known := info.Types[op]; !known || tv.Value != nil {
+			// A named constant, which we'd have to spell out.
+			return nil, nil, false, false
+		}
+		texts = append(texts, text.String())
+		values = append(values, op)
+		text.Reset()
+	}
+	return append(texts, text.String()), values, raw, true
+}
+
+// stringLit returns the value of a string literal, and whether it's a raw
+// string literal.
+func stringLit(e ast.Expr) (s string, raw, ok bool) {
+	lit, isLit := astutil.Unparen(e).(*ast.BasicLit)
+	if !isLit || lit.Kind != token.STRING {
+		return "", false, false
+	}
+	s, err := strconv.Unquote(lit.Value)
+	if err != nil {
+		return "", false, false
+	}
+	return s, strings.HasPrefix(lit.Value, "`"), true
+}
+
+// comparedWith reports whether a value following the given query text is
+// compared with something, or is the operand of LIMIT or OFFSET.
+func comparedWith(before string) bool {
+	s := strings.ToUpper(strings.TrimRight(before, " \t\r\n"))
+	if strings.HasSuffix(s, "=") || strings.HasSuffix(s, "<") || strings.HasSuffix(s, ">") {
+		return true
+	}
+	for _, keyword := range []string{"LIKE", "LIMIT", "OFFSET"} {
+		if !strings.HasSuffix(s, keyword) {
+			continue
+		}
+		rest := s[:len(s)-len(keyword)]
+		if rest == "" || strings.HasSuffix(rest, " ") || strings.HasSuffix(rest, "\t") || strings.HasSuffix(rest, "\n") {
+			return true
+		}
+	}
+	return false
+}
+
+// isScalarType reports whether t is a number or boolean type, whose values
+// are never quoted.
+func isScalarType(t types.Type) bool {
+	b, ok := t.Underlying().(*types.Basic)
+	return ok && b.Info()&(types.IsNumeric|types.IsBoolean) != 0
+}
+
+// ApplyFixes applies the fixes to the files they are in and returns the
+// number of files it changed.
+func ApplyFixes(fixes []*Fix) (int, error) {
+	edits := make(map[string][]TextEdit)
+	for _, fix := range fixes {
+		for _, edit := range fix.Edits {
+			edits[edit.Pos.Filename] = append(edits[edit.Pos.Filename], edit)
+		}
+	}
+	names := make([]string, 0, len(edits))
+	for name := range edits {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fileEdits := edits[name]
+		sort.SliceStable(fileEdits, func(i, j int) bool { return fileEdits[i].Pos.Offset < fileEdits[j].Pos.Offset })
+		info, err := os.Stat(name)
+		if err != nil {
+			return 0, err
+		}
+		src, err := ioutil.ReadFile(name)
+		if err != nil {
+			return 0, err
+		}
+		var buf bytes.Buffer
+		last := 0
+		for i, edit := range fileEdits {
+			// Fixes which import the same package make the same edit,
+			// which is only made once.
+			if i > 0 && edit == fileEdits[i-1] {
+				continue
+			}
+			if edit.Pos.Offset < last || edit.End.Offset > len(src) {
+				return 0, fmt.Errorf("%s: overlapping edits at %s", name, edit.Pos)
+			}
+			buf.Write(src[last:edit.Pos.Offset])
+			buf.WriteString(edit.NewText)
+			last = edit.End.Offset
+		}
+		buf.Write(src[last:])
+		if err := ioutil.WriteFile(name, buf.Bytes(), info.Mode()); err != nil {
+			return 0, err
+		}
+	}
+	return len(names), nil
+}
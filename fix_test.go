@@ -0,0 +1,327 @@
+package main
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const fixSrc = `package main
+
+import "fmt"
+
+type DB struct{}
+
+func (*DB) Query(query string, args ...interface{}) {}
+
+const table = "t"
+
+func main() {
+	db := &DB{}
+	id, name, col := 1, "x", "a"
+	db.Query(fmt.Sprintf("SELECT * FROM t WHERE id = %d", id))
+	db.Query("SELECT * FROM t WHERE name = '" + name + "'")
+	db.Query(fmt.Sprintf("SELECT * FROM t WHERE name LIKE '%s' AND id > %v LIMIT %d", name, id, 10))
+	db.Query(fmt.Sprintf(` + "`SELECT * FROM t WHERE id = %d`" + `, id))
+	db.Query(fmt.Sprintf("SELECT * FROM t WHERE %s = 1", col))
+	db.Query(fmt.Sprintf("SELECT * FROM t WHERE name = %s", name))
+	db.Query(fmt.Sprintf("SELECT * FROM t WHERE id = %d AND a = ?", id))
+	db.Query(fmt.Sprintf("SELECT * FROM t WHERE id = %d", id), 1)
+	db.Query(fmt.Sprintf("SELECT * FROM t WHERE id = %5d", id))
+	db.Query("SELECT * FROM " + table + " WHERE name = '" + name + "'")
+	db.Query("SELECT * FROM t ORDER BY " + col)
+}
+`
+
+func TestSuggestFix(t *testing.T) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "main.go", fixSrc, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := &types.Info{
+		Types: make(map[ast.Expr]types.TypeAndValue),
+		Uses:  make(map[*ast.Ident]types.Object),
+	}
+	if _, err := (&types.Config{Importer: importer.Default()}).Check("main", fset, []*ast.File{f}, info); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		`"SELECT * FROM t WHERE id = $1", id`,
+		`"SELECT * FROM t WHERE name = $1", name`,
+		`"SELECT * FROM t WHERE name LIKE $1 AND id > $2 LIMIT $3", name, id, 10`,
+		"`SELECT * FROM t WHERE id = $1`, id",
+		"", // a column name
+		"", // a string which isn't quoted
+		"", // already has a placeholder
+		"", // already has arguments
+		"", // a verb with a width
+		"", // a named constant
+		"", // not compared with anything
+	}
+	i := 0
+	ast.Inspect(f, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		if sel, ok := call.Fun.(*ast.SelectorExpr); !ok || sel.Sel.Name != "Query" {
+			return true
+		}
+		fix := SuggestFix(fset, info, call, 0, "$")
+		got := ""
+		if fix != nil {
+			if len(fix.Edits) != 1 {
+				t.Fatalf("%s: expected one edit, got %+v", fset.Position(call.Pos()), fix.Edits)
+			}
+			got = fix.Edits[0].NewText
+		}
+		if i < len(expected) && got != expected[i] {
+			t.Errorf("%s: expected fix %q, got %q", fset.Position(call.Pos()), expected[i], got)
+		}
+		i++
+		return true
+	})
+	if i != len(expected) {
+		t.Errorf("found %d calls, expected %d", i, len(expected))
+	}
+}
+
+func TestPlaceholderStyle(t *testing.T) {
+	tests := map[string]string{
+		"postgres":  "$1",
+		"pgx":       "$1",
+		"sqlserver": "@p1",
+		"godror":    ":1",
+		"mysql":     "?",
+		"sqlite3":   "?",
+	}
+	for driver, expected := range tests {
+		if got := placeholder(PlaceholderStyle(driver), 1); got != expected {
+			t.Errorf("%s: expected %s, got %s", driver, expected, got)
+		}
+	}
+}
+
+func TestApplyFixes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "safesql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "main.go")
+	src := "db.Query(fmt.Sprintf(\"id = %d\", id))\ndb.Query(\"a = '\" + a + \"'\")\n"
+	if err := ioutil.WriteFile(name, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	edit := func(text, replacement string) TextEdit {
+		offset := strings.Index(src, text)
+		return TextEdit{
+			Pos:     token.Position{Filename: name, Offset: offset},
+			End:     token.Position{Filename: name, Offset: offset + len(text)},
+			NewText: replacement,
+		}
+	}
+	fixes := []*Fix{
+		{Edits: []TextEdit{edit(`"a = '" + a + "'"`, `"a = ?", a`)}},
+		{Edits: []TextEdit{edit(`fmt.Sprintf("id = %d", id)`, `"id = ?", id`)}},
+	}
+	n, err := ApplyFixes(fixes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("expected 1 file to be changed, got %d", n)
+	}
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "db.Query(\"id = ?\", id)\ndb.Query(\"a = ?\", a)\n"; string(data) != expected {
+		t.Errorf("expected %q, got %q", expected, data)
+	}
+}
+
+// quoterStubs are stubs of the packages with IdentifierQuoters, for the source
+// fixes are suggested for to import.
+var quoterStubs = map[string]string{
+	"github.com/lib/pq":       "package pq\n\nfunc QuoteIdentifier(name string) string { return name }\n",
+	"github.com/jackc/pgx/v5": "package pgx\n\ntype Identifier []string\n\nfunc (ident Identifier) Sanitize() string { return \"\" }\n",
+}
+
+// quoterImporter returns an importer of the quoterStubs, and of the standard
+// library.
+func quoterImporter(fset *token.FileSet) types.Importer {
+	imp := importer.Default()
+	return importerFunc(func(path string) (*types.Package, error) {
+		src, ok := quoterStubs[path]
+		if !ok {
+			return imp.Import(path)
+		}
+		stub, err := parser.ParseFile(fset, path+".go", src, 0)
+		if err != nil {
+			return nil, err
+		}
+		return (&types.Config{}).Check(path, fset, []*ast.File{stub}, nil)
+	})
+}
+
+// checkWithQuoters type-checks f, which may import the quoterStubs.
+func checkWithQuoters(t *testing.T, fset *token.FileSet, f *ast.File) *types.Info {
+	info := &types.Info{
+		Types:      make(map[ast.Expr]types.TypeAndValue),
+		Defs:       make(map[*ast.Ident]types.Object),
+		Uses:       make(map[*ast.Ident]types.Object),
+		Selections: make(map[*ast.SelectorExpr]*types.Selection),
+		Scopes:     make(map[ast.Node]*types.Scope),
+	}
+	if _, err := (&types.Config{Importer: quoterImporter(fset)}).Check("main", fset, []*ast.File{f}, info); err != nil {
+		t.Fatal(err)
+	}
+	return info
+}
+
+const quoteFixSrc = `package main
+
+import (
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+type DB struct{}
+
+func (*DB) Exec(query string, args ...interface{}) {}
+
+func main() {
+	db := &DB{}
+	schema, n := "s", 1
+	db.Exec(fmt.Sprintf("CREATE SCHEMA %s", schema))
+	db.Exec("DROP TABLE " + schema + ".users")
+	db.Exec(fmt.Sprintf(` + "`" + `CREATE TABLE "%s" (name VARCHAR(%d), "100%%" INT)` + "`" + `, schema, n))
+	db.Exec(fmt.Sprintf("COMMENT ON SCHEMA s IS '%s'", schema))
+	db.Exec("DROP SCHEMA " + pq.QuoteIdentifier(schema))
+	db.Exec(fmt.Sprintf("DROP TABLE t%d", n))
+}
+`
+
+func TestSuggestQuoteFix(t *testing.T) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "main.go", quoteFixSrc, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := checkWithQuoters(t, fset, f)
+
+	expected := []string{
+		`fmt.Sprintf("CREATE SCHEMA %s", pq.QuoteIdentifier(schema))`,
+		`"DROP TABLE " + pq.QuoteIdentifier(schema) + ".users"`,
+		"fmt.Sprintf(`CREATE TABLE %s (name VARCHAR(%d), \"100%%\" INT)`, pq.QuoteIdentifier(schema), n)",
+		"", // a string, rather than an identifier
+		"", // already quoted
+		"", // only a number
+	}
+	i := 0
+	ast.Inspect(f, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		if sel, ok := call.Fun.(*ast.SelectorExpr); !ok || sel.Sel.Name != "Exec" {
+			return true
+		}
+		fix := SuggestQuoteFix(fset, f, info, call, 0, IdentifierQuoters[0])
+		got := ""
+		if fix != nil {
+			if len(fix.Edits) != 1 {
+				t.Fatalf("%s: expected one edit, got %+v", fset.Position(call.Pos()), fix.Edits)
+			}
+			got = fix.Edits[0].NewText
+		}
+		if i < len(expected) && got != expected[i] {
+			t.Errorf("%s: expected fix %q, got %q", fset.Position(call.Pos()), expected[i], got)
+		}
+		if i == 0 && fix != nil && fix.Message != "Quote schema with pq.QuoteIdentifier" {
+			t.Errorf("unexpected message %q", fix.Message)
+		}
+		i++
+		return true
+	})
+	if i != len(expected) {
+		t.Errorf("found %d calls, expected %d", i, len(expected))
+	}
+}
+
+// TestSuggestQuoteFixImport checks that fixes import the quoter's package
+// once, however many of them there are in a file.
+func TestSuggestQuoteFixImport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "safesql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "main.go")
+	src := `package main
+
+import "fmt"
+
+type DB struct{}
+
+func (*DB) Exec(query string, args ...interface{}) {}
+
+func main() {
+	db := &DB{}
+	schema := "s"
+	db.Exec(fmt.Sprintf("CREATE SCHEMA %s", schema))
+	db.Exec("DROP SCHEMA \"" + schema + "\"")
+}
+`
+	if err := ioutil.WriteFile(name, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, name, src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := checkWithQuoters(t, fset, f)
+
+	var fixes []*Fix
+	ast.Inspect(f, func(n ast.Node) bool {
+		if call, ok := n.(*ast.CallExpr); ok {
+			if sel, ok := call.Fun.(*ast.SelectorExpr); ok && sel.Sel.Name == "Exec" {
+				if fix := SuggestQuoteFix(fset, f, info, call, 0, IdentifierQuoters[1]); fix != nil {
+					fixes = append(fixes, fix)
+				}
+			}
+		}
+		return true
+	})
+	if len(fixes) != 2 {
+		t.Fatalf("expected 2 fixes, got %d", len(fixes))
+	}
+	if _, err := ApplyFixes(fixes); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := strings.NewReplacer(
+		`import "fmt"`, "import \"fmt\"\nimport \"github.com/jackc/pgx/v5\"",
+		`fmt.Sprintf("CREATE SCHEMA %s", schema)`, `fmt.Sprintf("CREATE SCHEMA %s", pgx.Identifier{schema}.Sanitize())`,
+		`"DROP SCHEMA \"" + schema + "\""`, `"DROP SCHEMA " + pgx.Identifier{schema}.Sanitize()`,
+	).Replace(src)
+	if string(data) != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, data)
+	}
+}
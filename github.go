@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// A GitHubReport accumulates findings to write as GitHub Actions workflow
+// commands, which show up as annotations on pull requests. Only the findings
+// which fail the run are included.
+type GitHubReport struct {
+	root    string
+	results []Result
+}
+
+// NewGitHubReport returns an empty report whose file names are relative to
+// the given directory, which should be the root of the repository.
+func NewGitHubReport(root string) *GitHubReport {
+	return &GitHubReport{root: root}
+}
+
+// Add adds a finding to the report.
+func (r *GitHubReport) Add(result Result) {
+	if !result.Suppressed {
+		r.results = append(r.results, result)
+	}
+}
+
+// Write writes the report to w.
+func (r *GitHubReport) Write(w io.Writer) error {
+	for _, res := range r.results {
+		name := res.Position.Filename
+		if rel, ok := relPath(r.root, name); ok {
+			name = rel
+		}
+		message := res.Message
+		for _, step := range res.Steps() {
+			message += fmt.Sprintf("\n%s at line %d", step.Message, step.Position.Line)
+		}
+		_, err := fmt.Fprintf(w, "::%s file=%s,line=%d,col=%d,title=%s::%s\n",
+			severityName(res.Severity, "error", "warning", "notice"), githubEscapeProperty(name), res.Position.Line, res.Position.Column,
+			githubEscapeProperty(res.Rule), githubEscapeData(message))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var (
+	githubDataEscaper     = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A")
+	githubPropertyEscaper = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C")
+)
+
+func githubEscapeData(s string) string {
+	return githubDataEscaper.Replace(s)
+}
+
+func githubEscapeProperty(s string) string {
+	return githubPropertyEscaper.Replace(s)
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"bytes"
+	"go/token"
+	"testing"
+)
+
+func TestGitHubReport(t *testing.T) {
+	r := NewGitHubReport("/src/app")
+	r.Add(Result{
+		Rule:     RuleNonConstQuery,
+		Position: token.Position{Filename: "/src/app/db/a,b.go", Line: 14, Column: 19},
+		Message:  "Query is not a compile-time constant (100%)",
+		Flow:     []FlowStep{{token.Position{Filename: "/src/app/db/a,b.go", Line: 12, Column: 2}, "non-constant part: parameter name"}},
+	})
+	r.Add(Result{
+		Rule:       RuleNonConstQuery,
+		Position:   token.Position{Filename: "/src/app/db/db.go", Line: 20, Column: 19},
+		Suppressed: true,
+	})
+
+	var buf bytes.Buffer
+	if err := r.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	expected := "::error file=db/a%2Cb.go,line=14,col=19,title=SAFESQL001::Query is not a compile-time constant (100%25)%0Anon-constant part: parameter name at line 12\n"
+	if buf.String() != expected {
+		t.Errorf("Write wrote %q, expected %q", buf.String(), expected)
+	}
+}
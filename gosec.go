@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/token"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// gosecSQLRules are the identifiers of gosec's rules for SQL built from
+// strings, which overlap with SAFESQL001, SAFESQL005 and SAFESQL006: G201 for
+// queries built with fmt.Sprintf and the like, and G202 for those built by
+// concatenation.
+var gosecSQLRules = []string{"G201", "G202"}
+
+// A GosecReport is the set of SQL findings of a gosec JSON report
+// (gosec -fmt json), which safesql's own findings on the same lines leave out,
+// so that tools collecting the findings of both don't count them twice.
+type GosecReport struct {
+	// lines maps the key of each file to the lines of its findings, and
+	// those to the findings' rules.
+	lines map[string]map[int]string
+}
+
+type gosecJSON struct {
+	Issues []gosecIssue
+}
+
+type gosecIssue struct {
+	RuleID       string            `json:"rule_id"`
+	File         string            `json:"file"`
+	Line         string            `json:"line"`
+	Nosec        bool              `json:"nosec"`
+	Suppressions []json.RawMessage `json:"suppressions"`
+}
+
+// ReadGosecReport reads the gosec JSON report at path. File names in it which
+// aren't absolute are taken to be relative to dir.
+func ReadGosecReport(path, dir string) (*GosecReport, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var report gosecJSON
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	g := &GosecReport{lines: make(map[string]map[int]string)}
+	for _, issue := range report.Issues {
+		// Issues suppressed with #nosec don't stand in for ours.
+		if !isGosecSQLRule(issue.RuleID) || issue.Nosec || len(issue.Suppressions) > 0 {
+			continue
+		}
+		first, last, err := parseGosecLines(issue.Line)
+		if err != nil {
+			return nil, fmt.Errorf("%s: issue in %s: %v", path, issue.File, err)
+		}
+		name := issue.File
+		if !filepath.IsAbs(name) {
+			name = filepath.Join(dir, name)
+		}
+		key := fileKey(name)
+		if g.lines[key] == nil {
+			g.lines[key] = make(map[int]string)
+		}
+		for line := first; line <= last; line++ {
+			g.lines[key][line] = issue.RuleID
+		}
+	}
+	return g, nil
+}
+
+// parseGosecLines parses the line of a gosec issue, which is a range such as
+// "14-16" if the code it's about spans several lines.
+func parseGosecLines(s string) (first, last int, err error) {
+	from, to := s, s
+	if i := strings.Index(s, "-"); i >= 0 {
+		from, to = s[:i], s[i+1:]
+	}
+	if first, err = strconv.Atoi(from); err != nil {
+		return 0, 0, fmt.Errorf("bad line %q", s)
+	}
+	if last, err = strconv.Atoi(to); err != nil || last < first {
+		return 0, 0, fmt.Errorf("bad line %q", s)
+	}
+	return first, last, nil
+}
+
+func isGosecSQLRule(id string) bool {
+	for _, rule := range gosecSQLRules {
+		if id == rule {
+			return true
+		}
+	}
+	return false
+}
+
+// Covers returns the gosec rule of the report's finding on the line of pos, if
+// there is one and it overlaps with the given safesql rule. A nil report
+// covers nothing.
+func (g *GosecReport) Covers(pos token.Position, rule string) (string, bool) {
+	if g == nil {
+		return "", false
+	}
+	if r, ok := LookupRule(rule); !ok || len(r.Gosec) == 0 {
+		return "", false
+	}
+	id, ok := g.lines[fileKey(pos.Filename)][pos.Line]
+	return id, ok
+}
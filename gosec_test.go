@@ -0,0 +1,70 @@
+package main
+
+import (
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const gosecSrc = `{
+	"Golang errors": {},
+	"Issues": [
+		{"severity": "MEDIUM", "confidence": "HIGH", "rule_id": "G201", "file": "/src/app/db/db.go", "line": "14", "column": "19", "nosec": false, "suppressions": null},
+		{"severity": "MEDIUM", "confidence": "HIGH", "rule_id": "G202", "file": "db/db.go", "line": "20-22", "column": "3", "nosec": false, "suppressions": null},
+		{"severity": "MEDIUM", "confidence": "HIGH", "rule_id": "G201", "file": "/src/app/db/db.go", "line": "30", "column": "3", "nosec": true, "suppressions": null},
+		{"severity": "LOW", "confidence": "HIGH", "rule_id": "G104", "file": "/src/app/db/db.go", "line": "40", "column": "2", "nosec": false, "suppressions": null}
+	],
+	"Stats": {"files": 1, "lines": 50, "nosec": 1, "found": 3}
+}`
+
+func TestGosecReport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "safesql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "gosec.json")
+	if err := ioutil.WriteFile(path, []byte(gosecSrc), 0644); err != nil {
+		t.Fatal(err)
+	}
+	g, err := ReadGosecReport(path, "/src/app")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		line int
+		rule string
+		id   string
+	}{
+		{14, RuleNonConstQuery, "G201"},
+		{14, RuleDynamicDDL, "G201"},
+		// Data source names aren't SQL.
+		{14, RuleRequestDSN, ""},
+		{15, RuleNonConstQuery, ""},
+		{21, RuleNonConstQuery, "G202"},
+		// Suppressed with #nosec.
+		{30, RuleNonConstQuery, ""},
+		// Not about SQL.
+		{40, RuleNonConstQuery, ""},
+	} {
+		pos := token.Position{Filename: "/src/app/db/db.go", Line: test.line, Column: 1}
+		if id, ok := g.Covers(pos, test.rule); id != test.id || ok != (test.id != "") {
+			t.Errorf("Covers(line %d, %s) = %q, %v, expected %q", test.line, test.rule, id, ok, test.id)
+		}
+	}
+
+	var none *GosecReport
+	if _, ok := none.Covers(token.Position{Filename: "/src/app/db/db.go", Line: 14}, RuleNonConstQuery); ok {
+		t.Error("a nil report covers a finding")
+	}
+
+	if err := ioutil.WriteFile(path, []byte(`{"Issues": [{"rule_id": "G201", "file": "db.go", "line": "x"}]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadGosecReport(path, "/src/app"); err == nil {
+		t.Error("expected an error for a bad line")
+	}
+}
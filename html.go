@@ -0,0 +1,321 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"strings"
+)
+
+// An HTMLReport accumulates findings to write as a single, self-contained HTML
+// page, for sharing with people who won't run safesql themselves, e.g.
+// auditors. Findings are grouped by package, with the source around them and
+// the flow of data to them, and can be filtered by severity. Suppressed
+// findings are included, but hidden until asked for.
+type HTMLReport struct {
+	root     string
+	packages map[string][]Result
+	stats    *Stats
+	// Context is how many lines of source to show before and after each
+	// finding.
+	Context int
+
+	sources sourceFiles
+}
+
+// NewHTMLReport returns an empty report whose file names are relative to the
+// given directory.
+func NewHTMLReport(root string) *HTMLReport {
+	return &HTMLReport{root: root, packages: make(map[string][]Result), Context: 2, sources: make(sourceFiles)}
+}
+
+// Add adds a finding to the report.
+func (r *HTMLReport) Add(result Result) {
+	r.packages[result.Package] = append(r.packages[result.Package], result)
+}
+
+// SetStats includes statistics about the run in the report's summary.
+func (r *HTMLReport) SetStats(stats *Stats) {
+	r.stats = stats
+}
+
+type htmlPage struct {
+	Packages []htmlPackage
+	// Summary is the statistics about the run, as the text output shows
+	// them.
+	Summary string
+	// Severities are the filters, with the number of findings of each
+	// severity.
+	Severities []htmlCount
+	Suppressed int
+}
+
+type htmlCount struct {
+	Name  string
+	Count int
+}
+
+type htmlPackage struct {
+	Path     string
+	Findings []htmlFinding
+}
+
+type htmlFinding struct {
+	Location      string
+	Rule          Rule
+	Message       string
+	Severity      string
+	Confidence    string
+	Suppressed    bool
+	Justification string
+	Snippet       []htmlLine
+	// Flow is the data flow to the finding, ending with the finding
+	// itself, and Related the other places it shows up.
+	Flow    []htmlStep
+	Related []htmlStep
+	Fix     string
+}
+
+type htmlLine struct {
+	Number    int
+	Text      string
+	Reported  bool
+	Underline string
+}
+
+type htmlStep struct {
+	Location string
+	Message  string
+	Source   string
+}
+
+// htmlSeverity returns the name a finding's severity is filtered by.
+func htmlSeverity(l Level) string {
+	if l == 0 {
+		return "ungraded"
+	}
+	return l.String()
+}
+
+// Write writes the report to w, with packages sorted by import path.
+func (r *HTMLReport) Write(w io.Writer) error {
+	pkgs := make([]string, 0, len(r.packages))
+	for pkg := range r.packages {
+		pkgs = append(pkgs, pkg)
+	}
+	sort.Strings(pkgs)
+
+	var page htmlPage
+	counts := make(map[string]int)
+	for _, pkg := range pkgs {
+		hp := htmlPackage{Path: pkg}
+		for _, res := range r.packages[pkg] {
+			hp.Findings = append(hp.Findings, r.finding(res))
+			if res.Suppressed {
+				page.Suppressed++
+			} else {
+				counts[htmlSeverity(res.Severity)]++
+			}
+		}
+		page.Packages = append(page.Packages, hp)
+	}
+	for _, name := range []string{"high", "medium", "low", "ungraded"} {
+		if name != "ungraded" || counts[name] > 0 {
+			page.Severities = append(page.Severities, htmlCount{name, counts[name]})
+		}
+	}
+	if r.stats != nil {
+		var summary bytes.Buffer
+		r.stats.Write(&summary)
+		page.Summary = summary.String()
+	}
+	return htmlTemplate.Execute(w, page)
+}
+
+// finding returns what the page shows of a finding.
+func (r *HTMLReport) finding(res Result) htmlFinding {
+	rule, _ := LookupRule(res.Rule)
+	f := htmlFinding{
+		Location:      r.location(res.Position.Filename, res.Position.Line, res.Position.Column),
+		Rule:          rule,
+		Message:       res.Message,
+		Severity:      htmlSeverity(res.Severity),
+		Confidence:    res.Confidence.String(),
+		Suppressed:    res.Suppressed,
+		Justification: res.Justification,
+	}
+	if res.Fix != nil {
+		f.Fix = res.Fix.Message
+	}
+
+	lines := r.sources.lines(res.Position.Filename)
+	if res.Position.Line >= 1 && res.Position.Line <= len(lines) {
+		first, last := res.Position.Line-r.Context, res.Position.Line+r.Context
+		if first < 1 {
+			first = 1
+		}
+		if last > len(lines) {
+			last = len(lines)
+		}
+		for i := first; i <= last; i++ {
+			line := htmlLine{Number: i, Text: lines[i-1]}
+			if i == res.Position.Line {
+				line.Reported = true
+				// Underline the query as -format pretty does.
+				start, end := res.Position.Column, res.Position.Column+1
+				if res.Argument.Line == res.Position.Line {
+					start, end = res.Argument.Column, res.Argument.Column+1
+					if res.ArgumentEnd.Line == res.Argument.Line && res.ArgumentEnd.Column > start {
+						end = res.ArgumentEnd.Column
+					}
+				}
+				line.Underline = indentLike(line.Text, start-1) + "^" + strings.Repeat("~", end-start-1)
+			}
+			f.Snippet = append(f.Snippet, line)
+		}
+	}
+
+	for _, step := range res.Flow {
+		f.Flow = append(f.Flow, r.step(step))
+	}
+	if len(f.Flow) > 0 {
+		f.Flow = append(f.Flow, r.step(FlowStep{res.Position, res.Message}))
+	}
+	for _, step := range res.Related {
+		f.Related = append(f.Related, r.step(step))
+	}
+	return f
+}
+
+// step returns what the page shows of a step in the flow to a finding, with
+// the line of source it's on.
+func (r *HTMLReport) step(step FlowStep) htmlStep {
+	s := htmlStep{
+		Location: r.location(step.Position.Filename, step.Position.Line, step.Position.Column),
+		Message:  step.Message,
+	}
+	if lines := r.sources.lines(step.Position.Filename); step.Position.Line >= 1 && step.Position.Line <= len(lines) {
+		s.Source = lines[step.Position.Line-1]
+	}
+	return s
+}
+
+func (r *HTMLReport) location(filename string, line, column int) string {
+	if rel, ok := relPath(r.root, filename); ok {
+		filename = rel
+	}
+	return fmt.Sprintf("%s:%d:%d", filename, line, column)
+}
+
+var htmlTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>SafeSQL report</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em auto; max-width: 70em; color: #1f2328; }
+h1 { font-size: 1.6em; }
+h2 { font-size: 1.2em; border-bottom: 1px solid #d0d7de; padding-bottom: .3em; }
+.filters label { margin-right: 1.5em; }
+.finding { border: 1px solid #d0d7de; border-left-width: 6px; border-radius: 4px; margin: 1em 0; padding: .5em 1em; }
+.finding.high { border-left-color: #cf222e; }
+.finding.medium { border-left-color: #bf8700; }
+.finding.low, .finding.ungraded { border-left-color: #0969da; }
+.finding.suppressed { opacity: .6; }
+.finding .meta { color: #57606a; font-size: .9em; }
+.finding .message { font-weight: 600; margin: .3em 0; }
+pre { background: #f6f8fa; padding: .5em; overflow-x: auto; font-size: .85em; line-height: 1.4; tab-size: 4; }
+pre .reported { background: #ffebe9; }
+pre .underline { color: #cf222e; }
+ol.flow, ul.related { font-size: .9em; }
+ol.flow code, ul.related code { background: #f6f8fa; }
+.hidden { display: none; }
+</style>
+</head>
+<body>
+<h1>SafeSQL report</h1>
+{{- with .Summary}}
+<pre class="summary">{{.}}</pre>
+{{- end}}
+<p class="filters">
+{{- range .Severities}}
+<label><input type="checkbox" data-severity="{{.Name}}" checked> {{.Name}} ({{.Count}})</label>
+{{- end}}
+<label><input type="checkbox" data-severity="suppressed"> suppressed ({{.Suppressed}})</label>
+</p>
+{{- if not .Packages}}
+<p>No findings.</p>
+{{- end}}
+{{- range .Packages}}
+<section class="package">
+<h2>{{.Path}}</h2>
+{{- range .Findings}}
+<div class="finding {{.Severity}}{{if .Suppressed}} suppressed hidden{{end}}" data-severity="{{.Severity}}"{{if .Suppressed}} data-suppressed{{end}}>
+<div class="meta">{{.Location}} · <a href="{{.Rule.HelpURI}}">{{.Rule.ID}} {{.Rule.Name}}</a>
+{{- if .Confidence}} · {{.Severity}} severity, {{.Confidence}} confidence{{end}}
+{{- with .Rule.References}} · {{.}}{{end}}
+{{- if .Suppressed}} · suppressed{{with .Justification}}: {{.}}{{end}}{{end}}</div>
+<div class="message">{{.Message}}</div>
+{{- if .Snippet}}
+<pre>
+{{- range .Snippet}}
+<span{{if .Reported}} class="reported"{{end}}>{{printf "%5d" .Number}} | {{.Text}}</span>
+{{- if .Reported}}
+<span class="underline">      | {{.Underline}}</span>
+{{- end}}
+{{- end}}
+</pre>
+{{- end}}
+{{- if .Flow}}
+<p>Data flow:</p>
+<ol class="flow">
+{{- range .Flow}}
+<li>{{.Location}}: {{.Message}}{{with .Source}}<br><code>{{.}}</code>{{end}}</li>
+{{- end}}
+</ol>
+{{- end}}
+{{- if .Related}}
+<p>Also at:</p>
+<ul class="related">
+{{- range .Related}}
+<li>{{.Location}}: {{.Message}}{{with .Source}}<br><code>{{.}}</code>{{end}}</li>
+{{- end}}
+</ul>
+{{- end}}
+{{- with .Fix}}
+<p>Fix: {{.}}</p>
+{{- end}}
+</div>
+{{- end}}
+</section>
+{{- end}}
+<script>
+(function() {
+	var boxes = document.querySelectorAll(".filters input");
+	function update() {
+		var shown = {};
+		boxes.forEach(function(box) { shown[box.dataset.severity] = box.checked; });
+		document.querySelectorAll(".package").forEach(function(pkg) {
+			var visible = 0;
+			pkg.querySelectorAll(".finding").forEach(function(f) {
+				var show = shown[f.dataset.severity] !== false;
+				if ("suppressed" in f.dataset) {
+					show = show && shown.suppressed;
+				}
+				f.classList.toggle("hidden", !show);
+				if (show) {
+					visible++;
+				}
+			});
+			pkg.classList.toggle("hidden", visible == 0);
+		});
+	}
+	boxes.forEach(function(box) { box.addEventListener("change", update); });
+	update();
+})();
+</script>
+</body>
+</html>
+`))
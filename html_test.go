@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHTMLReport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "safesql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "db.go")
+	if err := ioutil.WriteFile(filename, []byte(prettySrc), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewHTMLReport(dir)
+	r.Add(Result{
+		Rule:        RuleNonConstQuery,
+		Position:    token.Position{Filename: filename, Line: 5, Column: 23},
+		Package:     "example.com/app/db",
+		Message:     "Query passed to <db>.Query is not a compile-time constant",
+		Severity:    LevelHigh,
+		Confidence:  LevelMedium,
+		Flow:        []FlowStep{{token.Position{Filename: filename, Line: 4, Column: 7}, "non-constant part: parameter name"}},
+		Argument:    token.Position{Filename: filename, Line: 5, Column: 24},
+		ArgumentEnd: token.Position{Filename: filename, Line: 5, Column: 25},
+	})
+	r.Add(Result{
+		Rule:          RuleNonConstQuery,
+		Position:      token.Position{Filename: filename, Line: 4, Column: 2},
+		Package:       "example.com/app/db",
+		Message:       "Query is not a compile-time constant",
+		Severity:      LevelLow,
+		Confidence:    LevelLow,
+		Suppressed:    true,
+		Justification: "legacy",
+	})
+	r.Add(Result{
+		Rule:     RuleNonConstQuery,
+		Position: token.Position{Filename: "/elsewhere/api.go", Line: 3, Column: 1},
+		Package:  "example.com/app/api",
+		Message:  "Query is not a compile-time constant",
+	})
+
+	var buf bytes.Buffer
+	if err := r.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	page := buf.String()
+	for _, expected := range []string{
+		// Packages are sorted, and messages escaped.
+		"<h2>example.com/app/api</h2>",
+		"<h2>example.com/app/db</h2>",
+		"Query passed to &lt;db&gt;.Query is not a compile-time constant",
+		// The snippet, with the query underlined.
+		`<span class="reported">    5 | 	rows, err := db.Query(q)</span>`,
+		`<span class="underline">      | 	                      ^</span>`,
+		// The flow to the finding, and its rule.
+		"<li>db.go:4:7: non-constant part: parameter name<br><code>	q := &#34;SELECT * FROM &#34; &#43; name</code></li>",
+		`<a href="https://github.com/stripe/safesql#safesql001">SAFESQL001 NonConstantQuery</a>`,
+		"CWE-89, A03:2021 - Injection",
+		// The filters count the findings which fail the run.
+		`data-severity="high" checked> high (1)`,
+		`data-severity="ungraded" checked> ungraded (1)`,
+		`data-severity="suppressed"> suppressed (1)`,
+		`class="finding low suppressed hidden"`,
+		"suppressed: legacy",
+		"/elsewhere/api.go:3:1",
+	} {
+		if !strings.Contains(page, expected) {
+			t.Errorf("report doesn't contain %q:\n%s", expected, page)
+		}
+	}
+}
+
+func TestWriteReport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "safesql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "report.html")
+	if err := writeReport(NewHTMLReport(dir), filename); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(data, []byte("<p>No findings.</p>")) {
+		t.Errorf("unexpected report:\n%s", data)
+	}
+	if err := writeReport(NewHTMLReport(dir), filepath.Join(dir, "missing", "report.html")); err == nil {
+		t.Error("expected an error writing to a missing directory")
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bufio"
+	"go/types"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/go/loader"
+	"golang.org/x/tools/go/ssa"
+)
+
+// ReadChangedFiles reads a list of changed files, one per line, as written by
+// git diff --name-only. Relative names are relative to wd. Blank lines are
+// ignored.
+func ReadChangedFiles(name, wd string) (map[string]bool, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	files := make(map[string]bool)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		file := strings.TrimSpace(scanner.Text())
+		if file == "" {
+			continue
+		}
+		file = filepath.FromSlash(file)
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(wd, file)
+		}
+		files[filepath.Clean(file)] = true
+	}
+	return files, scanner.Err()
+}
+
+// globalFiles are the names of the files which can change the findings in any
+// package: those which set the versions of dependencies, and the configuration.
+var globalFiles = []string{"go.mod", "go.sum", "go.work", "go.work.sum", "modules.txt", DefaultConfigFile, BundleFile}
+
+// ChangedPackages returns the packages in p in whose directory one of the
+// given files, keyed by absolute file name, is. This includes files which were
+// deleted or aren't Go source, such as embedded ones, since they change the
+// package too. If one of the files is a go.mod, the configuration or another
+// of the globalFiles, every package counts as changed.
+func ChangedPackages(p *loader.Program, files map[string]bool) map[*types.Package]bool {
+	dirs := make(map[string]bool, len(files))
+	all := false
+	for file := range files {
+		dirs[filepath.Dir(file)] = true
+		if contains(globalFiles, filepath.Base(file)) {
+			all = true
+		}
+	}
+
+	changed := make(map[*types.Package]bool)
+	for pkg, info := range p.AllPackages {
+		if len(info.Files) == 0 {
+			continue
+		}
+		dir := filepath.Dir(p.Fset.File(info.Files[0].Pos()).Name())
+		if all || dirs[dir] {
+			changed[pkg] = true
+		}
+	}
+	return changed
+}
+
+// AffectedMains returns those of the given main packages which are, or import
+// directly or indirectly, one of the changed packages. The others are the same
+// program they were before the change, so their findings can't have changed.
+func AffectedMains(mains []*ssa.Package, changed map[*types.Package]bool) []*ssa.Package {
+	return mainsImporting(mains, func(pkg *types.Package) bool {
+		return changed[pkg]
+	})
+}
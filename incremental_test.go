@@ -0,0 +1,101 @@
+package main
+
+import (
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"golang.org/x/tools/go/loader"
+	"golang.org/x/tools/go/ssa"
+)
+
+func TestReadChangedFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "safesql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	list := filepath.Join(dir, "changed.txt")
+	if err := ioutil.WriteFile(list, []byte("db/db.go\n\n/src/api/main.go\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := ReadChangedFiles(list, "/src")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]bool{
+		filepath.FromSlash("/src/db/db.go"):    true,
+		filepath.FromSlash("/src/api/main.go"): true,
+	}
+	if !reflect.DeepEqual(files, expected) {
+		t.Errorf("expected %v, got %v", expected, files)
+	}
+}
+
+func TestChangedPackages(t *testing.T) {
+	var c loader.Config
+	for _, path := range []string{"example.com/db", "example.com/api"} {
+		name := filepath.Join("/src", filepath.FromSlash(path), "a.go")
+		f, err := c.ParseFile(name, "package "+filepath.Base(path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.CreateFromFiles(path, f)
+	}
+	p, err := c.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		files    []string
+		expected []string
+	}{
+		"deleted file": {
+			files:    []string{"/src/example.com/db/deleted.go"},
+			expected: []string{"example.com/db"},
+		},
+		"other directory": {
+			files:    []string{"/src/example.com/docs/README.md"},
+			expected: nil,
+		},
+		"go.mod": {
+			files:    []string{"/src/example.com/go.mod"},
+			expected: []string{"example.com/api", "example.com/db"},
+		},
+	}
+	for name, test := range tests {
+		files := make(map[string]bool)
+		for _, file := range test.files {
+			files[filepath.FromSlash(file)] = true
+		}
+		var changed []string
+		for pkg := range ChangedPackages(p, files) {
+			changed = append(changed, pkg.Path())
+		}
+		sort.Strings(changed)
+		if !reflect.DeepEqual(changed, test.expected) {
+			t.Errorf("%s: expected %q, got %q", name, test.expected, changed)
+		}
+	}
+}
+
+func TestAffectedMains(t *testing.T) {
+	db := types.NewPackage("example.com/db", "db")
+	api := &ssa.Package{Pkg: types.NewPackage("example.com/cmd/api", "main")}
+	api.Pkg.SetImports([]*types.Package{db})
+	tool := &ssa.Package{Pkg: types.NewPackage("example.com/cmd/tool", "main")}
+
+	affected := AffectedMains([]*ssa.Package{api, tool}, map[*types.Package]bool{db: true})
+	if !reflect.DeepEqual(affected, []*ssa.Package{api}) {
+		t.Errorf("expected only the command which imports the changed package, got %v", affected)
+	}
+	if affected := AffectedMains([]*ssa.Package{api, tool}, nil); len(affected) != 0 {
+		t.Errorf("expected no commands to be affected without changes, got %v", affected)
+	}
+}
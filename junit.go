@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A JUnitReport accumulates findings to write as a JUnit XML test report,
+// with one test suite per package and one failed test case per finding, for
+// CI systems which only understand test reports. Suppressed findings are
+// included as skipped test cases.
+type JUnitReport struct {
+	root     string
+	packages map[string][]Result
+	stats    *Stats
+}
+
+// NewJUnitReport returns an empty report whose file names are relative to the
+// given directory.
+func NewJUnitReport(root string) *JUnitReport {
+	return &JUnitReport{root: root, packages: make(map[string][]Result)}
+}
+
+// Add adds a finding to the report.
+func (r *JUnitReport) Add(result Result) {
+	r.packages[result.Package] = append(r.packages[result.Package], result)
+}
+
+// SetStats includes statistics about the run in the report: the time it took,
+// and the rest as properties of an extra, empty test suite.
+func (r *JUnitReport) SetStats(stats *Stats) {
+	r.stats = stats
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     string           `xml:"time,attr,omitempty"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name       string           `xml:"name,attr"`
+	Tests      int              `xml:"tests,attr"`
+	Failures   int              `xml:"failures,attr"`
+	Skipped    int              `xml:"skipped,attr"`
+	Properties *junitProperties `xml:"properties,omitempty"`
+	TestCases  []junitTestCase  `xml:"testcase"`
+}
+
+type junitProperties struct {
+	Properties []junitProperty `xml:"property"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr,omitempty"`
+}
+
+// Write writes the report to w, with packages sorted by import path.
+func (r *JUnitReport) Write(w io.Writer) error {
+	pkgs := make([]string, 0, len(r.packages))
+	for pkg := range r.packages {
+		pkgs = append(pkgs, pkg)
+	}
+	sort.Strings(pkgs)
+
+	suites := junitTestSuites{Name: "safesql"}
+	for _, pkg := range pkgs {
+		suite := junitTestSuite{Name: pkg}
+		for _, res := range r.packages[pkg] {
+			name := res.Position.Filename
+			if rel, ok := relPath(r.root, name); ok {
+				name = rel
+			}
+			location := fmt.Sprintf("%s:%d:%d", name, res.Position.Line, res.Position.Column)
+			tc := junitTestCase{Name: res.Rule + " " + location, ClassName: pkg}
+			if res.Suppressed {
+				tc.Skipped = &junitSkipped{Message: res.Justification}
+				suite.Skipped++
+			} else {
+				text := []string{location + ": " + res.Message}
+				if res.Severity != 0 {
+					text = append(text, fmt.Sprintf("severity: %s, confidence: %s", res.Severity, res.Confidence))
+				}
+				for _, step := range res.Steps() {
+					text = append(text, fmt.Sprintf("%s at %s", step.Message, step.Position))
+				}
+				tc.Failure = &junitFailure{Message: res.Message, Type: res.Rule, Text: strings.Join(text, "\n")}
+				suite.Failures++
+			}
+			suite.Tests++
+			suite.TestCases = append(suite.TestCases, tc)
+		}
+		suites.Tests += suite.Tests
+		suites.Failures += suite.Failures
+		suites.Skipped += suite.Skipped
+		suites.Suites = append(suites.Suites, suite)
+	}
+
+	if r.stats != nil {
+		suites.Time = fmt.Sprintf("%.3f", r.stats.Duration.Seconds())
+		summary := junitTestSuite{Name: "safesql", Properties: &junitProperties{}}
+		for _, m := range r.stats.Metrics() {
+			summary.Properties.Properties = append(summary.Properties.Properties, junitProperty{m.Name, strconv.FormatFloat(m.Value, 'f', -1, 64)})
+		}
+		suites.Suites = append(suites.Suites, summary)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
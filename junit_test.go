@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"go/token"
+	"testing"
+)
+
+func TestJUnitReport(t *testing.T) {
+	r := NewJUnitReport("/src/app")
+	r.Add(Result{
+		Rule:     RuleNonConstQuery,
+		Position: token.Position{Filename: "/src/app/db/db.go", Line: 14, Column: 19},
+		Package:  "example.com/app/db",
+		Message:  "Query is not a compile-time constant",
+		Flow:     []FlowStep{{token.Position{Filename: "/src/app/db/db.go", Line: 12, Column: 2}, "non-constant part: parameter name"}},
+	})
+	r.Add(Result{
+		Rule:          RuleNonConstQuery,
+		Position:      token.Position{Filename: "/src/app/db/db.go", Line: 20, Column: 19},
+		Package:       "example.com/app/db",
+		Suppressed:    true,
+		Justification: "legacy",
+	})
+	r.Add(Result{
+		Rule:     RuleNonConstQuery,
+		Position: token.Position{Filename: "/src/app/api/api.go", Line: 3, Column: 1},
+		Package:  "example.com/app/api",
+		Message:  "Query is not a compile-time constant",
+	})
+
+	var buf bytes.Buffer
+	if err := r.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	expected := `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites name="safesql" tests="3" failures="2" skipped="1">
+  <testsuite name="example.com/app/api" tests="1" failures="1" skipped="0">
+    <testcase name="SAFESQL001 api/api.go:3:1" classname="example.com/app/api">
+      <failure message="Query is not a compile-time constant" type="SAFESQL001">api/api.go:3:1: Query is not a compile-time constant</failure>
+    </testcase>
+  </testsuite>
+  <testsuite name="example.com/app/db" tests="2" failures="1" skipped="1">
+    <testcase name="SAFESQL001 db/db.go:14:19" classname="example.com/app/db">
+      <failure message="Query is not a compile-time constant" type="SAFESQL001">db/db.go:14:19: Query is not a compile-time constant&#xA;non-constant part: parameter name at /src/app/db/db.go:12:2</failure>
+    </testcase>
+    <testcase name="SAFESQL001 db/db.go:20:19" classname="example.com/app/db">
+      <skipped message="legacy"></skipped>
+    </testcase>
+  </testsuite>
+</testsuites>
+`
+	if buf.String() != expected {
+		t.Errorf("Write wrote:\n%s\nexpected:\n%s", buf.String(), expected)
+	}
+}
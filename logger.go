@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// A LogLevel is how much a Logger writes.
+type LogLevel int
+
+const (
+	// LogSilent writes nothing.
+	LogSilent LogLevel = iota
+	// LogVerbose writes what safesql is doing, for -v.
+	LogVerbose
+	// LogDebug also writes the intermediate results of the analysis,
+	// e.g. the calls in the call graph, for -debug.
+	LogDebug
+)
+
+// A Logger writes messages about the progress of the analysis, as opposed to
+// its results, if they are of at most its level. A nil Logger writes nothing.
+type Logger struct {
+	w     io.Writer
+	level LogLevel
+}
+
+// NewLogger returns a Logger writing messages of at most the given level to w.
+func NewLogger(w io.Writer, level LogLevel) *Logger {
+	return &Logger{w: w, level: level}
+}
+
+// Enabled reports whether messages of the given level are written, for callers
+// which need to do some work to produce them.
+func (l *Logger) Enabled(level LogLevel) bool {
+	return l != nil && l.level >= level
+}
+
+// Verbosef writes a message for -v.
+func (l *Logger) Verbosef(format string, args ...interface{}) {
+	if l.Enabled(LogVerbose) {
+		fmt.Fprintf(l.w, format+"\n", args...)
+	}
+}
+
+// Debugf writes a message for -debug, prefixed with "debug: ".
+func (l *Logger) Debugf(format string, args ...interface{}) {
+	if l.Enabled(LogDebug) {
+		fmt.Fprintf(l.w, "debug: "+format+"\n", args...)
+	}
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestLogger(t *testing.T) {
+	for level, expected := range map[LogLevel]string{
+		LogSilent:  "",
+		LogVerbose: "found 1\n",
+		LogDebug:   "found 1\ndebug: checked 2\n",
+	} {
+		var buf bytes.Buffer
+		l := NewLogger(&buf, level)
+		l.Verbosef("found %d", 1)
+		l.Debugf("checked %d", 2)
+		if buf.String() != expected {
+			t.Errorf("level %d: expected %q, got %q", level, expected, buf.String())
+		}
+	}
+}
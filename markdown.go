@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/token"
+	"html"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// markdownMaxSize is how long a Markdown report may be. GitHub rejects
+// comments longer than 65536 characters, which leaves room for a bot to add
+// its own text.
+const markdownMaxSize = 60000
+
+// A MarkdownReport accumulates findings to write as a Markdown summary sized
+// for a single pull request comment: a table counting the findings by rule and
+// severity, followed by a collapsible section for each finding, as many as
+// fit. Only the findings which fail the run are included.
+type MarkdownReport struct {
+	root    string
+	results []Result
+	// SourceURL is the address of the files of the commit checked, e.g.
+	// https://github.com/org/repo/blob/<commit>, which findings link to.
+	// Findings aren't linked if it's empty.
+	SourceURL string
+	// Context is how many lines of source to show before and after each
+	// finding.
+	Context int
+
+	sources sourceFiles
+}
+
+// NewMarkdownReport returns an empty report whose file names are relative to
+// the given directory, which should be the root of the repository. It links
+// findings to the commit a GitHub Actions workflow runs on, if it's run by
+// one.
+func NewMarkdownReport(root string) *MarkdownReport {
+	return &MarkdownReport{root: root, SourceURL: githubSourceURL(), Context: 2, sources: make(sourceFiles)}
+}
+
+// githubSourceURL returns the address of the files of the commit the GitHub
+// Actions workflow running safesql was triggered by, or "" if it isn't run by
+// one.
+func githubSourceURL() string {
+	server, repo, sha := os.Getenv("GITHUB_SERVER_URL"), os.Getenv("GITHUB_REPOSITORY"), os.Getenv("GITHUB_SHA")
+	if server == "" || repo == "" || sha == "" {
+		return ""
+	}
+	return server + "/" + repo + "/blob/" + sha
+}
+
+// Add adds a finding to the report.
+func (r *MarkdownReport) Add(result Result) {
+	if !result.Suppressed {
+		r.results = append(r.results, result)
+	}
+}
+
+// Write writes the report to w.
+func (r *MarkdownReport) Write(w io.Writer) error {
+	var buf bytes.Buffer
+	switch len(r.results) {
+	case 0:
+		fmt.Fprintln(&buf, "### SafeSQL found no problems")
+	case 1:
+		fmt.Fprintln(&buf, "### SafeSQL found 1 problem")
+	default:
+		fmt.Fprintf(&buf, "### SafeSQL found %d problems\n", len(r.results))
+	}
+	if len(r.results) > 0 {
+		r.writeSummary(&buf)
+	}
+
+	for i, res := range r.results {
+		// Leave room for the line saying how many more findings there are.
+		details := r.details(res)
+		if buf.Len()+len(details) > markdownMaxSize-100 {
+			fmt.Fprintf(&buf, "\n%d more findings aren't shown. Run safesql for the full list.\n", len(r.results)-i)
+			break
+		}
+		buf.WriteString(details)
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// writeSummary writes the table of the number of findings of each rule and
+// severity.
+func (r *MarkdownReport) writeSummary(w io.Writer) {
+	counts := make(map[string]map[Level]int)
+	for _, res := range r.results {
+		if counts[res.Rule] == nil {
+			counts[res.Rule] = make(map[Level]int)
+		}
+		counts[res.Rule][res.Severity]++
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "| Rule | High | Medium | Low | Total |")
+	fmt.Fprintln(w, "|------|-----:|-------:|----:|------:|")
+	for _, rule := range Rules {
+		c, ok := counts[rule.ID]
+		if !ok {
+			continue
+		}
+		total := 0
+		for _, n := range c {
+			total += n
+		}
+		fmt.Fprintf(w, "| [%s](%s) %s | %d | %d | %d | %d |\n",
+			rule.ID, rule.HelpURI(), rule.Name, c[LevelHigh], c[LevelMedium], c[LevelLow], total)
+	}
+	fmt.Fprintln(w)
+}
+
+// details returns the collapsible section about a finding, with the source
+// around it and the flow of data to it.
+func (r *MarkdownReport) details(res Result) string {
+	var b strings.Builder
+	summary := res.Rule
+	if res.Severity != 0 {
+		summary += " (" + res.Severity.String() + ")"
+	}
+	// Markdown isn't rendered in HTML tags.
+	fmt.Fprintf(&b, "<details>\n<summary>%s %s: %s</summary>\n\n", summary,
+		html.EscapeString(r.name(res.Position)), html.EscapeString(res.Message))
+	b.WriteString(r.link(res.Position))
+	if res.Severity != 0 {
+		fmt.Fprintf(&b, " · %s severity, %s confidence", res.Severity, res.Confidence)
+	}
+	b.WriteString("\n")
+
+	lines := r.sources.lines(res.Position.Filename)
+	if res.Position.Line >= 1 && res.Position.Line <= len(lines) {
+		first, last := res.Position.Line-r.Context, res.Position.Line+r.Context
+		if first < 1 {
+			first = 1
+		}
+		if last > len(lines) {
+			last = len(lines)
+		}
+		b.WriteString("\n```go\n")
+		for i := first; i <= last; i++ {
+			b.WriteString(lines[i-1] + "\n")
+		}
+		b.WriteString("```\n")
+	}
+
+	if steps := res.Steps(); len(steps) > 0 {
+		b.WriteString("\n")
+		for _, step := range steps {
+			fmt.Fprintf(&b, "- %s: %s\n", r.link(step.Position), markdownEscape(step.Message))
+		}
+	}
+	if res.Fix != nil {
+		fmt.Fprintf(&b, "\nFix: %s\n", markdownEscape(res.Fix.Message))
+	}
+	b.WriteString("</details>\n")
+	return b.String()
+}
+
+// name returns the file name and line of pos, relative to the report's root.
+func (r *MarkdownReport) name(pos token.Position) string {
+	name := pos.Filename
+	if rel, ok := relPath(r.root, name); ok {
+		name = rel
+	}
+	return fmt.Sprintf("%s:%d", filepath.ToSlash(name), pos.Line)
+}
+
+// link returns a link to the line of pos on GitHub, or just its name if the
+// report has no SourceURL or pos is outside its root.
+func (r *MarkdownReport) link(pos token.Position) string {
+	rel, ok := relPath(r.root, pos.Filename)
+	if r.SourceURL == "" || !ok {
+		return "`" + r.name(pos) + "`"
+	}
+	return fmt.Sprintf("[`%s`](%s/%s#L%d)", r.name(pos), strings.TrimSuffix(r.SourceURL, "/"), rel, pos.Line)
+}
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "[", `\[`, "]", `\]`,
+	"<", "&lt;", ">", "&gt;", "|", `\|`, "\n", " ",
+)
+
+// markdownEscape escapes s to be shown as it is in Markdown text, including
+// table cells.
+func markdownEscape(s string) string {
+	return markdownEscaper.Replace(s)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMarkdownReport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "safesql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "db.go")
+	if err := ioutil.WriteFile(filename, []byte(prettySrc), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewMarkdownReport(dir)
+	r.SourceURL = "https://github.com/org/repo/blob/abc123/"
+	r.Context = 1
+	r.Add(Result{
+		Rule:       RuleNonConstQuery,
+		Position:   token.Position{Filename: filename, Line: 5, Column: 23},
+		Message:    "Query passed to (*database/sql.DB).Query is not a compile-time constant",
+		Severity:   LevelHigh,
+		Confidence: LevelMedium,
+		Flow:       []FlowStep{{token.Position{Filename: filename, Line: 4, Column: 7}, "non-constant part: parameter name"}},
+	})
+	r.Add(Result{
+		Rule:       RuleNonConstQuery,
+		Position:   token.Position{Filename: filename, Line: 4, Column: 2},
+		Suppressed: true,
+	})
+
+	var buf bytes.Buffer
+	if err := r.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	expected := "### SafeSQL found 1 problem\n" +
+		"\n" +
+		"| Rule | High | Medium | Low | Total |\n" +
+		"|------|-----:|-------:|----:|------:|\n" +
+		"| [SAFESQL001](https://github.com/stripe/safesql#safesql001) NonConstantQuery | 1 | 0 | 0 | 1 |\n" +
+		"\n" +
+		"<details>\n" +
+		"<summary>SAFESQL001 (high) db.go:5: Query passed to (*database/sql.DB).Query is not a compile-time constant</summary>\n" +
+		"\n" +
+		"[`db.go:5`](https://github.com/org/repo/blob/abc123/db.go#L5) · high severity, medium confidence\n" +
+		"\n" +
+		"```go\n" +
+		"\tq := \"SELECT * FROM \" + name\n" +
+		"\trows, err := db.Query(q)\n" +
+		"}\n" +
+		"```\n" +
+		"\n" +
+		"- [`db.go:4`](https://github.com/org/repo/blob/abc123/db.go#L4): non-constant part: parameter name\n" +
+		"</details>\n"
+	if buf.String() != expected {
+		t.Errorf("Write wrote:\n%s\nexpected:\n%s", buf.String(), expected)
+	}
+}
+
+func TestMarkdownReportSize(t *testing.T) {
+	r := NewMarkdownReport("/src/app")
+	for i := 0; i < 2000; i++ {
+		r.Add(Result{
+			Rule:     RuleNonConstQuery,
+			Position: token.Position{Filename: "/src/app/db/db.go", Line: i + 1, Column: 1},
+			Message:  "Query is not a compile-time constant",
+		})
+	}
+	var buf bytes.Buffer
+	if err := r.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() > markdownMaxSize {
+		t.Errorf("report is %d bytes long, expected at most %d", buf.Len(), markdownMaxSize)
+	}
+	if !strings.Contains(buf.String(), "more findings aren't shown") {
+		t.Errorf("report doesn't say findings were left out")
+	}
+}
+
+func TestMarkdownEscape(t *testing.T) {
+	if s := markdownEscape("a|b *c* <d>"); s != `a\|b \*c\* &lt;d&gt;` {
+		t.Errorf("markdownEscape returned %q", s)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// metricsFile is the JSON form of the statistics written by WriteMetrics, in
+// which Counts are the numbers of findings by rule, severity and package.
+type metricsFile struct {
+	Packages       int             `json:"packages"`
+	Sinks          int             `json:"sinks"`
+	CallSites      int             `json:"callSites"`
+	ConstCallSites int             `json:"constCallSites"`
+	Findings       int             `json:"findings"`
+	Suppressed     int             `json:"suppressed"`
+	Seconds        float64         `json:"seconds"`
+	Counts         []metricFinding `json:"counts"`
+}
+
+type metricFinding struct {
+	Rule     string `json:"rule"`
+	Severity string `json:"severity"`
+	Package  string `json:"package"`
+	Count    int    `json:"count"`
+}
+
+// findingCounts returns the counts of the findings by rule, severity and
+// package, sorted by rule, then from high to low severity, then by package.
+func (s *Stats) findingCounts() []metricFinding {
+	keys := make([]findingKey, 0, len(s.findings))
+	for key := range s.findings {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.Rule != b.Rule {
+			return a.Rule < b.Rule
+		}
+		if a.Severity != b.Severity {
+			return a.Severity > b.Severity
+		}
+		return a.Package < b.Package
+	})
+	counts := make([]metricFinding, 0, len(keys))
+	for _, key := range keys {
+		counts = append(counts, metricFinding{key.Rule, key.Severity.String(), key.Package, s.findings[key]})
+	}
+	return counts
+}
+
+// WriteMetrics writes the statistics to the file at path, for dashboards to
+// collect: in the Prometheus text format, e.g. for node_exporter's textfile
+// collector, if its name ends in ".prom", and as JSON otherwise. Unlike the
+// summary, findings are also counted by package.
+func WriteMetrics(path string, s *Stats) error {
+	var data []byte
+	if filepath.Ext(path) == ".prom" {
+		var buf bytes.Buffer
+		gauge := func(name, help string, value float64) {
+			fmt.Fprintf(&buf, "# HELP safesql_%s %s\n# TYPE safesql_%s gauge\nsafesql_%s %g\n", name, help, name, name, value)
+		}
+		gauge("packages", "Packages analyzed, including dependencies.", float64(s.Packages))
+		gauge("sinks", "Query methods found in the database packages.", float64(s.Sinks))
+		gauge("call_sites", "Calls to query methods checked.", float64(s.CallSites))
+		gauge("const_call_sites", "Calls to query methods passed constant queries.", float64(s.ConstCallSites))
+		gauge("suppressed", "Findings suppressed.", float64(s.Suppressed))
+		gauge("duration_seconds", "How long the run took.", s.Duration.Seconds())
+		fmt.Fprintf(&buf, "# HELP safesql_findings Findings which fail the run.\n# TYPE safesql_findings gauge\n")
+		for _, f := range s.findingCounts() {
+			fmt.Fprintf(&buf, "safesql_findings{rule=%s,severity=%s,package=%s} %d\n",
+				promLabel(f.Rule), promLabel(f.Severity), promLabel(f.Package), f.Count)
+		}
+		data = buf.Bytes()
+	} else {
+		var err error
+		data, err = json.MarshalIndent(metricsFile{
+			Packages:       s.Packages,
+			Sinks:          s.Sinks,
+			CallSites:      s.CallSites,
+			ConstCallSites: s.ConstCallSites,
+			Findings:       s.Findings(),
+			Suppressed:     s.Suppressed,
+			Seconds:        s.Duration.Seconds(),
+			Counts:         s.findingCounts(),
+		}, "", "  ")
+		if err != nil {
+			return err
+		}
+		data = append(data, '\n')
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// promLabel quotes a Prometheus label value.
+func promLabel(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"go/token"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/ssa"
+)
+
+// migrationPackages are the import paths of migration tools, and of the
+// packages below them such as their database drivers, which read migrations
+// and run them as they are.
+var migrationPackages = []string{
+	"github.com/golang-migrate/migrate",
+	"github.com/pressly/goose",
+	"ariga.io/atlas",
+}
+
+// isMigrationPackage reports whether the import path is that of one of the
+// migrationPackages, or of a package below one, including its major versions.
+func isMigrationPackage(path string) bool {
+	for _, m := range migrationPackages {
+		if path == m || strings.HasPrefix(path, m+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// isEmbedded reports whether v holds the contents of a file embedded in the
+// binary: a []byte variable with a //go:embed directive, which the linker
+// fills in so nothing is ever stored in it, or a file read from an embed.FS,
+// directly or through fs.ReadFile.
+func (c *ConstChecker) isEmbedded(v ssa.Value) bool {
+	switch v := v.(type) {
+	case *ssa.UnOp:
+		global, ok := v.X.(*ssa.Global)
+		if !ok || v.Op != token.MUL {
+			return false
+		}
+		if s, ok := global.Type().(*types.Pointer).Elem().Underlying().(*types.Slice); !ok || !types.Identical(s.Elem(), types.Typ[types.Byte]) {
+			return false
+		}
+		stored, ok := c.Globals.Stored(global)
+		return ok && len(stored) == 0
+	case *ssa.Extract:
+		call, ok := v.Tuple.(*ssa.Call)
+		if !ok || v.Index != 0 {
+			return false
+		}
+		callee := call.Call.StaticCallee()
+		if callee == nil || callee.Pkg == nil || callee.Name() != "ReadFile" {
+			return false
+		}
+		switch callee.Pkg.Pkg.Path() {
+		case "embed":
+			return true
+		case "io/fs":
+			inter, ok := call.Call.Args[0].(*ssa.MakeInterface)
+			return ok && isEmbedFS(inter.X.Type())
+		}
+	}
+	return false
+}
+
+// isEmbedFS reports whether t is embed.FS or a pointer to it.
+func isEmbedFS(t types.Type) bool {
+	named, ok := deref(t).(*types.Named)
+	return ok && named.Obj().Pkg() != nil && named.Obj().Pkg().Path() == "embed" && named.Obj().Name() == "FS"
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"go/token"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// PathRoot returns the directory file names are shown relative to in the
+// given -path-mode, or "" if they are shown as absolute paths.
+func PathRoot(mode, wd string) (string, error) {
+	switch mode {
+	case "absolute":
+		return "", nil
+	case "relative":
+		return wd, nil
+	case "module":
+		return ModuleRoot(wd)
+	}
+	return "", fmt.Errorf("unknown path mode %q, expected relative, absolute or module", mode)
+}
+
+// ModuleRoot returns the root of the module containing dir, i.e. the closest
+// directory above it with a go.mod file.
+func ModuleRoot(dir string) (string, error) {
+	for d := dir; ; d = filepath.Dir(d) {
+		if _, err := os.Stat(filepath.Join(d, "go.mod")); err == nil {
+			return d, nil
+		}
+		if filepath.Dir(d) == d {
+			return "", fmt.Errorf("no go.mod in %s or any directory above it", dir)
+		}
+	}
+}
+
+// ShowPosition returns pos with its file name relative to root, if it is below
+// it.
+func ShowPosition(root string, pos token.Position) token.Position {
+	if rel, ok := relPath(root, pos.Filename); ok {
+		pos.Filename = filepath.FromSlash(rel)
+	}
+	return pos
+}
+
+// fileKey returns the key to look up the given file name with, so that names
+// which refer to the same file on this operating system have the same key.
+func fileKey(name string) string {
+	return pathKey(runtime.GOOS, name)
+}
+
+// pathKey returns the key of a file name on the given operating system. On
+// Windows, where file names are case-insensitive and both slashes separate
+// directories, this is the cleaned name in lower case with forward slashes.
+// Elsewhere, it's just the cleaned name.
+func pathKey(goos, name string) string {
+	if goos == "windows" {
+		return strings.ToLower(path.Clean(strings.Replace(name, `\`, "/", -1)))
+	}
+	return filepath.Clean(name)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPathRoot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "safesql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	pkg := filepath.Join(dir, "internal", "db")
+	if err := os.MkdirAll(pkg, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/app\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]string{"absolute": "", "relative": pkg, "module": dir}
+	for mode, expected := range tests {
+		if root, err := PathRoot(mode, pkg); err != nil || root != expected {
+			t.Errorf("PathRoot(%q) = %q, %v, expected %q", mode, root, err, expected)
+		}
+	}
+	if _, err := PathRoot("module", os.TempDir()); err == nil {
+		t.Error("PathRoot found a module outside of one")
+	}
+	if _, err := PathRoot("home", pkg); err == nil {
+		t.Error("PathRoot accepted an unknown mode")
+	}
+
+	pos := token.Position{Filename: filepath.Join(pkg, "db.go"), Line: 3, Column: 2}
+	if shown := ShowPosition(dir, pos).String(); shown != filepath.Join("internal", "db", "db.go")+":3:2" {
+		t.Errorf("ShowPosition(module) = %s", shown)
+	}
+	if shown := ShowPosition("", pos); shown != pos {
+		t.Errorf("ShowPosition(absolute) = %s", shown)
+	}
+	if shown := ShowPosition(filepath.Join(dir, "cmd"), pos); shown != pos {
+		t.Errorf("ShowPosition(sibling) = %s", shown)
+	}
+}
+
+func TestPathKey(t *testing.T) {
+	tests := []struct {
+		goos, a, b string
+		same       bool
+	}{
+		{"windows", `C:\Users\alice\db\db.go`, `c:/users/Alice/db/db.go`, true},
+		{"windows", `C:\Users\alice\db\..\db\db.go`, `C:\Users\alice\db\db.go`, true},
+		{"windows", `C:\Users\alice\db\db.go`, `C:\Users\alice\db\other.go`, false},
+		{"linux", "/home/alice/db/./db.go", "/home/alice/db/db.go", true},
+		{"linux", "/home/alice/db/db.go", "/home/Alice/db/db.go", false},
+	}
+	for _, test := range tests {
+		if same := pathKey(test.goos, test.a) == pathKey(test.goos, test.b); same != test.same {
+			t.Errorf("%s: expected %q and %q to be the same file: %v, got %v", test.goos, test.a, test.b, test.same, same)
+		}
+	}
+}
@@ -0,0 +1,328 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/scanner"
+	"go/types"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/buildutil"
+	"golang.org/x/tools/go/loader"
+)
+
+// ExpandPatterns returns the packages matched by the given patterns. A pattern
+// is an import path or a directory relative to wd (starting with "./" or
+// "../"), either of which can end in "/..." to match the packages below it
+// too. Below such a pattern, directories which match any of the exclude globs,
+// given as slash-separated paths relative to wd in which "**" matches any
+// number of directories, are left out along with everything below them, as are
+// testdata and vendor directories and those whose names start with "." or "_".
+func ExpandPatterns(ctxt *build.Context, wd string, patterns, exclude []string) []string {
+	seen := make(map[string]bool)
+	pkgs := make([]string, 0, len(patterns))
+	add := func(pkg string) {
+		if !seen[pkg] {
+			seen[pkg] = true
+			pkgs = append(pkgs, pkg)
+		}
+	}
+	excluded := func(dir string) bool {
+		rel, err := filepath.Rel(wd, dir)
+		if err != nil {
+			return false
+		}
+		for _, glob := range exclude {
+			if matchGlob(glob, filepath.ToSlash(rel)) {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, pattern := range patterns {
+		root := strings.TrimSuffix(pattern, "/...")
+		if root == pattern {
+			add(pattern)
+			continue
+		}
+		if !build.IsLocalImport(root) {
+			matched := make([]string, 0)
+			for path := range buildutil.ExpandPatterns(ctxt, []string{pattern}) {
+				if bp, err := ctxt.Import(path, wd, build.FindOnly); err != nil || !excluded(bp.Dir) {
+					matched = append(matched, path)
+				}
+			}
+			sort.Strings(matched)
+			for _, path := range matched {
+				add(path)
+			}
+			continue
+		}
+
+		filepath.Walk(filepath.Join(wd, filepath.FromSlash(root)), func(path string, fi os.FileInfo, err error) error {
+			if err != nil || !fi.IsDir() {
+				return nil
+			}
+			name := fi.Name()
+			if path != filepath.Join(wd, filepath.FromSlash(root)) &&
+				(name == "testdata" || name == "vendor" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")) {
+				return filepath.SkipDir
+			}
+			if excluded(path) {
+				return filepath.SkipDir
+			}
+			if hasGoFiles(ctxt, path) {
+				rel, _ := filepath.Rel(wd, path)
+				pkg := filepath.ToSlash(rel)
+				if pkg != "." && !strings.HasPrefix(pkg, "../") {
+					pkg = "./" + pkg
+				}
+				add(pkg)
+			}
+			return nil
+		})
+	}
+	return pkgs
+}
+
+// hasGoFiles reports whether dir has Go files which ctxt builds, so that, as
+// with go build, a directory whose files are all excluded by build constraints,
+// e.g. a generator run with go run, isn't a package.
+func hasGoFiles(ctxt *build.Context, dir string) bool {
+	_, err := ctxt.ImportDir(dir, 0)
+	_, noGo := err.(*build.NoGoError)
+	return !noGo
+}
+
+// A PackageFailure is a package which couldn't be analyzed, and why, so that
+// the run can go on without it and summarize what it left out.
+type PackageFailure struct {
+	Package string
+	Err     error
+}
+
+// WriteFailures summarizes the packages which couldn't be analyzed, at the end
+// of the output, so that the findings missing from them aren't overlooked.
+func WriteFailures(w io.Writer, failures []PackageFailure) {
+	if len(failures) == 0 {
+		return
+	}
+	if len(failures) == 1 {
+		fmt.Fprintln(w, "1 package couldn't be analyzed, so any findings in it are missing:")
+	} else {
+		fmt.Fprintf(w, "%d packages couldn't be analyzed, so any findings in them are missing:\n", len(failures))
+	}
+	for _, f := range failures {
+		fmt.Fprintf(w, "- %s: %v\n", f.Package, f.Err)
+	}
+}
+
+// LoadPackages loads the given packages with the given configuration, to which
+// no packages must have been added. Packages which can't be loaded, e.g.
+// because they or the packages they import don't type check, are left out
+// with a warning written to warn rather than failing the whole run, and
+// returned as failures. It's an error if none of them can be loaded.
+//
+// A given package whose own files have errors is analyzed partially, without
+// those files, if the rest of its files type check on their own, so that a
+// broken file doesn't hide the findings in the others. Such packages are
+// created from their files rather than imported, so they're the program's
+// Created packages, and other packages importing them are still left out.
+//
+// If tests is true, the packages are loaded with their tests, as by
+// ImportWithTests, and their external test packages are also Created
+// packages. A package whose external tests don't type check is loaded
+// without its tests, with a warning.
+func LoadPackages(c loader.Config, pkgs []string, tests bool, warn io.Writer) (*loader.Program, []PackageFailure, error) {
+	ctxt := c.Build
+	if ctxt == nil {
+		ctxt = &build.Default
+	}
+	wd := c.Cwd
+	if wd == "" {
+		wd, _ = os.Getwd()
+	}
+	find := c.FindPackage
+	if find == nil {
+		find = (*build.Context).Import
+	}
+
+	// The loader identifies packages by import path, which isn't necessarily
+	// how they were given.
+	args := make(map[string]string)
+	loadable := make([]string, 0, len(pkgs))
+	var failures []PackageFailure
+	fail := func(pkg string, err error) {
+		fmt.Fprintf(warn, "skipping %s: %v\n", pkg, err)
+		failures = append(failures, PackageFailure{pkg, err})
+	}
+	for _, pkg := range pkgs {
+		bp, err := find(ctxt, pkg, wd, 0)
+		if err != nil {
+			fail(pkg, err)
+			continue
+		}
+		args[bp.ImportPath] = pkg
+		loadable = append(loadable, pkg)
+	}
+	// partial holds the files of the packages to analyze partially, by
+	// import path.
+	partial := make(map[string][]string)
+	// untested holds the packages to load without their tests.
+	untested := make(map[string]bool)
+
+	for len(loadable) > 0 || len(partial) > 0 {
+		conf := c
+		conf.AllowErrors = true
+		conf.TypeChecker.Error = func(error) {}
+		for _, pkg := range loadable {
+			if tests && !untested[pkg] {
+				conf.ImportWithTests(pkg)
+			} else {
+				conf.Import(pkg)
+			}
+		}
+		paths := make([]string, 0, len(partial))
+		for path := range partial {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+		for _, path := range paths {
+			files := make([]*ast.File, 0, len(partial[path]))
+			for _, filename := range partial[path] {
+				if f, err := conf.ParseFile(filename, nil); err == nil {
+					files = append(files, f)
+				}
+			}
+			conf.CreateFromFiles(path, files...)
+		}
+		p, err := conf.Load()
+		if err != nil {
+			return nil, failures, err
+		}
+
+		skip := make(map[string]bool)
+		retry := false
+		for path, info := range p.Imported {
+			if info.TransitivelyErrorFree {
+				continue
+			}
+			arg, ok := args[path]
+			if !ok {
+				arg = path
+			}
+			if files, broken := partialFiles(p, info); len(files) > 0 && ok {
+				fmt.Fprintf(warn, "analyzing %s partially, without %s, which has errors: %v\n", arg, strings.Join(broken, ", "), info.Errors[0])
+				partial[path] = files
+				skip[arg] = true
+				continue
+			}
+			err := packageError(p, info.Pkg, make(map[*types.Package]bool))
+			if err == nil {
+				err = fmt.Errorf("it imports a package with errors")
+			}
+			fail(arg, err)
+			skip[arg] = true
+		}
+		for _, info := range p.Created {
+			if _, ok := partial[info.Pkg.Path()]; !ok {
+				// The external tests of a package.
+				if err := packageError(p, info.Pkg, make(map[*types.Package]bool)); err != nil {
+					arg := args[strings.TrimSuffix(info.Pkg.Path(), "_test")]
+					fmt.Fprintf(warn, "loading %s without its tests, which have errors: %v\n", arg, err)
+					untested[arg] = true
+					retry = true
+				}
+				continue
+			}
+			if err := packageError(p, info.Pkg, make(map[*types.Package]bool)); err != nil {
+				fail(args[info.Pkg.Path()], fmt.Errorf("its files without errors don't type check on their own: %v", err))
+				delete(partial, info.Pkg.Path())
+				skip[args[info.Pkg.Path()]] = true
+			}
+		}
+		if len(skip) == 0 && !retry {
+			sort.Slice(failures, func(i, j int) bool { return failures[i].Package < failures[j].Package })
+			return p, failures, nil
+		}
+		remaining := make([]string, 0, len(loadable))
+		for _, pkg := range loadable {
+			if !skip[pkg] {
+				remaining = append(remaining, pkg)
+			}
+		}
+		if len(remaining) == len(loadable) && len(p.Created) == 0 {
+			return nil, failures, fmt.Errorf("couldn't tell which packages have errors")
+		}
+		loadable = remaining
+	}
+	return nil, failures, fmt.Errorf("none of the packages %v could be loaded", pkgs)
+}
+
+// partialFiles returns the files of the package which have none of its
+// errors, and the base names of those which do. It returns no files if some of
+// the errors aren't in any file, or the package's files aren't all Go source
+// the loader parsed itself, e.g. because it uses cgo.
+func partialFiles(p *loader.Program, info *loader.PackageInfo) (files, broken []string) {
+	if len(info.Errors) == 0 {
+		return nil, nil
+	}
+	bad := make(map[string]bool)
+	for _, err := range info.Errors {
+		filename := errorFile(err)
+		if filename == "" {
+			return nil, nil
+		}
+		bad[filename] = true
+	}
+	for _, f := range info.Files {
+		filename := p.Fset.File(f.Pos()).Name()
+		if bad[filename] {
+			broken = append(broken, filepath.Base(filename))
+		} else {
+			files = append(files, filename)
+		}
+	}
+	if len(broken) < len(bad) {
+		return nil, nil
+	}
+	return files, broken
+}
+
+// errorFile returns the name of the file an error from the loader is in, or ""
+// if it isn't in one.
+func errorFile(err error) string {
+	switch err := err.(type) {
+	case types.Error:
+		return err.Fset.Position(err.Pos).Filename
+	case scanner.ErrorList:
+		if len(err) > 0 {
+			return err[0].Pos.Filename
+		}
+	case *scanner.Error:
+		return err.Pos.Filename
+	}
+	return ""
+}
+
+func packageError(p *loader.Program, pkg *types.Package, seen map[*types.Package]bool) error {
+	if seen[pkg] {
+		return nil
+	}
+	seen[pkg] = true
+	if info := p.AllPackages[pkg]; info != nil && len(info.Errors) > 0 {
+		return info.Errors[0]
+	}
+	for _, imp := range pkg.Imports() {
+		if err := packageError(p, imp, seen); err != nil {
+			return fmt.Errorf("in imported package %s: %v", imp.Path(), err)
+		}
+	}
+	return nil
+}
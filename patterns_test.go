@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"go/build"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/loader"
+)
+
+func TestExpandPatterns(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkgs := ExpandPatterns(&build.Default, wd, []string{"./testdata/...", "./testdata/type_error", "fmt"}, []string{"testdata/*ignored*", "testdata/multi*", "testdata/src"})
+	expected := []string{"./testdata/type_error", "./testdata/type_error_partial", "fmt"}
+	if !reflect.DeepEqual(pkgs, expected) {
+		t.Errorf("expected %v, got %v", expected, pkgs)
+	}
+}
+
+func TestLoadPackages(t *testing.T) {
+	var warn bytes.Buffer
+	c := loader.Config{FindPackage: FindPackage}
+	p, failures, err := LoadPackages(c, []string{"./testdata/type_error", "./testdata/does_not_exist", "./testdata/single_ignored"}, false, &warn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.InitialPackages()) != 1 || p.InitialPackages()[0].Pkg.Name() != "main" || len(p.InitialPackages()[0].Errors) != 0 {
+		t.Errorf("expected only the package without errors to be loaded, got %v", p.InitialPackages())
+	}
+	for _, skipped := range []string{"skipping ./testdata/type_error", "skipping ./testdata/does_not_exist"} {
+		if !strings.Contains(warn.String(), skipped) {
+			t.Errorf("expected a warning %q, got %q", skipped, warn.String())
+		}
+	}
+	if len(failures) != 2 || failures[0].Package != "./testdata/does_not_exist" || failures[1].Package != "./testdata/type_error" {
+		t.Errorf("expected the skipped packages to be returned as failures, got %v", failures)
+	}
+
+	if _, _, err := LoadPackages(c, []string{"./testdata/type_error"}, false, &warn); err == nil {
+		t.Error("expected an error if no packages can be loaded")
+	}
+}
+
+func TestLoadPackagesPartially(t *testing.T) {
+	var warn bytes.Buffer
+	c := loader.Config{FindPackage: FindPackage}
+	p, failures, err := LoadPackages(c, []string{"./testdata/type_error_partial"}, false, &warn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Created) != 1 || len(p.Created[0].Files) != 1 || len(p.Created[0].Errors) != 0 {
+		t.Fatalf("expected the package to be created from its file without errors, got %v", p.Created)
+	}
+	if name := p.Fset.File(p.Created[0].Files[0].Pos()).Name(); filepath.Base(name) != "main.go" {
+		t.Errorf("expected main.go to be analyzed, got %s", name)
+	}
+	if expected := "analyzing ./testdata/type_error_partial partially, without broken.go"; !strings.Contains(warn.String(), expected) {
+		t.Errorf("expected a warning %q, got %q", expected, warn.String())
+	}
+	if len(failures) != 0 {
+		t.Errorf("expected a package analyzed partially not to be a failure, got %v", failures)
+	}
+}
+
+func TestWriteFailures(t *testing.T) {
+	var out bytes.Buffer
+	WriteFailures(&out, nil)
+	if out.Len() != 0 {
+		t.Errorf("expected nothing to be written without failures, got %q", out.String())
+	}
+	WriteFailures(&out, []PackageFailure{{"./broken", errors.New("it doesn't type check")}, {"database/sql", errors.New("package ssa can't build it")}})
+	expected := `2 packages couldn't be analyzed, so any findings in them are missing:
+- ./broken: it doesn't type check
+- database/sql: package ssa can't build it
+`
+	if out.String() != expected {
+		t.Errorf("expected %q, got %q", expected, out.String())
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"flag"
+	"go/build"
+	"go/parser"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strconv"
+	"testing"
+
+	"golang.org/x/tools/go/callgraph"
+	"golang.org/x/tools/go/loader"
+	"golang.org/x/tools/go/ssa"
+	"golang.org/x/tools/go/ssa/ssautil"
+)
+
+var (
+	perfPackages = flag.Int("perf.packages", 200, "Number of store packages in the program BenchmarkAnalysis generates")
+	perfCommands = flag.Int("perf.commands", 10, "Number of commands in the program BenchmarkAnalysis generates")
+)
+
+// BenchmarkAnalysis measures each phase of the analysis of a synthetic program
+// written by testdata/perf/gen.go, whose size is set with -perf.packages and
+// -perf.commands, e.g.
+//
+//	go test -run NONE -bench Analysis -benchmem -perf.packages 1000
+func BenchmarkAnalysis(b *testing.B) {
+	dir, err := ioutil.TempDir("", "safesql-perf")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	gen := exec.Command("go", "run", "testdata/perf/gen.go", "-out", dir,
+		"-packages", strconv.Itoa(*perfPackages), "-commands", strconv.Itoa(*perfCommands))
+	if out, err := gen.CombinedOutput(); err != nil {
+		b.Fatalf("generating the program: %v\n%s", err, out)
+	}
+
+	defer os.Setenv("GO111MODULE", os.Getenv("GO111MODULE"))
+	os.Setenv("GO111MODULE", "off")
+	ctxt := build.Default
+	ctxt.GOPATH = dir
+	c := loader.Config{
+		Build:       &ctxt,
+		FindPackage: FindPackage,
+		ParserMode:  parser.ParseComments,
+	}
+	pkgs := ExpandPatterns(&ctxt, dir, []string{"example.com/perf/..."}, nil)
+
+	var p *loader.Program
+	b.Run("load", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if p, _, err = LoadPackages(c, pkgs, false, ioutil.Discard); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	if p == nil {
+		return
+	}
+
+	var s *ssa.Program
+	b.Run("ssa", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			s = ssautil.CreateProgram(p, 0)
+			s.Build()
+		}
+	})
+	if s == nil {
+		return
+	}
+
+	spec := sqlPackages[0]
+	qms := FindQueryMethods(spec, p.Package(spec.packageName).Pkg, s)
+	mains := FindMains(p, s)
+	var cg *callgraph.Graph
+	var chans *Channels
+	for precision := PrecisionFast; precision <= PrecisionMax; precision++ {
+		b.Run("callgraph/"+precision.String(), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if cg, chans, err = BuildCallGraph(s, mains, precision); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+	if cg == nil {
+		return
+	}
+
+	b.Run("check", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			cc := &ConstChecker{Chans: chans, Globals: NewGlobals(s)}
+			if bad, _ := FindNonConstCalls(cg, qms, cc); len(bad) == 0 {
+				b.Fatal("expected to find the queries built with fmt.Sprintf")
+			}
+		}
+	})
+}
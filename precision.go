@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"go/token"
+	"go/types"
+	"time"
+
+	"golang.org/x/tools/go/callgraph"
+	"golang.org/x/tools/go/callgraph/cha"
+	"golang.org/x/tools/go/callgraph/vta"
+	"golang.org/x/tools/go/pointer"
+	"golang.org/x/tools/go/ssa"
+	"golang.org/x/tools/go/ssa/ssautil"
+)
+
+// A Precision is how precisely the call graph is built, trading the time it
+// takes for findings which can't actually happen.
+type Precision int
+
+const (
+	// PrecisionFast builds the call graph by class hierarchy analysis, in
+	// which a dynamic call may reach any function of the right type.
+	PrecisionFast Precision = iota + 1
+	// PrecisionBalanced refines that by variable type analysis, in which it
+	// may only reach functions of the types which flow to it.
+	PrecisionBalanced
+	// PrecisionMax builds it by pointer analysis from the main packages, and
+	// also resolves the channels queries are received from.
+	PrecisionMax
+)
+
+func (p Precision) String() string {
+	switch p {
+	case PrecisionFast:
+		return "fast"
+	case PrecisionBalanced:
+		return "balanced"
+	case PrecisionMax:
+		return "max"
+	}
+	return ""
+}
+
+// ParsePrecision parses the name of a Precision.
+func ParsePrecision(s string) (Precision, error) {
+	for p := PrecisionFast; p <= PrecisionMax; p++ {
+		if s == p.String() {
+			return p, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown precision %q, expected fast, balanced or max", s)
+}
+
+// BuildCallGraph builds the call graph of the program run by the given main
+// packages at the given precision. Only PrecisionMax resolves the channels
+// queries are received from; below it the Channels are nil, so that queries
+// received from channels aren't constant.
+//
+// Below PrecisionMax the call graph isn't limited to the functions the main
+// packages can reach, so it may have calls to query methods in functions which
+// are never called.
+func BuildCallGraph(s *ssa.Program, mains []*ssa.Package, precision Precision) (*callgraph.Graph, *Channels, error) {
+	switch precision {
+	case PrecisionFast:
+		return cha.CallGraph(s), nil, nil
+	case PrecisionBalanced:
+		return vta.CallGraph(ssautil.AllFunctions(s), cha.CallGraph(s)), nil, nil
+	}
+	config := &pointer.Config{
+		Mains:          mains,
+		BuildCallGraph: true,
+	}
+	chans := AddChannelQueries(s, config)
+	res, err := pointer.Analyze(config)
+	if err != nil {
+		return nil, nil, err
+	}
+	chans.Resolve(res)
+	return res.CallGraph, chans, nil
+}
+
+// BuildCallGraphWithin is like BuildCallGraph, but if the call graph isn't
+// built within the budget it gives up, and builds it at PrecisionFast instead.
+// It returns the precision the call graph was actually built at. A budget of
+// zero or less means there's no time left for anything slower than
+// PrecisionFast.
+//
+// The analysis given up on can't be cancelled, so it carries on in the
+// background until the program exits.
+func BuildCallGraphWithin(budget time.Duration, s *ssa.Program, mains []*ssa.Package, precision Precision) (*callgraph.Graph, *Channels, Precision, error) {
+	if precision == PrecisionFast || budget <= 0 {
+		cg, chans, err := BuildCallGraph(s, mains, PrecisionFast)
+		return cg, chans, PrecisionFast, err
+	}
+
+	type result struct {
+		cg    *callgraph.Graph
+		chans *Channels
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		cg, chans, err := BuildCallGraph(s, mains, precision)
+		done <- result{cg, chans, err}
+	}()
+	timer := time.NewTimer(budget)
+	defer timer.Stop()
+	select {
+	case r := <-done:
+		return r.cg, r.chans, precision, r.err
+	case <-timer.C:
+		cg, chans, err := BuildCallGraph(s, mains, PrecisionFast)
+		return cg, chans, PrecisionFast, err
+	}
+}
+
+// NeedsPointerAnalysis reports whether only the pointer analysis can tell
+// which calls reach the query methods, or what their queries are: whether a
+// call in the program other than in the SQL packages may reach one of them
+// dynamically, through an interface method of the same name and signature or
+// a function value of the type of its method value or method expression, or
+// the program receives strings from channels. If not, every call to a query
+// method is a static call, which the static call graph has too.
+func NeedsPointerAnalysis(s *ssa.Program, qms []*QueryMethod) bool {
+	// The types of the method values and method expressions of the query
+	// methods, which are the types a function value must have to be one.
+	funcTypes := make([]*types.Signature, 0, 2*len(qms))
+	for _, m := range qms {
+		sig := m.Func.Type().(*types.Signature)
+		funcTypes = append(funcTypes, types.NewSignature(nil, sig.Params(), sig.Results(), sig.Variadic()))
+		if sig.Recv() == nil {
+			// A function, whose value is the function itself.
+			continue
+		}
+		params := []*types.Var{sig.Recv()}
+		for i := 0; i < sig.Params().Len(); i++ {
+			params = append(params, sig.Params().At(i))
+		}
+		funcTypes = append(funcTypes, types.NewSignature(nil, types.NewTuple(params...), sig.Results(), sig.Variadic()))
+	}
+	mayBeQueryMethod := func(cc *ssa.CallCommon) bool {
+		if cc.IsInvoke() {
+			for _, m := range qms {
+				if m.IsMethod() && cc.Method.Name() == m.Func.Name() && types.Identical(cc.Method.Type(), m.Func.Type()) {
+					return true
+				}
+			}
+			return false
+		}
+		if cc.StaticCallee() != nil {
+			return false
+		}
+		for _, t := range funcTypes {
+			if types.Identical(cc.Signature(), t) {
+				return true
+			}
+		}
+		return false
+	}
+
+	for fn := range ssautil.AllFunctions(s) {
+		// Synthetic wrappers, which have no package, are checked too, since
+		// the thunk of an interface method expression calls it dynamically.
+		if fn.Pkg != nil && isSQLPackage(fn.Pkg.Pkg.Path()) {
+			continue
+		}
+		for _, b := range fn.Blocks {
+			for _, instr := range b.Instrs {
+				switch instr := instr.(type) {
+				case ssa.CallInstruction:
+					if mayBeQueryMethod(instr.Common()) {
+						return true
+					}
+				case *ssa.UnOp:
+					if instr.Op == token.ARROW && isStringChan(instr.X.Type()) {
+						return true
+					}
+				case *ssa.Select:
+					for _, st := range instr.States {
+						if st.Dir == types.RecvOnly && isStringChan(st.Chan.Type()) {
+							return true
+						}
+					}
+				}
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/tools/go/loader"
+	"golang.org/x/tools/go/ssa/ssautil"
+)
+
+func TestParsePrecision(t *testing.T) {
+	for p := PrecisionFast; p <= PrecisionMax; p++ {
+		if parsed, err := ParsePrecision(p.String()); err != nil || parsed != p {
+			t.Errorf("expected to parse %q as %d, got %d, %v", p, p, parsed, err)
+		}
+	}
+	if _, err := ParsePrecision("exact"); err == nil {
+		t.Errorf("expected an error for an unknown precision")
+	}
+}
+
+func TestBuildCallGraphWithin(t *testing.T) {
+	var c loader.Config
+	f, err := c.ParseFile("main.go", "package main\n\nfunc query(string) {}\n\nfunc main() { query(\"SELECT 1\") }\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.CreateFromFiles("main", f)
+	p, err := c.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := ssautil.CreateProgram(p, 0)
+	s.Build()
+	main := s.Package(p.Created[0].Pkg)
+
+	cg, chans, built, err := BuildCallGraphWithin(-time.Second, s, nil, PrecisionMax)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if built != PrecisionFast || chans != nil {
+		t.Errorf("expected to fall back to fast precision without channels once the budget is spent, got %s", built)
+	}
+	if in := cg.CreateNode(main.Func("query")).In; len(in) != 1 {
+		t.Errorf("expected 1 call to query, got %d", len(in))
+	}
+}
+
+func TestNeedsPointerAnalysis(t *testing.T) {
+	tests := map[string]struct {
+		decls, main string
+		expected    bool
+	}{
+		"static call": {
+			main:     `db.Query("SELECT 1")`,
+			expected: false,
+		},
+		"local method value": {
+			main:     `f := db.Query; f("SELECT 1")`,
+			expected: false,
+		},
+		"unrelated function value": {
+			decls:    `func run(f func(int)) { f(1) }`,
+			main:     `run(func(int) {}); db.Query("SELECT 1")`,
+			expected: false,
+		},
+		"method value": {
+			decls:    `func run(f func(string)) { f("SELECT 1") }`,
+			main:     `run(db.Query)`,
+			expected: true,
+		},
+		"method expression": {
+			decls:    `func run(f func(*DB, string)) { f(&DB{}, "SELECT 1") }`,
+			main:     `run((*DB).Query); _ = db`,
+			expected: true,
+		},
+		"interface": {
+			decls:    `func run(q interface{ Query(string) }) { q.Query("SELECT 1") }`,
+			main:     `run(db)`,
+			expected: true,
+		},
+		"channel": {
+			main:     `ch := make(chan string, 1); ch <- "SELECT 1"; db.Query(<-ch)`,
+			expected: true,
+		},
+	}
+	for name, test := range tests {
+		var c loader.Config
+		src := "package main\n\ntype DB struct{}\n\nfunc (*DB) Query(query string) {}\n\n" + test.decls + "\n\nfunc main() {\n\tdb := &DB{}\n\t" + test.main + "\n}\n"
+		f, err := c.ParseFile("main.go", src)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		c.CreateFromFiles("main", f)
+		p, err := c.Load()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		s := ssautil.CreateProgram(p, 0)
+		s.Build()
+		spec := sqlPackage{packageName: "main", paramNames: []string{"query"}}
+		qms := FindQueryMethods(spec, p.Created[0].Pkg, s)
+		if len(qms) != 1 {
+			t.Fatalf("%s: expected 1 query method, got %d", name, len(qms))
+		}
+		if needs := NeedsPointerAnalysis(s, qms); needs != test.expected {
+			t.Errorf("%s: expected %v, got %v", name, test.expected, needs)
+		}
+	}
+}
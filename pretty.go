@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+const (
+	ansiReset  = "\x1b[0m"
+	ansiBold   = "\x1b[1m"
+	ansiRed    = "\x1b[31m"
+	ansiGreen  = "\x1b[32m"
+	ansiYellow = "\x1b[33m"
+	ansiCyan   = "\x1b[36m"
+)
+
+// A SnippetPrinter prints findings for people to read, with the offending
+// source lines and a caret under the query.
+type SnippetPrinter struct {
+	// root is the directory file names are printed relative to.
+	root string
+	// Color is whether to use ANSI colors.
+	Color bool
+	// Context is how many lines to print before the offending line.
+	Context int
+
+	sources sourceFiles
+}
+
+// NewSnippetPrinter returns a SnippetPrinter which prints file names relative
+// to root, and uses colors if the output is a terminal.
+func NewSnippetPrinter(root string, out *os.File) *SnippetPrinter {
+	return &SnippetPrinter{
+		root:    root,
+		Color:   os.Getenv("NO_COLOR") == "" && os.Getenv("TERM") != "dumb" && isTerminal(out),
+		Context: 2,
+		sources: make(sourceFiles),
+	}
+}
+
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+func (p *SnippetPrinter) color(code, s string) string {
+	if !p.Color {
+		return s
+	}
+	return code + s + ansiReset
+}
+
+// Print prints a finding to w.
+func (p *SnippetPrinter) Print(w io.Writer, r Result) {
+	name := r.Position.Filename
+	if rel, ok := relPath(p.root, name); ok {
+		name = rel
+	}
+	details := r.Rule
+	if r.Severity != 0 {
+		details += fmt.Sprintf(", %s severity, %s confidence", r.Severity, r.Confidence)
+	}
+	fmt.Fprintf(w, "%s %s %s\n",
+		p.color(ansiBold, fmt.Sprintf("%s:%d:%d:", name, r.Position.Line, r.Position.Column)),
+		p.color(severityName(r.Severity, ansiRed, ansiYellow, ansiCyan)+ansiBold, severityName(r.Severity, "error:", "warning:", "note:")),
+		p.color(ansiBold, fmt.Sprintf("%s (%s)", r.Message, details)))
+
+	lines := p.sources.lines(r.Position.Filename)
+	if r.Position.Line >= 1 && r.Position.Line <= len(lines) {
+		first := r.Position.Line - p.Context
+		if first < 1 {
+			first = 1
+		}
+		width := len(fmt.Sprint(r.Position.Line))
+		for i := first; i <= r.Position.Line; i++ {
+			fmt.Fprintf(w, "%s %s\n", p.color(ansiCyan, fmt.Sprintf("%*d |", width, i)), lines[i-1])
+		}
+		// Underline the query if it starts on the reported line, and
+		// point at the call otherwise.
+		start, end := r.Position.Column, r.Position.Column+1
+		if r.Argument.Line == r.Position.Line {
+			start, end = r.Argument.Column, r.Argument.Column+1
+			if r.ArgumentEnd.Line == r.Argument.Line && r.ArgumentEnd.Column > start {
+				end = r.ArgumentEnd.Column
+			}
+		}
+		fmt.Fprintf(w, "%s %s%s\n", p.color(ansiCyan, strings.Repeat(" ", width)+" |"),
+			indentLike(lines[r.Position.Line-1], start-1),
+			p.color(ansiRed+ansiBold, "^"+strings.Repeat("~", end-start-1)))
+	}
+
+	for _, step := range r.Steps() {
+		stepName := step.Position.Filename
+		if rel, ok := relPath(p.root, stepName); ok {
+			stepName = rel
+		}
+		fmt.Fprintf(w, "  %s %s at %s:%d:%d\n", p.color(ansiYellow, "note:"), step.Message, stepName, step.Position.Line, step.Position.Column)
+	}
+	if len(r.Commands) > 0 {
+		fmt.Fprintf(w, "  %s in %s\n", p.color(ansiYellow, "note:"), strings.Join(r.Commands, ", "))
+	}
+	if r.Fix != nil {
+		fmt.Fprintf(w, "  %s %s\n", p.color(ansiGreen, "help:"), r.Fix.Message)
+	}
+}
+
+// sourceFiles caches the lines of the source files snippets are shown from.
+type sourceFiles map[string][]string
+
+// lines returns the lines of the given file, or nil if it can't be read.
+func (s sourceFiles) lines(filename string) []string {
+	lines, ok := s[filename]
+	if !ok {
+		if data, err := ioutil.ReadFile(filename); err == nil {
+			lines = strings.Split(string(data), "\n")
+		}
+		s[filename] = lines
+	}
+	return lines
+}
+
+// indentLike returns whitespace as wide as the first n bytes of line, keeping
+// its tabs so that it lines up however tabs are displayed.
+func indentLike(line string, n int) string {
+	if n > len(line) {
+		n = len(line)
+	}
+	indent := []byte(line[:n])
+	for i, c := range indent {
+		if c != '\t' {
+			indent[i] = ' '
+		}
+	}
+	return string(indent)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const prettySrc = `package db
+
+func find(db *sql.DB, name string) {
+	q := "SELECT * FROM " + name
+	rows, err := db.Query(q)
+}
+`
+
+func TestSnippetPrinter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "safesql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "db.go")
+	if err := ioutil.WriteFile(filename, []byte(prettySrc), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	p := NewSnippetPrinter(dir, nil)
+	var buf bytes.Buffer
+	p.Print(&buf, Result{
+		Rule:        RuleNonConstQuery,
+		Position:    token.Position{Filename: filename, Line: 5, Column: 23},
+		Message:     "Query is not a compile-time constant",
+		Flow:        []FlowStep{{token.Position{Filename: filename, Line: 4, Column: 7}, "non-constant part: parameter name"}},
+		Argument:    token.Position{Filename: filename, Line: 5, Column: 24},
+		ArgumentEnd: token.Position{Filename: filename, Line: 5, Column: 25},
+	})
+	expected := "db.go:5:23: error: Query is not a compile-time constant (SAFESQL001)\n" +
+		"3 | func find(db *sql.DB, name string) {\n" +
+		"4 | \tq := \"SELECT * FROM \" + name\n" +
+		"5 | \trows, err := db.Query(q)\n" +
+		"  | \t                      ^\n" +
+		"  note: non-constant part: parameter name at db.go:4:7\n"
+	if buf.String() != expected {
+		t.Errorf("Print printed:\n%s\nexpected:\n%s", buf.String(), expected)
+	}
+
+	p.Color = true
+	buf.Reset()
+	p.Print(&buf, Result{Rule: RuleNonConstQuery, Position: token.Position{Filename: filename, Line: 1, Column: 1}})
+	if !bytes.Contains(buf.Bytes(), []byte(ansiRed)) {
+		t.Errorf("Print didn't use colors:\n%q", buf.String())
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"time"
+)
+
+// Profiles writes a CPU profile and a heap profile of the run, for -cpuprofile
+// and -memprofile. A nil Profiles does nothing.
+type Profiles struct {
+	cpu        *os.File
+	memprofile string
+}
+
+// StartProfiles starts profiling the CPU to the file cpuprofile, and records
+// that a heap profile is to be written to memprofile when the profiles are
+// stopped. Either may be empty, and if both are it returns nil.
+func StartProfiles(cpuprofile, memprofile string) (*Profiles, error) {
+	if cpuprofile == "" && memprofile == "" {
+		return nil, nil
+	}
+	p := &Profiles{memprofile: memprofile}
+	if cpuprofile != "" {
+		f, err := os.Create(cpuprofile)
+		if err != nil {
+			return nil, err
+		}
+		if err := pprof.StartCPUProfile(f); err != nil {
+			f.Close()
+			return nil, err
+		}
+		p.cpu = f
+	}
+	return p, nil
+}
+
+// Stop stops the CPU profile, and writes the heap profile. It's called once
+// the analysis is done, while the program and its call graph are still in
+// memory, so that the heap profile shows what they take.
+func (p *Profiles) Stop() error {
+	if p == nil {
+		return nil
+	}
+	if p.cpu != nil {
+		pprof.StopCPUProfile()
+		if err := p.cpu.Close(); err != nil {
+			return err
+		}
+	}
+	if p.memprofile == "" {
+		return nil
+	}
+	f, err := os.Create(p.memprofile)
+	if err != nil {
+		return err
+	}
+	runtime.GC()
+	if err := pprof.WriteHeapProfile(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Timings records how long each phase of the run takes, for -trace-timings.
+// A nil Timings records nothing.
+type Timings struct {
+	phases    []string
+	durations []time.Duration
+	start     time.Time
+}
+
+// Phase records that the previous phase, if any, is over, and that the run is
+// now doing what phase describes, e.g. "building SSA".
+func (t *Timings) Phase(phase string) {
+	if t == nil {
+		return
+	}
+	now := time.Now()
+	t.stop(now)
+	t.phases = append(t.phases, phase)
+	t.start = now
+}
+
+func (t *Timings) stop(now time.Time) {
+	if len(t.durations) < len(t.phases) {
+		t.durations = append(t.durations, now.Sub(t.start))
+	}
+}
+
+// Write records that the last phase is over, and writes how long each phase
+// took.
+func (t *Timings) Write(w io.Writer) {
+	if t == nil {
+		return
+	}
+	t.stop(time.Now())
+	fmt.Fprintln(w, "timings:")
+	for i, phase := range t.phases {
+		fmt.Fprintf(w, "- %s: %s\n", phase, t.durations[i].Round(time.Millisecond))
+	}
+}
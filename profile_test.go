@@ -0,0 +1,26 @@
+package main
+
+import (
+	"bytes"
+	"regexp"
+	"testing"
+)
+
+func TestTimings(t *testing.T) {
+	var nilTimings *Timings
+	nilTimings.Phase("loading packages")
+	var buf bytes.Buffer
+	nilTimings.Write(&buf)
+	if buf.Len() != 0 {
+		t.Errorf("expected a nil Timings to write nothing, got %q", buf.String())
+	}
+
+	timings := &Timings{}
+	timings.Phase("loading packages")
+	timings.Phase("building SSA")
+	timings.Write(&buf)
+	expected := regexp.MustCompile(`^timings:\n- loading packages: [0-9.]+m?s\n- building SSA: [0-9.]+m?s\n$`)
+	if !expected.MatchString(buf.String()) {
+		t.Errorf("expected output matching %s, got %q", expected, buf.String())
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/token"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// A DumpedQuery is a constant query in a query dump.
+type DumpedQuery struct {
+	File   string `json:"file"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+	// Function is the function the call is in, and Method the query
+	// method the query is passed to.
+	Function string `json:"function,omitempty"`
+	Method   string `json:"method"`
+	Query    string `json:"query"`
+}
+
+type queryDump struct {
+	Queries []DumpedQuery `json:"queries"`
+}
+
+// DumpQueries returns an entry for each of the values of each of the given
+// queries, sorted by position, with file names relative to root where
+// possible.
+func DumpQueries(fset *token.FileSet, queries []ConstQuery, root string) []DumpedQuery {
+	dumped := make([]DumpedQuery, 0, len(queries))
+	for _, q := range queries {
+		pos := fset.Position(q.Site.Pos())
+		name := pos.Filename
+		if rel, ok := relPath(root, name); ok {
+			name = rel
+		}
+		var function string
+		if fn := q.Site.Parent(); fn != nil && fn.Pkg != nil {
+			function = fn.RelString(fn.Pkg.Pkg)
+		}
+		for _, v := range q.Values {
+			dumped = append(dumped, DumpedQuery{
+				File:     name,
+				Line:     pos.Line,
+				Column:   pos.Column,
+				Function: function,
+				Method:   q.Method.Func.FullName(),
+				Query:    v,
+			})
+		}
+	}
+	sort.SliceStable(dumped, func(i, j int) bool {
+		a, b := dumped[i], dumped[j]
+		return positionLess(token.Position{Filename: a.File, Line: a.Line, Column: a.Column},
+			token.Position{Filename: b.File, Line: b.Line, Column: b.Column})
+	})
+	return dumped
+}
+
+// WriteQueryDump writes queries to the file at path, as SQL, with a comment
+// saying where each query is, if its name ends in ".sql", and as JSON
+// otherwise.
+func WriteQueryDump(path string, queries []DumpedQuery) error {
+	var data []byte
+	if filepath.Ext(path) == ".sql" {
+		var buf bytes.Buffer
+		for _, q := range queries {
+			fmt.Fprintf(&buf, "-- %s:%d:%d %s\n", q.File, q.Line, q.Column, q.Method)
+			query := strings.TrimSpace(q.Query)
+			if !strings.HasSuffix(query, ";") {
+				query += ";"
+			}
+			fmt.Fprintf(&buf, "%s\n\n", query)
+		}
+		data = buf.Bytes()
+	} else {
+		var err error
+		data, err = json.MarshalIndent(queryDump{queries}, "", "  ")
+		if err != nil {
+			return err
+		}
+		data = append(data, '\n')
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// ReadQueryDump reads a query dump written as JSON by WriteQueryDump.
+func ReadQueryDump(path string) ([]DumpedQuery, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var dump queryDump
+	if err := json.Unmarshal(data, &dump); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return dump.Queries, nil
+}
+
+// A QueryChange is a query which isn't in a query baseline.
+type QueryChange struct {
+	DumpedQuery
+	// Old is the query in the baseline it replaces, if any: one passed to
+	// the same method in the same function which no longer is.
+	Old *DumpedQuery
+}
+
+// CompareQueries returns the queries which aren't in the baseline, and the
+// queries in the baseline which are neither in queries any more nor replaced
+// by one of them. Queries are compared by file, function, method and text
+// rather than position, so that unrelated edits don't change them.
+func CompareQueries(baseline, queries []DumpedQuery) (changed []QueryChange, removed []DumpedQuery) {
+	key := func(q DumpedQuery) string {
+		return strings.Join([]string{q.File, q.Function, q.Method, q.Query}, "\x00")
+	}
+	remaining := make(map[string]int)
+	for _, q := range baseline {
+		remaining[key(q)]++
+	}
+	added := make([]DumpedQuery, 0)
+	for _, q := range queries {
+		if remaining[key(q)] > 0 {
+			remaining[key(q)]--
+			continue
+		}
+		added = append(added, q)
+	}
+	for _, q := range baseline {
+		if remaining[key(q)] > 0 {
+			remaining[key(q)]--
+			removed = append(removed, q)
+		}
+	}
+
+	changed = make([]QueryChange, 0, len(added))
+	for _, q := range added {
+		change := QueryChange{DumpedQuery: q}
+		for i, old := range removed {
+			if old.File == q.File && old.Function == q.Function && old.Method == q.Method {
+				change.Old = &old
+				removed = append(removed[:i], removed[i+1:]...)
+				break
+			}
+		}
+		changed = append(changed, change)
+	}
+	return changed, removed
+}
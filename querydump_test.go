@@ -0,0 +1,155 @@
+package main
+
+import (
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/ssa"
+)
+
+const constValuesSrc = `package main
+
+type DB struct{}
+
+func (*DB) Exec(query string) {}
+
+var byName = map[string]string{"a": "SELECT a FROM t", "b": "SELECT b FROM t"}
+
+func main() {
+	db := &DB{}
+	db.Exec("SELECT 1") // SELECT 1
+	q := "SELECT 2"
+	if dynamic() != "" {
+		q = "SELECT 3"
+	}
+	db.Exec(q)            // SELECT 3|SELECT 2
+	db.Exec(byName["a"])  // SELECT a FROM t|SELECT b FROM t
+	db.Exec(q + dynamic()) //
+}
+
+func dynamic() string {
+	return "SELECT " + string(rune(len("x")))
+}
+`
+
+// TestConstValues checks the values found for constant queries, which are
+// given by the comments on the lines they are on.
+func TestConstValues(t *testing.T) {
+	fset := token.NewFileSet()
+	pkg := buildSource(t, fset, "main.go", constValuesSrc, types.NewPackage("main", ""), nil)
+	cc := &ConstChecker{Globals: NewGlobals(pkg.Prog)}
+
+	lines := strings.Split(constValuesSrc, "\n")
+	for _, b := range pkg.Func("main").Blocks {
+		for _, instr := range b.Instrs {
+			call, ok := instr.(*ssa.Call)
+			if !ok || call.Common().StaticCallee() == nil || call.Common().StaticCallee().Name() != "Exec" {
+				continue
+			}
+			line := lines[fset.Position(call.Pos()).Line-1]
+			comment := strings.TrimSpace(line[strings.Index(line, "//")+2:])
+			values, ok := cc.ConstValues(call.Common().Args[1])
+			if comment == "" {
+				if ok {
+					t.Errorf("%s: expected a non-constant query, got %q", strings.TrimSpace(line), values)
+				}
+				continue
+			}
+			expected := strings.Split(comment, "|")
+			if !ok || !sameStrings(values, expected) {
+				t.Errorf("%s: expected %q, got %q", strings.TrimSpace(line), expected, values)
+			}
+		}
+	}
+}
+
+func sameStrings(a, b []string) bool {
+	seen := make(map[string]int)
+	for _, s := range a {
+		seen[s]++
+	}
+	for _, s := range b {
+		seen[s]--
+	}
+	for _, n := range seen {
+		if n != 0 {
+			return false
+		}
+	}
+	return len(a) == len(b)
+}
+
+func TestWriteQueryDump(t *testing.T) {
+	dir, err := ioutil.TempDir("", "safesql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	queries := []DumpedQuery{
+		{File: "db/db.go", Line: 3, Column: 10, Method: "(*database/sql.DB).Exec", Query: "DELETE FROM t"},
+		{File: "db/db.go", Line: 7, Column: 10, Method: "(*database/sql.DB).Query", Query: "SELECT 1;\n"},
+	}
+
+	sqlFile := filepath.Join(dir, "queries.sql")
+	if err := WriteQueryDump(sqlFile, queries); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(sqlFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "-- db/db.go:3:10 (*database/sql.DB).Exec\nDELETE FROM t;\n\n" +
+		"-- db/db.go:7:10 (*database/sql.DB).Query\nSELECT 1;\n\n"
+	if string(data) != expected {
+		t.Errorf("expected %q, got %q", expected, data)
+	}
+
+	jsonFile := filepath.Join(dir, "queries.json")
+	if err := WriteQueryDump(jsonFile, queries); err != nil {
+		t.Fatal(err)
+	}
+	data, err = ioutil.ReadFile(jsonFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"query": "DELETE FROM t"`) {
+		t.Errorf("unexpected JSON %s", data)
+	}
+}
+
+func TestCompareQueries(t *testing.T) {
+	q := func(line int, function, query string) DumpedQuery {
+		return DumpedQuery{File: "db.go", Line: line, Function: function, Method: "(*database/sql.DB).Query", Query: query}
+	}
+	baseline := []DumpedQuery{
+		q(3, "users", "SELECT * FROM users"),
+		q(7, "orders", "SELECT * FROM orders"),
+		q(9, "orders", "SELECT * FROM orders"),
+		q(12, "items", "SELECT * FROM items"),
+	}
+	queries := []DumpedQuery{
+		// Moved by an edit above it.
+		q(5, "users", "SELECT * FROM users"),
+		q(9, "orders", "SELECT * FROM orders"),
+		q(11, "orders", "SELECT id FROM orders"),
+		q(20, "payments", "SELECT * FROM payments"),
+	}
+	changed, removed := CompareQueries(baseline, queries)
+	if len(changed) != 2 {
+		t.Fatalf("expected 2 changed queries, got %+v", changed)
+	}
+	if changed[0].Query != "SELECT id FROM orders" || changed[0].Old == nil || changed[0].Old.Query != "SELECT * FROM orders" {
+		t.Errorf("expected the orders query to be changed, got %+v", changed[0])
+	}
+	if changed[1].Query != "SELECT * FROM payments" || changed[1].Old != nil {
+		t.Errorf("expected the payments query to be new, got %+v", changed[1])
+	}
+	if len(removed) != 1 || removed[0].Query != "SELECT * FROM items" {
+		t.Errorf("expected the items query to be removed, got %+v", removed)
+	}
+}
@@ -0,0 +1,393 @@
+package main
+
+import (
+	"fmt"
+	"go/token"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// A Rule describes one of the checks safesql performs.
+type Rule struct {
+	ID          string
+	Name        string
+	Description string
+	Help        string
+	// Category is the Code Climate category of the rule's findings, e.g.
+	// "Security", and Tags are their SARIF tags.
+	Category string
+	Tags     []string
+	// Severity is that of the rule's findings unless the configuration
+	// overrides it, or for those graded by their query, the highest.
+	Severity Level
+	// CWE and OWASP classify the weakness the rule finds, e.g. "CWE-89"
+	// and "A03:2021 - Injection", for vulnerability management tools.
+	CWE   []string
+	OWASP []string
+	// Gosec are the identifiers of the gosec rules which report the same
+	// problems, e.g. G201, so that tools collecting the findings of both
+	// can tell which overlap.
+	Gosec []string
+	// Informational rules' findings never fail the run.
+	Informational bool
+	// OptIn rules are only checked if they're enabled, with -enable or in
+	// the configuration file.
+	OptIn bool
+	// Unsafe is an example of code which breaks the rule, and Safe the same
+	// code fixed, and Remediation lists the ways to fix it, for safesql
+	// explain.
+	Unsafe      string
+	Safe        string
+	Remediation []string
+}
+
+// Rules lists the checks safesql performs.
+var Rules = []Rule{
+	{
+		ID:          RuleNonConstQuery,
+		Name:        "NonConstantQuery",
+		Description: "SQL query is not a compile-time constant",
+		Help: "Queries built from strings at runtime, e.g. with fmt.Sprintf or string " +
+			"concatenation, may be subverted by user-supplied data. Use a constant " +
+			"query with placeholders for the values instead.",
+		Category: "Security",
+		Tags:     []string{"security"},
+		Severity: LevelHigh,
+		CWE:      []string{"CWE-89"},
+		OWASP:    []string{owaspInjection},
+		Gosec:    gosecSQLRules,
+		Unsafe:   `db.Query("SELECT * FROM users WHERE name = '" + name + "'")`,
+		Safe:     `db.Query("SELECT * FROM users WHERE name = ?", name)`,
+		Remediation: []string{
+			"Pass values as parameters, with the driver's placeholders (?, $1 or @p1), rather than splicing them into the query.",
+			"Declare queries as constants, of a type of their own such as type Query string if that helps keep them apart, and choose between them, e.g. in a switch, rather than building one from the parts which vary.",
+			"Quote identifiers, which placeholders can't stand for, for the dialect, e.g. with pq.QuoteIdentifier, or check them against a list of known names.",
+		},
+	},
+	{
+		ID:          RuleInvalidSQL,
+		Name:        "InvalidSQL",
+		Description: "Constant SQL query doesn't parse",
+		Help: "The query has a syntax error, and will fail when it's run. This " +
+			"often happens when a query built from several string constants is " +
+			"refactored. Only reported with -validate-sql.",
+		Category: "Bug Risk",
+		Tags:     []string{"correctness"},
+		Severity: LevelMedium,
+		Unsafe:   `db.Query("SELECT * FORM users WHERE id = ?", id)`,
+		Safe:     `db.Query("SELECT * FROM users WHERE id = ?", id)`,
+		Remediation: []string{
+			"Fix the syntax error; -dialect makes sure the query is parsed as the database does.",
+		},
+	},
+	{
+		ID:          RuleRequestDSN,
+		Name:        "DataSourceNameFromRequest",
+		Description: "Data source name is built from an HTTP request",
+		Help: "Whoever sends the request can choose the database server to connect " +
+			"to, the credentials, and driver options such as multi-statement " +
+			"execution. Build data source names from configuration instead.",
+		Category: "Security",
+		Tags:     []string{"security"},
+		Severity: LevelHigh,
+		CWE:      []string{"CWE-99"},
+		OWASP:    []string{owaspInjection},
+		Unsafe:   `db, err := sql.Open("mysql", r.FormValue("dsn"))`,
+		Safe:     `db, err := sql.Open("mysql", os.Getenv("DATABASE_URL"))`,
+		Remediation: []string{
+			"Build data source names from configuration, such as a file or the environment, rather than from requests.",
+			"If a request must choose the database, look it up by name among those the configuration lists.",
+		},
+	},
+	{
+		ID:          RuleUncheckedHandle,
+		Name:        "UncheckedHandle",
+		Description: "Database handle escapes into reflection or unsafe code",
+		Help: "Calls made through package reflect or unsafe pointers can't be traced, " +
+			"so the queries passed to them can't be checked. This is informational: " +
+			"it shows which parts of the program safesql can't vouch for, and " +
+			"doesn't fail the run.",
+		Category:      "Security",
+		Tags:          []string{"security"},
+		Severity:      LevelLow,
+		Informational: true,
+		Unsafe:        `reflect.ValueOf(db).MethodByName("Query").Call(args)`,
+		Safe:          `db.Query("SELECT * FROM users WHERE id = ?", id)`,
+		Remediation: []string{
+			"Call the database handle's methods directly, so that the queries passed to them can be checked.",
+			"If the reflection can't be avoided, check by other means that only constant queries reach it, and suppress the finding.",
+		},
+	},
+	{
+		ID:          RuleDynamicDDL,
+		Name:        "DynamicDDL",
+		Description: "DDL statement is not a compile-time constant",
+		Help: "CREATE, ALTER and DROP statements built at runtime, e.g. for a schema " +
+			"or table per tenant, name what they change with identifiers, which " +
+			"placeholders can't stand for. Check the identifiers against a list of " +
+			"known names, or quote them for the dialect (e.g. pq.QuoteIdentifier or " +
+			"pgx.Identifier{...}.Sanitize()) before building the statement.",
+		Category: "Security",
+		Tags:     []string{"security"},
+		Severity: LevelHigh,
+		CWE:      []string{"CWE-89"},
+		OWASP:    []string{owaspInjection},
+		Gosec:    gosecSQLRules,
+		Unsafe:   `db.Exec("CREATE SCHEMA " + tenant)`,
+		Safe:     `db.Exec("CREATE SCHEMA " + pq.QuoteIdentifier(tenant))`,
+		Remediation: []string{
+			"Quote the identifiers for the dialect, e.g. with pq.QuoteIdentifier or pgx.Identifier{...}.Sanitize(); with lib/pq or pgx, safesql -fix does so.",
+			"Or check them against a list of known names before building the statement.",
+		},
+	},
+	{
+		ID:          RuleInList,
+		Name:        "SprintfInList",
+		Description: "IN list is built with fmt.Sprintf and strings.Join",
+		Help: "Values joined into an IN (...) list, e.g. with " +
+			"fmt.Sprintf(\"... IN (%s)\", strings.Join(ids, \",\")), may be " +
+			"subverted by user-supplied data whenever they're strings. Pass them " +
+			"as parameters instead: expand a placeholder for them with sqlx.In, " +
+			"pass them as one array parameter (e.g. id = ANY($1)) with pgx or " +
+			"pq.Array, or build a placeholder for each.",
+		Category: "Security",
+		Tags:     []string{"security"},
+		Severity: LevelHigh,
+		CWE:      []string{"CWE-89"},
+		OWASP:    []string{owaspInjection},
+		Gosec:    gosecSQLRules,
+		Unsafe:   `db.Query(fmt.Sprintf("SELECT * FROM users WHERE id IN (%s)", strings.Join(ids, ",")))`,
+		Safe:     `db.Query("SELECT * FROM users WHERE id = ANY($1)", pq.Array(ids))`,
+		Remediation: []string{
+			"With sqlx, expand a single placeholder for the values with sqlx.In.",
+			"With pgx or lib/pq, pass the values as one array parameter, e.g. id = ANY($1), with pq.Array for lib/pq.",
+			"Otherwise, build a placeholder for each value and pass the values as parameters.",
+		},
+	},
+	{
+		ID:          RuleSQLFormat,
+		Name:        "SQLFormatString",
+		Description: "Format string looks like SQL, but isn't passed to a known database package",
+		Help: "A Sprintf-like function builds what looks like a SQL statement, which " +
+			"may be run by a database layer safesql doesn't know, and so doesn't " +
+			"check. Add the package the statement is passed to to the " +
+			"configuration file, so that its queries are checked. This is an " +
+			"opt-in audit: enable it with -enable SAFESQL007.",
+		Category:      "Security",
+		Tags:          []string{"security"},
+		Severity:      LevelLow,
+		CWE:           []string{"CWE-89"},
+		OWASP:         []string{owaspInjection},
+		Informational: true,
+		OptIn:         true,
+		Unsafe:        `store.Run(fmt.Sprintf("SELECT * FROM users WHERE name = '%s'", name))`,
+		Safe:          `store.Run("SELECT * FROM users WHERE name = ?", name)`,
+		Remediation: []string{
+			"Add the package which runs the statement to the configuration file, so that its queries are checked too.",
+			"Pass values as parameters to it rather than formatting them into the statement.",
+		},
+	},
+}
+
+// owaspInjection is the category of the OWASP Top 10 which SQL and resource
+// injection fall under.
+const owaspInjection = "A03:2021 - Injection"
+
+// HelpURI returns the address of the rule's documentation.
+func (r Rule) HelpURI() string {
+	return "https://github.com/stripe/safesql#" + strings.ToLower(r.ID)
+}
+
+// References returns the CWE and OWASP identifiers of the rule, separated by
+// commas, or "" if it has none.
+func (r Rule) References() string {
+	return strings.Join(append(append([]string(nil), r.CWE...), r.OWASP...), ", ")
+}
+
+// LookupRule returns the rule with the given identifier.
+func LookupRule(id string) (Rule, bool) {
+	for _, rule := range Rules {
+		if rule.ID == id {
+			return rule, true
+		}
+	}
+	return Rule{}, false
+}
+
+// A FlowStep is a step in the flow of data to a finding.
+type FlowStep struct {
+	Position token.Position
+	Message  string
+}
+
+// A Result is a finding as it is reported in machine-readable formats.
+type Result struct {
+	Rule     string
+	Position token.Position
+	// Package is the import path of the package the finding is in.
+	Package string
+	Message string
+	// Severity and Confidence grade the finding. They are zero if it
+	// hasn't been graded.
+	Severity, Confidence Level
+	// Argument and ArgumentEnd are the extent of the query expression, if
+	// it's known.
+	Argument, ArgumentEnd token.Position
+	// Flow is how data reaches the finding, e.g. the non-constant parts
+	// of a query.
+	Flow []FlowStep
+	// Related are the other places the same problem shows up, e.g. other
+	// calls which are passed the same query.
+	Related     []FlowStep
+	Fingerprint string
+	// Suppressed is whether the finding doesn't fail the run, for whatever
+	// reason.
+	Suppressed bool
+	// Suppression is "inSource" for findings suppressed by an ignore
+	// comment and "external" for those suppressed by the configuration
+	// file, in which case Justification says why.
+	Suppression   string
+	Justification string
+	// Fix is a rewrite of the source which resolves the finding, if
+	// there's a simple one.
+	Fix *Fix
+	// Commands are the import paths of the main packages which can run the
+	// code the finding is in, with -all-commands.
+	Commands []string
+	// BaselineState is "unchanged" for findings which are in the baseline
+	// or on lines -diff doesn't consider changed, and "new" for the others
+	// if either is in use.
+	BaselineState string
+}
+
+// Steps returns the steps of the flow to the finding followed by the related
+// locations.
+func (r Result) Steps() []FlowStep {
+	steps := make([]FlowStep, 0, len(r.Flow)+len(r.Related))
+	return append(append(steps, r.Flow...), r.Related...)
+}
+
+// A Reporter writes findings in a machine-readable format.
+type Reporter interface {
+	// Add adds a finding to the report.
+	Add(Result)
+	// Write writes the report to w.
+	Write(w io.Writer) error
+}
+
+// NewReporter returns a Reporter for the given format, whose file names are
+// relative to root where possible. The template format writes findings with
+// the given text/template.
+func NewReporter(format, root, tmpl string) (Reporter, error) {
+	switch format {
+	case "sarif":
+		return NewSARIFReport(root), nil
+	case "checkstyle":
+		return NewCheckstyleReport(root), nil
+	case "csv":
+		return NewCSVReport(root), nil
+	case "codeclimate":
+		return NewCodeClimateReport(root), nil
+	case "github":
+		return NewGitHubReport(root), nil
+	case "html":
+		return NewHTMLReport(root), nil
+	case "junit":
+		return NewJUnitReport(root), nil
+	case "markdown":
+		return NewMarkdownReport(root), nil
+	case "teamcity":
+		return NewTeamCityReport(root), nil
+	case "template":
+		if tmpl == "" {
+			return nil, fmt.Errorf("-format template needs a -template")
+		}
+		return NewTemplateReport(root, tmpl)
+	}
+	return nil, fmt.Errorf("unknown format %q", format)
+}
+
+// writeReport writes the report to the named file, or to standard output if
+// the name is empty.
+func writeReport(r Reporter, filename string) error {
+	if filename == "" {
+		return r.Write(os.Stdout)
+	}
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := r.Write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// SortResults sorts results by position and then by rule, so that reports
+// don't depend on the order the analysis happened to find things in.
+func SortResults(results []Result) {
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Position != results[j].Position {
+			return positionLess(results[i].Position, results[j].Position)
+		}
+		return results[i].Rule < results[j].Rule
+	})
+}
+
+// Findings records which findings have been reported, by rule and position,
+// so that each is only reported, counted and added to the baseline once, even
+// if the analysis finds it more than once, e.g. through calls at the same
+// position or in a file loaded under two names.
+type Findings map[string]bool
+
+// First reports whether the finding of the given rule at pos is the first one,
+// and records it.
+func (f Findings) First(rule string, pos token.Position) bool {
+	key := fmt.Sprintf("%s %s:%d:%d", rule, fileKey(pos.Filename), pos.Line, pos.Column)
+	if f[key] {
+		return false
+	}
+	f[key] = true
+	return true
+}
+
+// positionLess orders positions by file name, line and column.
+func positionLess(a, b token.Position) bool {
+	if a.Filename != b.Filename {
+		return a.Filename < b.Filename
+	}
+	if a.Line != b.Line {
+		return a.Line < b.Line
+	}
+	return a.Column < b.Column
+}
+
+// severityName returns high, medium or low according to the severity of a
+// finding, for formats which have their own names for severities. Findings
+// which haven't been graded count as high.
+func severityName(severity Level, high, medium, low string) string {
+	switch severity {
+	case LevelMedium:
+		return medium
+	case LevelLow:
+		return low
+	}
+	return high
+}
+
+// relPath returns filename relative to root, slash-separated, if it's below
+// root.
+func relPath(root, filename string) (string, bool) {
+	if root == "" {
+		return "", false
+	}
+	rel, err := filepath.Rel(root, filename)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
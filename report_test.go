@@ -0,0 +1,26 @@
+package main
+
+import (
+	"go/token"
+	"testing"
+)
+
+func TestFindings(t *testing.T) {
+	findings := make(Findings)
+	pos := token.Position{Filename: "/src/db.go", Line: 3, Column: 10}
+	if !findings.First(RuleNonConstQuery, pos) {
+		t.Error("expected the first finding to be the first")
+	}
+	if findings.First(RuleNonConstQuery, pos) {
+		t.Error("expected the same finding again not to be the first")
+	}
+	if findings.First(RuleNonConstQuery, token.Position{Filename: "/src/./db.go", Line: 3, Column: 10, Offset: 40}) {
+		t.Error("expected the same finding in the same file under another name not to be the first")
+	}
+	if !findings.First(RuleInvalidSQL, pos) {
+		t.Error("expected a finding of another rule at the same position to be the first")
+	}
+	if !findings.First(RuleNonConstQuery, token.Position{Filename: "/src/db.go", Line: 3, Column: 20}) {
+		t.Error("expected a finding at another position to be the first")
+	}
+}
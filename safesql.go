@@ -6,29 +6,44 @@ package main
 import (
 	"flag"
 	"fmt"
+	"go/ast"
 	"go/build"
+	"go/constant"
+	"go/parser"
 	"go/token"
 	"go/types"
-	"io/ioutil"
+	"io"
 	"os"
-	"sort"
 
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
+	"golang.org/x/tools/go/ast/astutil"
 	"golang.org/x/tools/go/callgraph"
 	"golang.org/x/tools/go/loader"
-	"golang.org/x/tools/go/pointer"
 	"golang.org/x/tools/go/ssa"
 	"golang.org/x/tools/go/ssa/ssautil"
 )
 
-const IgnoreComment = "//nolint:safesql"
-
 type sqlPackage struct {
 	packageName string
 	paramNames  []string
-	enable      bool
+	// funcNames are the package-level functions which take raw SQL as their
+	// first string parameter, e.g. gorm.Expr.
+	funcNames []string
+	// rewritesPlaceholders is whether the package rewrites ? placeholders
+	// for the dialect itself, so that fixes should use them whatever it is.
+	rewritesPlaceholders bool
+	// fragments is whether its queries are parts of statements, such as
+	// the conditions of a query builder, which can't be validated alone.
+	fragments bool
+	// mock is whether it's a mock database, against which queries aren't
+	// run at all.
+	mock   bool
+	enable bool
 }
 
 var sqlPackages = []sqlPackage{
@@ -37,229 +52,844 @@ var sqlPackages = []sqlPackage{
 		paramNames:  []string{"query"},
 	},
 	{
-		packageName: "github.com/jinzhu/gorm",
-		paramNames:  []string{"sql", "query"},
+		packageName:          "github.com/jinzhu/gorm",
+		paramNames:           []string{"sql", "query"},
+		funcNames:            []string{"Expr"},
+		rewritesPlaceholders: true,
+	},
+	{
+		packageName:          "gorm.io/gorm",
+		paramNames:           []string{"sql", "query"},
+		funcNames:            []string{"Expr"},
+		rewritesPlaceholders: true,
+	},
+	{
+		// Its Expr and NamedExpr hold raw SQL in their SQL fields, which
+		// are checked by FindNonConstFields.
+		packageName: "gorm.io/gorm/clause",
 	},
 	{
 		packageName: "github.com/jmoiron/sqlx",
 		paramNames:  []string{"query"},
 	},
+	// pgx's Conn and Pool take queries as sql, and its Batch, whose
+	// queries are run later by SendBatch, as query.
+	{
+		packageName: "github.com/jackc/pgx/v4",
+		paramNames:  []string{"sql", "query"},
+	},
+	{
+		packageName: "github.com/jackc/pgx/v4/pgxpool",
+		paramNames:  []string{"sql"},
+	},
+	{
+		packageName: "github.com/jackc/pgx/v5",
+		paramNames:  []string{"sql", "query"},
+	},
+	{
+		packageName: "github.com/jackc/pgx/v5/pgxpool",
+		paramNames:  []string{"sql"},
+	},
+	// go-ora's Connection runs queries itself when it's used without
+	// database/sql, and takes them as query or text, as does NewStmt.
+	{
+		packageName: "github.com/sijms/go-ora/v2",
+		paramNames:  []string{"query", "text"},
+		funcNames:   []string{"NewStmt"},
+	},
+	// godror's queues and object types are looked up by name, so its
+	// only raw SQL of its own is the query a Subscription registers for
+	// change notifications.
+	{
+		packageName: "github.com/godror/godror",
+		paramNames:  []string{"qry"},
+	},
+	// SQLite's own bindings prepare statements from queries on their Conn,
+	// and sqlitex's functions prepare and run them in one go.
+	{
+		packageName: "crawshaw.io/sqlite",
+		paramNames:  []string{"query"},
+	},
+	{
+		packageName: "crawshaw.io/sqlite/sqlitex",
+		paramNames:  []string{"query"},
+		funcNames:   []string{"Exec", "ExecTransient", "ExecScript"},
+	},
+	{
+		packageName: "zombiezen.com/go/sqlite",
+		paramNames:  []string{"query"},
+	},
+	{
+		packageName: "zombiezen.com/go/sqlite/sqlitex",
+		paramNames:  []string{"query"},
+		funcNames:   []string{"Exec", "ExecTransient", "Execute", "ExecuteTransient", "ExecScript", "ExecuteScript"},
+	},
+	// scany's Select and Get run a query and scan its rows into a slice or
+	// struct, both as functions and as methods of the API types.
+	{
+		packageName: "github.com/georgysavva/scany/pgxscan",
+		paramNames:  []string{"query"},
+		funcNames:   []string{"Select", "Get"},
+	},
+	{
+		packageName: "github.com/georgysavva/scany/sqlscan",
+		paramNames:  []string{"query"},
+		funcNames:   []string{"Select", "Get"},
+	},
+	{
+		packageName: "github.com/georgysavva/scany/v2/pgxscan",
+		paramNames:  []string{"query"},
+		funcNames:   []string{"Select", "Get"},
+	},
+	{
+		packageName: "github.com/georgysavva/scany/v2/sqlscan",
+		paramNames:  []string{"query"},
+		funcNames:   []string{"Select", "Get"},
+	},
+	// Query builders take raw SQL conditions, as opposed to maps of
+	// columns to values or expressions, as strings or interface{}.
+	{
+		packageName:          "github.com/Masterminds/squirrel",
+		paramNames:           []string{"pred", "sql", "join"},
+		funcNames:            []string{"Expr"},
+		rewritesPlaceholders: true,
+		fragments:            true,
+	},
+	{
+		packageName:          "github.com/doug-martin/goqu/v9",
+		paramNames:           []string{"query"},
+		funcNames:            []string{"L", "Literal"},
+		rewritesPlaceholders: true,
+		fragments:            true,
+	},
+	{
+		packageName:          "github.com/gocraft/dbr/v2",
+		paramNames:           []string{"query"},
+		funcNames:            []string{"Expr"},
+		rewritesPlaceholders: true,
+		fragments:            true,
+	},
+	// Mock databases' expectations (ExpectQuery, ExpectExec and
+	// ExpectPrepare) take regular expressions which match queries, and are
+	// often built from them, rather than queries, so they're never sinks.
+	{
+		packageName: "github.com/DATA-DOG/go-sqlmock",
+		mock:        true,
+	},
+	{
+		packageName: "gopkg.in/DATA-DOG/go-sqlmock.v1",
+		mock:        true,
+	},
 }
 
 func main() {
-	var verbose, quiet bool
+	if len(os.Args) > 1 && os.Args[1] == "doctor" {
+		os.Exit(doctorMain(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "serve" {
+		os.Exit(serveMain(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "explain" {
+		os.Exit(explainMain(os.Args[2:]))
+	}
+
+	var verbose, debug, quiet, unusedSuppressions, fix, watch, setExitStatus, stdin, validateSQL, suggestSinks, workspace, allCommands, tests, traceTimings, trustMigrations bool
+	var maxIssues int
+	var timeout, budget time.Duration
+	var failOn, file, excludeDirs, dumpFile, queriesBaseline, dialectName string
+	var tags, goos, goarch, metricsFile, changedFilesList, cpuprofile, memprofile string
+	var minSeverity, minConfidence, pathMode, tmpl, precisionName string
+	var enableRules, disableRules, ruleSeverities string
+	var baselineMode, baselineFile, diffRef, configFile, auditFile, format, outputFile, sourceURL, gosecFile string
 	flag.BoolVar(&verbose, "v", false, "Verbose mode")
+	flag.BoolVar(&debug, "debug", false, "Also print the intermediate results of the analysis, for troubleshooting")
 	flag.BoolVar(&quiet, "q", false, "Only print on failure")
+	flag.BoolVar(&unusedSuppressions, "unused-suppressions", false, "Fail on ignore comments which don't ignore anything")
+	flag.StringVar(&baselineMode, "baseline", "", "Write current findings to the baseline file (write), or only fail on findings not in it (check)")
+	flag.StringVar(&baselineFile, "baseline-file", ".safesql-baseline.json", "Baseline file to use with -baseline")
+	flag.StringVar(&configFile, "config", DefaultConfigFile, "Configuration file")
+	flag.StringVar(&gosecFile, "gosec-report", "", "Leave out the SQL findings on lines which this gosec JSON report (gosec -fmt json) has a G201 or G202 finding on")
+	flag.StringVar(&auditFile, "audit-suppressions", "", "Write an inventory of all suppressions to this JSON file")
+	flag.StringVar(&dumpFile, "dump-queries", "", "Write every constant query to this file, as SQL if its name ends in .sql and as JSON otherwise")
+	flag.StringVar(&queriesBaseline, "queries-baseline", "", "Fail on constant queries which aren't in this JSON file, written with -dump-queries, or are changed since")
+	flag.StringVar(&metricsFile, "metrics-file", "", "Write statistics about the run to this file, in the Prometheus text format if its name ends in .prom and as JSON otherwise")
+	flag.StringVar(&format, "format", "text", "Output format: text, pretty, or checkstyle, codeclimate, csv, github, html, junit, markdown, sarif, teamcity or template to print findings in that format to stdout and everything else to stderr")
+	flag.StringVar(&sourceURL, "source-url", "", "Address of the repository's files at the commit checked, e.g. https://github.com/org/repo/blob/<commit>, for -format markdown to link findings to. Defaults to that of the GitHub Actions run")
+	flag.StringVar(&outputFile, "o", "", "Write the findings to this file instead of stdout, for the formats other than text and pretty, and everything else to stdout")
+	flag.StringVar(&minSeverity, "min-severity", "low", "Only report findings of at least this severity: low, medium or high")
+	flag.StringVar(&minConfidence, "min-confidence", "low", "Only report findings of at least this confidence: low, medium or high")
+	flag.StringVar(&tmpl, "template", "", "text/template to print each finding with, for -format template, e.g. '{{.File}}:{{.Line}} {{.Message}}'")
+	flag.StringVar(&pathMode, "path-mode", "", "How to print file names: relative (to the current directory), absolute or module (relative to the module root). Defaults to absolute for text output and relative for other formats")
+	flag.StringVar(&diffRef, "diff", "", "Only fail on findings on lines changed relative to this git ref")
+	flag.StringVar(&changedFilesList, "changed-files", "", "Only check the commands affected by the files listed in this file, one per line, or by those changed relative to the -diff ref (or HEAD) if it's \"git\"")
+	flag.BoolVar(&validateSQL, "validate-sql", false, "Also report constant queries which aren't valid SQL")
+	flag.BoolVar(&trustMigrations, "trust-migrations", true, "Treat files embedded in the binary, such as migrations, as constant queries, and don't check the queries migration tools run")
+	flag.StringVar(&dialectName, "dialect", "", "SQL dialect of the queries, for -validate-sql and -fix: mysql, postgres, sqlite, clickhouse, sqlserver or oracle. Defaults to that of the drivers passed to sql.Open and the database packages used")
+	flag.BoolVar(&suggestSinks, "suggest-sinks", false, "Instead of checking the packages, print the packages they use whose methods look like they take queries, for the configuration file")
+	flag.BoolVar(&fix, "fix", false, "Rewrite simple non-constant queries to use placeholders")
+	flag.BoolVar(&watch, "watch", false, "Run again whenever a Go file in the packages changes")
+	flag.StringVar(&file, "file", "", "Check the package containing this file instead of the given packages, and only report findings in the file")
+	flag.BoolVar(&stdin, "stdin", false, "Read the contents of the -file from standard input")
+	flag.StringVar(&tags, "tags", goflagsTags(), "Comma-separated build tags to load the packages with, as for go build. Defaults to those in GOFLAGS")
+	flag.StringVar(&goos, "goos", "", "Operating system to load the packages for, if not GOOS")
+	flag.StringVar(&goarch, "goarch", "", "Architecture to load the packages for, if not GOARCH")
+	flag.BoolVar(&allCommands, "all-commands", false, "Also check every main package in the module the current directory is in, together in one program, and say which commands each finding is in")
+	flag.BoolVar(&tests, "tests", false, "Also load the packages' tests, and analyze them as entry points of the program, like the commands' main functions")
+	flag.BoolVar(&workspace, "workspace", false, "Also check all of the packages in every module of the go.work workspace the current directory is in, together")
+	flag.StringVar(&excludeDirs, "exclude-dirs", "", "Comma-separated globs of directories, relative to the current directory, to leave out when expanding patterns ending in /...")
+	flag.BoolVar(&setExitStatus, "set-exit-status", true, "Exit with status 1 if the run fails; if false, only report findings")
+	flag.DurationVar(&timeout, "timeout", 0, "Stop with an error if the analysis takes longer than this, e.g. 10m. Zero means no timeout")
+	flag.DurationVar(&budget, "budget", 0, "If the call graph isn't built this long after the run started, e.g. 30s, build it at fast precision instead. Zero means no budget")
+	flag.StringVar(&precisionName, "precision", "max", "How precisely to build the call graph: fast (class hierarchy analysis), balanced (variable type analysis) or max (pointer analysis)")
+	flag.StringVar(&cpuprofile, "cpuprofile", "", "Write a CPU profile of the analysis to this file")
+	flag.StringVar(&memprofile, "memprofile", "", "Write a heap profile to this file once the analysis is done")
+	flag.BoolVar(&traceTimings, "trace-timings", false, "Print how long each phase of the run took")
+	flag.IntVar(&maxIssues, "max-issues", 0, "Only fail if there are more than this many findings")
+	flag.StringVar(&failOn, "fail-on", "low", "Only fail on findings of at least this severity: low, medium or high. Other findings are still reported")
+	flag.StringVar(&enableRules, "enable", "", "Comma-separated list of rules to report even if the configuration file disables them")
+	flag.StringVar(&disableRules, "disable", "", "Comma-separated list of rules not to report, e.g. SAFESQL004,SAFESQL005")
+	flag.StringVar(&ruleSeverities, "severity", "", "Comma-separated list of rule=level pairs overriding the severities of rules' findings, e.g. SAFESQL005=low")
 	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "Usage: %s [-q] [-v] package1 [package2 ...]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Usage: %s [flags] package1 [package2 ...]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s [flags] -file file.go\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s doctor [flags] package1 [package2 ...]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s serve [flags] package1 [package2 ...]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s explain [rule ...]\n", os.Args[0])
 		flag.PrintDefaults()
 	}
 
 	flag.Parse()
+	start := time.Now()
 	pkgs := flag.Args()
+	wd, _ := os.Getwd()
+
+	// onlyFile is the absolute name of the -file, if any.
+	var onlyFile string
+	if file != "" {
+		if len(pkgs) > 0 {
+			fmt.Fprintln(os.Stderr, "-file can't be combined with packages")
+			os.Exit(2)
+		}
+		abs, pkg, err := FilePackage(file, wd)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "-file: %v\n", err)
+			os.Exit(2)
+		}
+		onlyFile, pkgs = abs, []string{pkg}
+	} else if stdin {
+		fmt.Fprintln(os.Stderr, "-stdin needs a -file to name the file it reads")
+		os.Exit(2)
+	}
+	if workspace {
+		gowork := FindWorkspace(wd)
+		if gowork == "" {
+			fmt.Fprintln(os.Stderr, "-workspace: not in a go.work workspace")
+			os.Exit(2)
+		}
+		patterns, err := WorkspacePatterns(gowork, wd)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "-workspace: %v\n", err)
+			os.Exit(2)
+		}
+		pkgs = append(pkgs, patterns...)
+	}
+	var exclude []string
+	if excludeDirs != "" {
+		exclude = strings.Split(excludeDirs, ",")
+	}
+	ctxt := BuildContext(tags, goos, goarch)
+	if allCommands {
+		root, err := ModuleRoot(wd)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "-all-commands: %v\n", err)
+			os.Exit(2)
+		}
+		cmds, err := ModuleCommands(ctxt, root, wd, exclude, tests)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "-all-commands: %v\n", err)
+			os.Exit(2)
+		}
+		if len(cmds) == 0 {
+			fmt.Fprintf(os.Stderr, "-all-commands: no main packages in %s\n", root)
+			os.Exit(2)
+		}
+		pkgs = append(pkgs, cmds...)
+	}
 	if len(pkgs) == 0 {
 		flag.Usage()
 		os.Exit(2)
 	}
+	pkgs = ExpandPatterns(ctxt, wd, pkgs, exclude)
+	inFile := func(pos token.Position) bool {
+		return onlyFile == "" || pos.Filename == onlyFile
+	}
 
-	c := loader.Config{
-		FindPackage: FindPackage,
+	if watch {
+		if stdin {
+			fmt.Fprintln(os.Stderr, "-watch can't be combined with -stdin")
+			os.Exit(2)
+		}
+		if err := Watch(os.Stderr, ctxt, pkgs, flag.Args()); err != nil {
+			fmt.Fprintf(os.Stderr, "error watching packages: %v\n", err)
+		}
+		os.Exit(2)
 	}
-	for _, pkg := range pkgs {
-		c.Import(pkg)
+
+	// textRoot and reportRoot are the directories file names are printed
+	// relative to in text output and in reports respectively.
+	textRoot, reportRoot := "", wd
+	if pathMode != "" {
+		root, err := PathRoot(pathMode, wd)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "-path-mode: %v\n", err)
+			os.Exit(2)
+		}
+		textRoot, reportRoot = root, root
+	}
+	show := func(pos token.Position) token.Position {
+		return ShowPosition(textRoot, pos)
+	}
+
+	var out io.Writer = os.Stdout
+	var reporter Reporter
+	var printer *SnippetPrinter
+	switch format {
+	case "text":
+	case "pretty":
+		printer = NewSnippetPrinter(reportRoot, os.Stdout)
+	default:
+		var err error
+		if reporter, err = NewReporter(format, reportRoot, tmpl); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			flag.Usage()
+			os.Exit(2)
+		}
+		if mr, ok := reporter.(*MarkdownReport); ok && sourceURL != "" {
+			mr.SourceURL = sourceURL
+		}
+		if outputFile == "" {
+			out = os.Stderr
+		}
+	}
+	if outputFile != "" && reporter == nil {
+		fmt.Fprintf(os.Stderr, "-o needs a -format other than %s\n", format)
+		os.Exit(2)
+	}
+
+	logLevel := LogSilent
+	if verbose {
+		logLevel = LogVerbose
+	}
+	if debug {
+		logLevel = LogDebug
+	}
+	logger := NewLogger(out, logLevel)
+
+	severityThreshold, err := ParseLevel(minSeverity)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "-min-severity: %v\n", err)
+		os.Exit(2)
+	}
+	confidenceThreshold, err := ParseLevel(minConfidence)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "-min-confidence: %v\n", err)
+		os.Exit(2)
+	}
+	failThreshold, err := ParseLevel(failOn)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "-fail-on: %v\n", err)
+		os.Exit(2)
 	}
-	p, err := c.Load()
 
+	precision, err := ParsePrecision(precisionName)
 	if err != nil {
-		fmt.Printf("error loading packages %v: %v\n", pkgs, err)
+		fmt.Fprintf(os.Stderr, "-precision: %v\n", err)
 		os.Exit(2)
 	}
 
-	imports := getImports(p)
-	existOne := false
-	for i := range sqlPackages {
-		if _, exist := imports[sqlPackages[i].packageName]; exist {
-			if verbose {
-				fmt.Printf("Enabling support for %s\n", sqlPackages[i].packageName)
-			}
-			sqlPackages[i].enable = true
-			existOne = true
+	var explicitDialect Dialect
+	if dialectName != "" {
+		if explicitDialect, err = ParseDialect(dialectName); err != nil {
+			fmt.Fprintf(os.Stderr, "-dialect: %v\n", err)
+			os.Exit(2)
 		}
 	}
-	if !existOne {
-		fmt.Printf("No packages in %v include a supported database driver", pkgs)
+
+	config, err := LoadConfig(configFile)
+	if err != nil {
+		fmt.Fprintf(out, "error loading configuration: %v\n", err)
+		os.Exit(2)
+	}
+	if err := config.LoadBundles(ctxt); err != nil {
+		fmt.Fprintf(out, "error loading configuration: %v\n", err)
+		os.Exit(2)
+	}
+	if err := config.SetRules(enableRules, disableRules, ruleSeverities); err != nil {
+		fmt.Fprintln(os.Stderr, err)
 		os.Exit(2)
 	}
+	for _, pkg := range config.Packages {
+		sqlPackages = append(sqlPackages, sqlPackage{packageName: pkg.Package, paramNames: pkg.Params})
+	}
 
-	s := ssautil.CreateProgram(p, 0)
-	s.Build()
+	var gosec *GosecReport
+	if gosecFile != "" {
+		if gosec, err = ReadGosecReport(gosecFile, wd); err != nil {
+			fmt.Fprintf(out, "error reading gosec report: %v\n", err)
+			os.Exit(2)
+		}
+	}
 
-	qms := make([]*QueryMethod, 0)
+	var baseline *Baseline
+	switch baselineMode {
+	case "":
+	case "write":
+		baseline = &Baseline{}
+	case "check":
+		baseline, err = ReadBaseline(baselineFile)
+		if err != nil {
+			fmt.Fprintf(out, "error reading baseline: %v\n", err)
+			os.Exit(2)
+		}
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
 
-	for i := range sqlPackages {
-		if sqlPackages[i].enable {
-			qms = append(qms, FindQueryMethods(sqlPackages[i], p.Package(sqlPackages[i].packageName).Pkg, s)...)
+	var changed ChangedLines
+	if diffRef != "" {
+		changed, err = GitChangedLines(diffRef)
+		if err != nil {
+			fmt.Fprintf(out, "error computing changes relative to %s: %v\n", diffRef, err)
+			os.Exit(2)
 		}
 	}
 
-	if verbose {
-		fmt.Println("database driver functions that accept queries:")
-		for _, m := range qms {
-			fmt.Printf("- %s (param %d)\n", m.Func, m.Param)
+	// changedFiles is the set of files -changed-files names, if any.
+	var changedFiles map[string]bool
+	switch changedFilesList {
+	case "":
+	case "git":
+		ref := diffRef
+		if ref == "" {
+			ref = "HEAD"
+		}
+		if changedFiles, err = GitChangedFiles(ref); err != nil {
+			fmt.Fprintf(out, "error computing changed files relative to %s: %v\n", ref, err)
+			os.Exit(2)
+		}
+	default:
+		if changedFiles, err = ReadChangedFiles(changedFilesList, wd); err != nil {
+			fmt.Fprintf(out, "error reading changed files: %v\n", err)
+			os.Exit(2)
 		}
-		fmt.Println()
 	}
 
-	mains := FindMains(p, s)
-	if len(mains) == 0 {
-		fmt.Println("Did not find any commands (i.e., main functions).")
+	c := loader.Config{
+		Build:       ctxt,
+		FindPackage: FindPackage,
+		ParserMode:  parser.ParseComments,
+	}
+	if stdin {
+		if c.Build, err = OverlayFile(ctxt, onlyFile, os.Stdin); err != nil {
+			fmt.Fprintf(out, "error reading standard input: %v\n", err)
+			os.Exit(2)
+		}
+	}
+	profiles, err := StartProfiles(cpuprofile, memprofile)
+	if err != nil {
+		fmt.Fprintf(out, "error starting profiles: %v\n", err)
 		os.Exit(2)
 	}
-
-	res, err := pointer.Analyze(&pointer.Config{
-		Mains:          mains,
-		BuildCallGraph: true,
-	})
+	deadline := StartDeadline(out, timeout, os.Exit)
+	var timings *Timings
+	if traceTimings {
+		timings = &Timings{}
+	}
+	deadline.Phase("loading packages")
+	timings.Phase("loading packages")
+	p, failures, err := LoadPackages(c, pkgs, tests, out)
 	if err != nil {
-		fmt.Printf("error performing pointer analysis: %v\n", err)
+		fmt.Fprintf(out, "error loading packages %v: %v\n", pkgs, err)
 		os.Exit(2)
 	}
+	if logger.Enabled(LogDebug) {
+		loaded := make([]string, 0, len(p.AllPackages))
+		for pkg := range p.AllPackages {
+			loaded = append(loaded, pkg.Path())
+		}
+		sort.Strings(loaded)
+		for _, path := range loaded {
+			logger.Debugf("loaded package %s", path)
+		}
+	}
+
+	if !c.Build.CgoEnabled {
+		for _, path := range CgoPackages(c.Build, p) {
+			fmt.Fprintf(out, "warning: cgo is disabled, so the files of %s which use it aren't analyzed; set CGO_ENABLED=1 to analyze them\n", path)
+		}
+	}
 
-	bad := FindNonConstCalls(res.CallGraph, qms)
+	if suggestSinks {
+		WriteSuggestedSinks(out, SuggestSinks(p))
+		os.Exit(0)
+	}
 
-	if len(bad) == 0 {
+	if auditFile != "" {
+		files := make([]*ast.File, 0)
+		for _, info := range p.InitialPackages() {
+			files = append(files, info.Files...)
+		}
+		entries := AuditSuppressions(p.Fset, files, config)
+		AddBlameAges(entries, time.Now())
+		for i := range entries {
+			entries[i].File = show(token.Position{Filename: entries[i].File}).Filename
+		}
+		if err := WriteAudit(auditFile, entries); err != nil {
+			fmt.Fprintf(out, "error writing suppression audit: %v\n", err)
+			os.Exit(2)
+		}
 		if !quiet {
-			fmt.Println(`You're safe from SQL injection! Yay \o/`)
+			fmt.Fprintf(out, "Wrote %d suppressions to %s\n", len(entries), auditFile)
 		}
-		return
 	}
 
-	if verbose {
-		fmt.Printf("Found %d potentially unsafe SQL statements:\n", len(bad))
+	opts := AnalysisOptions{
+		Tests:           tests,
+		Precision:       precision,
+		Budget:          budget,
+		Start:           start,
+		Commands:        allCommands,
+		ChangedFiles:    changedFiles,
+		OnlyFile:        onlyFile,
+		TrustMigrations: trustMigrations,
+		MinSeverity:     severityThreshold,
+		MinConfidence:   confidenceThreshold,
+		ConstQueries:    dumpFile != "" || queriesBaseline != "",
+		ValidateSQL:     validateSQL,
+		BaselineMode:    baselineMode,
+		Baseline:        baseline,
+		Changed:         changed,
+		DiffRef:         diffRef,
+		Gosec:           gosec,
+		Root:            textRoot,
+		Quiet:           quiet,
+		Logger:          logger,
+		Deadline:        deadline,
+		Timings:         timings,
+	}
+	if dialectName != "" {
+		opts.Dialect = &explicitDialect
+	}
+	a, err := Analyze(p, config, opts, out)
+	failures = append(failures, a.Failures...)
+	switch {
+	case err == errNoneAffected:
+		if !quiet {
+			fmt.Fprintln(out, "None of the commands are affected by the changed files.")
+		}
+		os.Exit(0)
+	case err == errNoDatabase:
+		fmt.Fprintf(out, "No packages in %v include a supported database driver\n", pkgs)
+		WriteFailures(out, failures)
+		os.Exit(2)
+	case err != nil:
+		fmt.Fprintf(out, "error: %v\n", err)
+		WriteFailures(out, failures)
+		if err == errNoneBuilt {
+			os.Exit(3)
+		}
+		os.Exit(2)
+	}
+	deadline.Stop()
+	if err := profiles.Stop(); err != nil {
+		fmt.Fprintf(out, "error writing profiles: %v\n", err)
+		os.Exit(2)
 	}
+	timings.Phase("reporting")
 
-	potentialBadStatements := []token.Position{}
-	for _, ci := range bad {
-		potentialBadStatements = append(potentialBadStatements, p.Fset.Position(ci.Pos()))
+	// The baseline is read first, so that the two can be the same file.
+	hasQueryChanges := false
+	if queriesBaseline != "" {
+		old, err := ReadQueryDump(queriesBaseline)
+		if err != nil {
+			fmt.Fprintf(out, "error reading queries baseline: %v\n", err)
+			os.Exit(2)
+		}
+		changed, removed := CompareQueries(old, DumpQueries(p.Fset, a.ConstQueries, reportRoot))
+		if len(changed) > 0 {
+			fmt.Fprintf(out, "Found %d constant queries which aren't in %s:\n", len(changed), queriesBaseline)
+			for _, q := range changed {
+				if q.Old != nil {
+					fmt.Fprintf(out, "- %s:%d:%d: query passed to %s changed from %q to %q\n", q.File, q.Line, q.Column, q.Method, q.Old.Query, q.Query)
+				} else {
+					fmt.Fprintf(out, "- %s:%d:%d: new query passed to %s: %q\n", q.File, q.Line, q.Column, q.Method, q.Query)
+				}
+			}
+			hasQueryChanges = true
+		}
+		// With -file, the other queries in the baseline aren't checked
+		// at all.
+		if onlyFile == "" {
+			for _, q := range removed {
+				logger.Verbosef("- %s:%d:%d: query passed to %s is no longer used: %q", q.File, q.Line, q.Column, q.Method, q.Query)
+			}
+		}
 	}
 
-	issues, err := CheckIssues(potentialBadStatements)
-	if err != nil {
-		fmt.Printf("error when checking for ignore comments: %v\n", err)
-		os.Exit(2)
+	if dumpFile != "" {
+		dumped := DumpQueries(p.Fset, a.ConstQueries, reportRoot)
+		if err := WriteQueryDump(dumpFile, dumped); err != nil {
+			fmt.Fprintf(out, "error writing queries: %v\n", err)
+			os.Exit(2)
+		}
+		if !quiet {
+			fmt.Fprintf(out, "Wrote %d queries to %s\n", len(dumped), dumpFile)
+		}
 	}
 
-	if verbose {
-		fmt.Println("Please ensure that all SQL queries you use are compile-time constants.")
-		fmt.Println("You should always use parameterized queries or prepared statements")
-		fmt.Println("instead of building queries from strings.")
+	// Analyze has written the suppressed findings as it found them.
+	reported := a.Results
+	for _, result := range reported {
+		if rule, _ := LookupRule(result.Rule); result.Suppressed || (quiet && rule.Informational) {
+			continue
+		}
+		if printer != nil {
+			printer.Print(out, result)
+			continue
+		}
+		fmt.Fprintf(out, "- %s %s: %s (%s severity, %s confidence)\n", show(result.Position), result.Rule, result.Message, result.Severity, result.Confidence)
+		for _, step := range result.Steps() {
+			fmt.Fprintf(out, "  %s at %s\n", step.Message, show(step.Position))
+		}
+		if result.Fix != nil {
+			fmt.Fprintf(out, "  suggested fix: %s\n", result.Fix.Message)
+		}
+		if len(result.Commands) > 0 {
+			fmt.Fprintf(out, "  in %s\n", strings.Join(result.Commands, ", "))
+		}
 	}
 
-	hasNonIgnoredUnsafeStatement := false
+	if a.Suppressed.Total() > 0 {
+		a.Suppressed.Write(out)
+	}
+	if a.BelowThreshold > 0 && !quiet {
+		fmt.Fprintf(out, "Left out %d findings below -min-severity %s or -min-confidence %s\n", a.BelowThreshold, severityThreshold, confidenceThreshold)
+	}
 
-	for _, issue := range issues {
-		if issue.ignored {
-			fmt.Printf("- %s is potentially unsafe but ignored by comment\n", issue.statement)
-		} else {
-			fmt.Printf("- %s\n", issue.statement)
-			hasNonIgnoredUnsafeStatement = true
+	if fix {
+		fixes := make([]*Fix, 0)
+		for _, result := range reported {
+			if !result.Suppressed && result.Fix != nil {
+				fixes = append(fixes, result.Fix)
+			}
+		}
+		n, err := ApplyFixes(fixes)
+		if err != nil {
+			fmt.Fprintf(out, "error applying fixes: %v\n", err)
+			os.Exit(2)
+		}
+		if !quiet {
+			fmt.Fprintf(out, "Fixed %d potentially unsafe SQL statements in %d files\n", len(fixes), n)
 		}
 	}
 
-	if hasNonIgnoredUnsafeStatement {
-		os.Exit(1)
+	if baselineMode == "write" {
+		writeBaseline(out, baseline, baselineFile)
 	}
-}
 
-// QueryMethod represents a method on a type which has a string parameter named
-// "query".
-type QueryMethod struct {
-	Func     *types.Func
-	SSA      *ssa.Function
-	ArgCount int
-	Param    int
+	hasUnusedSuppression := false
+	if unusedSuppressions {
+		initialFiles := make([]*ast.File, 0)
+		for _, info := range p.InitialPackages() {
+			initialFiles = append(initialFiles, info.Files...)
+		}
+		for _, pos := range a.Suppressor.Unused(initialFiles) {
+			if !inFile(pos) {
+				continue
+			}
+			fmt.Fprintf(out, "- %s has an ignore comment which doesn't ignore anything\n", show(pos))
+			hasUnusedSuppression = true
+		}
+	}
+
+	stats := NewStats()
+	stats.Packages, stats.Sinks, stats.CallSites, stats.ConstCallSites = len(p.AllPackages), len(a.QueryMethods), a.Checked, a.ConstChecked
+	for _, result := range reported {
+		if !result.Suppressed {
+			stats.AddFinding(result)
+		}
+	}
+	stats.Suppressed = a.Suppressed.Total()
+	stats.Duration = time.Since(start)
+	if !quiet {
+		stats.Write(out)
+	}
+	if metricsFile != "" {
+		if err := WriteMetrics(metricsFile, stats); err != nil {
+			fmt.Fprintf(out, "error writing metrics: %v\n", err)
+			os.Exit(2)
+		}
+	}
+
+	if reporter != nil {
+		if sr, ok := reporter.(StatsReporter); ok {
+			sr.SetStats(stats)
+		}
+		SortResults(reported)
+		for _, result := range reported {
+			reporter.Add(result)
+		}
+		if err := writeReport(reporter, outputFile); err != nil {
+			fmt.Fprintf(out, "error writing %s report: %v\n", format, err)
+			os.Exit(2)
+		}
+	}
+
+	timings.Write(out)
+
+	failing := 0
+	for _, result := range reported {
+		if rule, _ := LookupRule(result.Rule); !result.Suppressed && !rule.Informational && result.Severity >= failThreshold {
+			failing++
+		}
+	}
+	if failing > 0 && failing <= maxIssues && !quiet {
+		fmt.Fprintf(out, "Not failing: %d potentially unsafe SQL statements is within -max-issues %d\n", failing, maxIssues)
+	}
+	WriteFailures(out, failures)
+	if setExitStatus && (failing > maxIssues || hasUnusedSuppression || hasQueryChanges) {
+		os.Exit(1)
+	}
+	if len(failures) > 0 {
+		// Packages were left out, so the run is incomplete even if what
+		// was analyzed is safe.
+		os.Exit(3)
+	}
+	if failing == 0 && !quiet {
+		fmt.Fprintln(out, `You're safe from SQL injection! Yay \o/`)
+	}
 }
 
-type Issue struct {
-	statement token.Position
-	ignored   bool
+func writeBaseline(out io.Writer, baseline *Baseline, path string) {
+	if err := baseline.Write(path); err != nil {
+		fmt.Fprintf(out, "error writing baseline: %v\n", err)
+		os.Exit(2)
+	}
+	fmt.Fprintf(out, "Wrote %d baseline entries to %s\n", len(baseline.Findings), path)
 }
 
-// CheckIssues checks lines to see if the line before or the current line has an ignore comment and marks those
-// statements that have the ignore comment on the current line or the line before
-func CheckIssues(lines []token.Position) ([]Issue, error) {
-	files := make(map[string][]token.Position)
+// RulePrefix is the prefix of all rule identifiers.
+const RulePrefix = "SAFESQL"
 
-	for _, line := range lines {
-		files[line.Filename] = append(files[line.Filename], line)
-	}
+// RuleNonConstQuery identifies the rule that queries must be compile-time
+// constants.
+const RuleNonConstQuery = RulePrefix + "001"
 
-	issues := []Issue{}
+// RuleInvalidSQL identifies the rule that constant queries must be valid SQL.
+const RuleInvalidSQL = RulePrefix + "002"
 
-	for file, linesInFile := range files {
-		// ensure we have the lines in ascending order
-		sort.Slice(linesInFile, func(i, j int) bool { return linesInFile[i].Line < linesInFile[j].Line })
+// RuleRequestDSN identifies the rule that data source names mustn't be built
+// from HTTP requests.
+const RuleRequestDSN = RulePrefix + "003"
 
-		data, err := ioutil.ReadFile(file)
-		if err != nil {
-			return nil, err
-		}
-		fileLines := strings.Split(string(data), "\n")
+// RuleUncheckedHandle identifies the informational rule that database handles
+// which escape into reflection or unsafe code can't be checked.
+const RuleUncheckedHandle = RulePrefix + "004"
 
-		for _, line := range linesInFile {
-			// check the line before the problematic statement first
-			potentialCommentLine := line.Line - 2
+// RuleDynamicDDL identifies the rule that DDL statements, which create, alter
+// or drop tables and schemas, must be compile-time constants.
+const RuleDynamicDDL = RulePrefix + "005"
 
-			// check only if the previous line is strictly a line that begins with
-			// the ignore comment
-			if 0 <= potentialCommentLine && BeginsWithComment(fileLines[potentialCommentLine]) {
-				issues = append(issues, Issue{statement: line, ignored: true})
-				continue
-			}
+// RuleInList identifies the rule that the values of IN lists mustn't be
+// spliced into queries with fmt.Sprintf and strings.Join.
+const RuleInList = RulePrefix + "006"
 
-			isIgnored := HasIgnoreComment(fileLines[line.Line-1])
-			issues = append(issues, Issue{statement: line, ignored: isIgnored})
-		}
-	}
+// RuleSQLFormat identifies the opt-in audit rule that SQL built with
+// Sprintf-like functions should be passed to a database package safesql
+// knows.
+const RuleSQLFormat = RulePrefix + "007"
 
-	return issues, nil
+// NonConstCall is a call to a query method whose query is not a compile-time
+// constant.
+type NonConstCall struct {
+	Site   ssa.CallInstruction
+	Method *QueryMethod
+	// Query is the value passed as the query, or nil if we couldn't tell
+	// which argument it was.
+	Query ssa.Value
 }
 
-func BeginsWithComment(line string) bool {
-	return strings.HasPrefix(strings.TrimSpace(line), IgnoreComment)
+// QueryMethod represents a method on a type which has a string parameter named
+// "query", or a function which takes raw SQL.
+type QueryMethod struct {
+	Func     *types.Func
+	SSA      *ssa.Function
+	ArgCount int
+	Param    int
 }
 
-func HasIgnoreComment(line string) bool {
-	return strings.HasSuffix(strings.TrimSpace(line), IgnoreComment)
+// IsMethod reports whether m is a method, rather than a function, which is
+// only ever called statically.
+func (m *QueryMethod) IsMethod() bool {
+	return m.Func.Type().(*types.Signature).Recv() != nil
 }
 
 // FindQueryMethods locates all methods in the given package (assumed to be
-// package database/sql) with a string parameter named "query".
-func FindQueryMethods(sqlPackages sqlPackage, sql *types.Package, ssa *ssa.Program) []*QueryMethod {
+// one of the sqlPackages, as described by spec) with a parameter named like a
+// query, and the functions it names which take raw SQL.
+func FindQueryMethods(spec sqlPackage, pkg *types.Package, prog *ssa.Program) []*QueryMethod {
 	methods := make([]*QueryMethod, 0)
-	scope := sql.Scope()
-	for _, name := range scope.Names() {
-		o := scope.Lookup(name)
-		if !o.Exported() {
-			continue
+	for _, m := range ExportedMethods(pkg) {
+		s := m.Type().(*types.Signature)
+		if num, ok := FuncHasQuery(spec, s); ok {
+			methods = append(methods, &QueryMethod{
+				Func:     m,
+				SSA:      prog.FuncValue(m),
+				ArgCount: s.Params().Len(),
+				Param:    num,
+			})
 		}
-		if _, ok := o.(*types.TypeName); !ok {
+	}
+	for _, name := range spec.funcNames {
+		fn, ok := pkg.Scope().Lookup(name).(*types.Func)
+		if !ok {
 			continue
 		}
-		n := o.Type().(*types.Named)
-		for i := 0; i < n.NumMethods(); i++ {
-			m := n.Method(i)
-			if !m.Exported() {
-				continue
-			}
-			s := m.Type().(*types.Signature)
-			if num, ok := FuncHasQuery(sqlPackages, s); ok {
+		s := fn.Type().(*types.Signature)
+		for i := 0; i < s.Params().Len(); i++ {
+			if types.Identical(s.Params().At(i).Type(), types.Typ[types.String]) {
 				methods = append(methods, &QueryMethod{
-					Func:     m,
-					SSA:      ssa.FuncValue(m),
+					Func:     fn,
+					SSA:      prog.FuncValue(fn),
 					ArgCount: s.Params().Len(),
-					Param:    num,
+					Param:    i,
 				})
+				break
+			}
+		}
+	}
+	return methods
+}
+
+// ExportedMethods returns the exported methods of the exported named types in
+// pkg, which are the ones other packages can call, in the order of the names
+// of the types and then in the order the methods are declared in.
+func ExportedMethods(pkg *types.Package) []*types.Func {
+	methods := make([]*types.Func, 0)
+	scope := pkg.Scope()
+	for _, name := range scope.Names() {
+		o, ok := scope.Lookup(name).(*types.TypeName)
+		if !ok || !o.Exported() || o.IsAlias() {
+			continue
+		}
+		n, ok := o.Type().(*types.Named)
+		if !ok {
+			continue
+		}
+		for i := 0; i < n.NumMethods(); i++ {
+			if m := n.Method(i); m.Exported() {
+				methods = append(methods, m)
 			}
 		}
 	}
@@ -268,11 +898,11 @@ func FindQueryMethods(sqlPackages sqlPackage, sql *types.Package, ssa *ssa.Progr
 
 // FuncHasQuery returns the offset of the string parameter named "query", or
 // none if no such parameter exists.
-func FuncHasQuery(sqlPackages sqlPackage, s *types.Signature) (offset int, ok bool) {
+func FuncHasQuery(spec sqlPackage, s *types.Signature) (offset int, ok bool) {
 	params := s.Params()
 	for i := 0; i < params.Len(); i++ {
 		v := params.At(i)
-		for _, paramName := range sqlPackages.paramNames {
+		for _, paramName := range spec.paramNames {
 			if v.Name() == paramName {
 				return i, true
 			}
@@ -295,6 +925,211 @@ func FindMains(p *loader.Program, s *ssa.Program) []*ssa.Package {
 	return mains
 }
 
+// DatabaseMains returns those of the given main packages which import one of
+// the enabled sqlPackages, directly or indirectly, since only they can call
+// its query methods. If none of them do, it returns all of them, so that
+// there's still a program to analyze.
+func DatabaseMains(mains []*ssa.Package) []*ssa.Package {
+	dbMains := mainsImporting(mains, func(pkg *types.Package) bool {
+		for _, sp := range sqlPackages {
+			if sp.enable && sp.packageName == pkg.Path() {
+				return true
+			}
+		}
+		return false
+	})
+	if len(dbMains) == 0 {
+		return mains
+	}
+	return dbMains
+}
+
+// mainsImporting returns those of the given main packages which import a
+// package match reports true for, directly or indirectly.
+func mainsImporting(mains []*ssa.Package, match func(pkg *types.Package) bool) []*ssa.Package {
+	memo := make(map[*types.Package]bool)
+	var imports func(pkg *types.Package) bool
+	imports = func(pkg *types.Package) bool {
+		if found, ok := memo[pkg]; ok {
+			return found
+		}
+		memo[pkg] = false // for import cycles, which only unsafe makes possible
+		found := match(pkg)
+		for _, imp := range pkg.Imports() {
+			if !found && imports(imp) {
+				found = true
+			}
+		}
+		memo[pkg] = found
+		return found
+	}
+
+	matched := make([]*ssa.Package, 0, len(mains))
+	for _, m := range mains {
+		if imports(m.Pkg) {
+			matched = append(matched, m)
+		}
+	}
+	return matched
+}
+
+// An SSAError is a package whose SSA couldn't be built.
+type SSAError struct {
+	Pkg   *ssa.Package
+	Panic interface{}
+}
+
+func (e SSAError) Error() string {
+	return fmt.Sprintf("building SSA for %s: %v", e.Pkg.Pkg.Path(), e.Panic)
+}
+
+// RangeOverFunc returns the position of the first range statement in the
+// package which ranges over a function, i.e. an iterator, or token.NoPos if
+// there's none. Package ssa can't build these before golang.org/x/tools
+// supported Go 1.23, and panics on them.
+func RangeOverFunc(info *loader.PackageInfo) token.Pos {
+	pos := token.NoPos
+	for _, f := range info.Files {
+		ast.Inspect(f, func(n ast.Node) bool {
+			if pos.IsValid() {
+				return false
+			}
+			if r, ok := n.(*ast.RangeStmt); ok {
+				if t := info.TypeOf(r.X); t != nil {
+					if _, ok := t.Underlying().(*types.Signature); ok {
+						pos = r.For
+					}
+				}
+			}
+			return true
+		})
+		if pos.IsValid() {
+			break
+		}
+	}
+	return pos
+}
+
+// BuildImported builds the SSA of the given packages and of the packages they
+// import, directly or indirectly, in parallel. Only these are reachable from
+// main packages, so the rest of the program needn't be built.
+//
+// Package ssa panics on some programs it doesn't support, e.g. with unusual
+// cgo, and may leave locks on the whole program held when it does, so that
+// nothing else can be built. The first such panic is returned as an error,
+// naming the package, without waiting for the packages still being built.
+func BuildImported(pkgs []*ssa.Package) error {
+	seen := make(map[*ssa.Package]bool)
+	var wg sync.WaitGroup
+	failed := make(chan error, 1)
+	var visit func(pkg *ssa.Package)
+	visit = func(pkg *ssa.Package) {
+		if pkg == nil || seen[pkg] {
+			return
+		}
+		seen[pkg] = true
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					select {
+					case failed <- SSAError{Pkg: pkg, Panic: r}:
+					default:
+					}
+				}
+			}()
+			pkg.Build()
+		}()
+		for _, imp := range pkg.Pkg.Imports() {
+			visit(pkg.Prog.Package(imp))
+		}
+	}
+	for _, pkg := range pkgs {
+		visit(pkg)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		select {
+		case err := <-failed:
+			return err
+		default:
+			return nil
+		}
+	case err := <-failed:
+		return err
+	}
+}
+
+// CreateMains creates the SSA program of p, without building it, and returns
+// its main packages: its commands and, if tests is true, the test mains of its
+// packages.
+func CreateMains(p *loader.Program, tests bool) (*ssa.Program, []*ssa.Package, error) {
+	s := ssautil.CreateProgram(p, 0)
+	mains := FindMains(p, s)
+	if tests {
+		testMains, err := TestMains(p, s)
+		if err != nil {
+			return nil, nil, err
+		}
+		mains = append(mains, testMains...)
+	}
+	return s, mains, nil
+}
+
+// BuildMains builds the given main packages of s, created by CreateMains, and
+// the packages they import, as BuildImported does. When package ssa can't
+// build one of those packages, rather than failing, it warns and leaves out
+// the main packages which import it, and builds the others again in a program
+// created anew, since the failure may have left s unusable. It returns the
+// program and the main packages it built, and the packages it couldn't build.
+func BuildMains(p *loader.Program, tests bool, s *ssa.Program, mains []*ssa.Package, warn io.Writer) (*ssa.Program, []*ssa.Package, []PackageFailure, error) {
+	var failures []PackageFailure
+	for len(mains) > 0 {
+		err := BuildImported(mains)
+		if err == nil {
+			break
+		}
+		serr, ok := err.(SSAError)
+		if !ok {
+			return nil, nil, failures, err
+		}
+		failed := serr.Pkg.Pkg.Path()
+		fmt.Fprintf(warn, "skipping the commands which import %s: %v\n", failed, err)
+		if info := p.AllPackages[serr.Pkg.Pkg]; info != nil {
+			if pos := RangeOverFunc(info); pos.IsValid() {
+				fmt.Fprintf(warn, "%s ranges over a function, which the golang.org/x/tools safesql is built with may not support; build safesql with a newer golang.org/x/tools to analyze iterators\n", p.Fset.Position(pos))
+			}
+		}
+		failures = append(failures, PackageFailure{failed, fmt.Errorf("package ssa can't build it: %v", serr.Panic)})
+
+		keep := make(map[string]bool, len(mains))
+		for _, m := range mains {
+			keep[m.Pkg.Path()] = true
+		}
+		for _, m := range mainsImporting(mains, func(pkg *types.Package) bool { return pkg.Path() == failed }) {
+			delete(keep, m.Pkg.Path())
+		}
+		var all []*ssa.Package
+		if s, all, err = CreateMains(p, tests); err != nil {
+			return nil, nil, failures, err
+		}
+		mains = make([]*ssa.Package, 0, len(keep))
+		for _, m := range all {
+			if keep[m.Pkg.Path()] {
+				mains = append(mains, m)
+			}
+		}
+	}
+	return s, mains, failures, nil
+}
+
 func getImports(p *loader.Program) map[string]interface{} {
 	pkgs := make(map[string]interface{})
 	for _, pkg := range p.AllPackages {
@@ -306,8 +1141,67 @@ func getImports(p *loader.Program) map[string]interface{} {
 }
 
 // FindNonConstCalls returns the set of callsites of the given set of methods
-// for which the "query" parameter is not a compile-time constant.
-func FindNonConstCalls(cg *callgraph.Graph, qms []*QueryMethod) []ssa.CallInstruction {
+// for which the "query" parameter is not a compile-time constant, and the
+// number of callsites it checked.
+func FindNonConstCalls(cg *callgraph.Graph, qms []*QueryMethod, cc *ConstChecker) ([]NonConstCall, int) {
+	bad := make([]NonConstCall, 0)
+	checked := 0
+	forEachQueryCall(cg, qms, func(site ssa.CallInstruction, m *QueryMethod, v ssa.Value) {
+		// Migration tools run the migrations they're given, which are
+		// checked where they're built, if they're built at all.
+		if fn := site.Parent(); cc.TrustMigrations && fn.Pkg != nil && isMigrationPackage(fn.Pkg.Pkg.Path()) {
+			return
+		}
+		checked++
+		if v == nil {
+			// We couldn't work out which operand is the query. Err on
+			// the side of caution and report the call site rather than
+			// silently letting it through.
+			bad = append(bad, NonConstCall{Site: site, Method: m})
+			return
+		}
+		if !cc.IsConst(v) {
+			bad = append(bad, NonConstCall{Site: site, Method: m, Query: v})
+		}
+	})
+	return bad, checked
+}
+
+// A ConstQuery is a call to a query method whose query is a compile-time
+// constant.
+type ConstQuery struct {
+	Site   ssa.CallInstruction
+	Method *QueryMethod
+	// Values are the queries the call can be passed, e.g. one for each
+	// branch of an if statement choosing between two.
+	Values []string
+}
+
+// FindConstQueries returns the calls to the given methods whose queries are
+// compile-time constants.
+func FindConstQueries(cg *callgraph.Graph, qms []*QueryMethod, cc *ConstChecker) []ConstQuery {
+	queries := make([]ConstQuery, 0)
+	forEachQueryCall(cg, qms, func(site ssa.CallInstruction, m *QueryMethod, v ssa.Value) {
+		if v == nil {
+			return
+		}
+		if values, ok := cc.ConstValues(v); ok && len(values) > 0 {
+			queries = append(queries, ConstQuery{Site: site, Method: m, Values: values})
+		}
+	})
+	return queries
+}
+
+// forEachQueryCall calls f for each call to one of the given methods in the
+// call graph, once per call, with the value passed as its query, or nil if we
+// couldn't tell which operand that is. Calls within the SQL packages
+// themselves, and calls which pass something other than a string as the
+// query, are skipped.
+func forEachQueryCall(cg *callgraph.Graph, qms []*QueryMethod, f func(site ssa.CallInstruction, m *QueryMethod, v ssa.Value)) {
+	// Method expressions like (*sql.DB).Query and method values like
+	// db.Query are called through synthetic thunks and bound-method
+	// wrappers. Deleting them connects the user's call site directly to the
+	// method, and QueryArg takes care of the resulting argument shapes.
 	cg.DeleteSyntheticNodes()
 
 	// package database/sql has a couple helper functions which are thin
@@ -320,7 +1214,10 @@ func FindNonConstCalls(cg *callgraph.Graph, qms []*QueryMethod) []ssa.CallInstru
 		okFuncs[m.SSA] = struct{}{}
 	}
 
-	bad := make([]ssa.CallInstruction, 0)
+	// A dynamic call can have several of the query methods as callees, e.g.
+	// an interface method implemented by both *sql.DB and *sql.Tx, but
+	// it's still only one call to check.
+	checked := make(map[ssa.CallInstruction]bool)
 	for _, m := range qms {
 		node := cg.CreateNode(m.SSA)
 		for _, edge := range node.In {
@@ -328,40 +1225,703 @@ func FindNonConstCalls(cg *callgraph.Graph, qms []*QueryMethod) []ssa.CallInstru
 				continue
 			}
 
-			isInternalSQLPkg := false
-			for _, pkg := range sqlPackages {
-				if pkg.packageName == edge.Caller.Func.Pkg.Pkg.Path() {
-					isInternalSQLPkg = true
-					break
-				}
+			if isSQLPackage(edge.Caller.Func.Pkg.Pkg.Path()) || checked[edge.Site] {
+				continue
+			}
+			checked[edge.Site] = true
+
+			// Queries run against a mock database, e.g. by test helpers,
+			// don't reach a real one.
+			if recv, ok := queryReceiver(edge.Site.Common()); ok && fromMock(recv, make(map[ssa.Value]bool)) {
+				continue
+			}
+
+			v, ok := QueryArg(edge.Site.Common(), m)
+			if !ok {
+				f(edge.Site, m, nil)
+				continue
 			}
-			if isInternalSQLPkg {
+
+			// Some packages (e.g. gorm) take the query as an interface{},
+			// which can also be a struct or map of conditions, or an
+			// expression of a query builder, which is checked where it's
+			// made.
+			if inter, ok := v.(*ssa.MakeInterface); ok && inter.X.Type() != types.Typ[types.String] {
 				continue
 			}
+			if change, ok := v.(*ssa.ChangeInterface); ok && change.X.Type().Underlying().(*types.Interface).NumMethods() > 0 {
+				continue
+			}
+
+			f(edge.Site, m, v)
+		}
+	}
+}
+
+// queryReceiver returns the receiver of a call to a method, statically or
+// through an interface.
+func queryReceiver(cc *ssa.CallCommon) (ssa.Value, bool) {
+	if cc.IsInvoke() {
+		return cc.Value, true
+	}
+	if cc.Signature().Recv() != nil && len(cc.Args) > 0 {
+		return cc.Args[0], true
+	}
+	return nil, false
+}
+
+// fromMock reports whether v is a database handle opened by a mock database
+// package, or a transaction, connection or statement of one.
+func fromMock(v ssa.Value, seen map[ssa.Value]bool) bool {
+	if seen[v] {
+		return false
+	}
+	seen[v] = true
+	switch v := v.(type) {
+	case *ssa.Extract:
+		return fromMock(v.Tuple, seen)
+	case *ssa.Call:
+		callee := v.Call.StaticCallee()
+		if callee == nil || callee.Pkg == nil {
+			return false
+		}
+		spec, ok := lookupSQLPackage(callee.Pkg.Pkg.Path())
+		if !ok {
+			return false
+		}
+		if spec.mock {
+			return true
+		}
+		// e.g. the transaction returned by Begin.
+		recv, ok := queryReceiver(&v.Call)
+		return ok && fromMock(recv, seen)
+	case *ssa.Phi:
+		for _, e := range v.Edges {
+			if !fromMock(e, seen) {
+				return false
+			}
+		}
+		return len(v.Edges) > 0
+	case *ssa.MakeInterface:
+		return fromMock(v.X, seen)
+	case *ssa.ChangeInterface:
+		return fromMock(v.X, seen)
+	case *ssa.ChangeType:
+		return fromMock(v.X, seen)
+	}
+	return false
+}
+
+// GroupByQuery groups calls which are passed the same query, so that a query
+// which is built once and then used several times is reported once. The groups
+// are in the order of their first calls.
+func GroupByQuery(calls []NonConstCall) [][]NonConstCall {
+	groups := make([][]NonConstCall, 0, len(calls))
+	index := make(map[ssa.Value]int)
+	for _, ci := range calls {
+		origin := queryOrigin(ci.Query)
+		if i, ok := index[origin]; ok && origin != nil {
+			groups[i] = append(groups[i], ci)
+			continue
+		}
+		index[origin] = len(groups)
+		groups = append(groups, []NonConstCall{ci})
+	}
+	return groups
+}
+
+// queryOrigin returns the value a query is converted from, if any, so that a
+// string passed as an interface{} to one method and as a string to another
+// counts as the same query.
+func queryOrigin(v ssa.Value) ssa.Value {
+	for {
+		switch x := v.(type) {
+		case *ssa.MakeInterface:
+			v = x.X
+		case *ssa.ChangeType:
+			v = x.X
+		default:
+			return v
+		}
+	}
+}
+
+// FindMultiStatementOpens returns the positions of calls to sql.Open (and its
+// sqlx equivalents) whose driver name and data source name are constants that
+// enable executing several statements in a single Exec. We can't tell which
+// handle a given Exec uses, so a single such call is enough for us to warn
+// about every non-constant Exec in the program.
+func FindMultiStatementOpens(s *ssa.Program) []token.Pos {
+	opens := make([]token.Pos, 0)
+	for _, site := range openCalls(s) {
+		driver, ok := stringConst(site.Common().Args[0])
+		if !ok {
+			continue
+		}
+		dsn, ok := stringConst(site.Common().Args[1])
+		if !ok {
+			continue
+		}
+		if EnablesMultiStatements(driver, dsn) {
+			opens = append(opens, site.Pos())
+		}
+	}
+	return opens
+}
+
+// openCalls returns the calls to sql.Open and its sqlx equivalents, which
+// take the driver name and the data source name as their first two arguments.
+func openCalls(s *ssa.Program) []ssa.CallInstruction {
+	calls := make([]ssa.CallInstruction, 0)
+	for fn := range ssautil.AllFunctions(s) {
+		if fn.Pkg == nil || isSQLPackage(fn.Pkg.Pkg.Path()) {
+			continue
+		}
+		for _, b := range fn.Blocks {
+			for _, instr := range b.Instrs {
+				site, ok := instr.(ssa.CallInstruction)
+				if !ok {
+					continue
+				}
+				callee := site.Common().StaticCallee()
+				if callee == nil || callee.Pkg == nil || !isOpenFunc(callee.Pkg.Pkg.Path(), callee.Name()) {
+					continue
+				}
+				if len(site.Common().Args) >= 2 {
+					calls = append(calls, site)
+				}
+			}
+		}
+	}
+	return calls
+}
+
+// stringConst returns the value of v if it's a string constant.
+func stringConst(v ssa.Value) (string, bool) {
+	c, ok := v.(*ssa.Const)
+	if !ok || c.Value == nil || c.Value.Kind() != constant.String {
+		return "", false
+	}
+	return constant.StringVal(c.Value), true
+}
+
+func isOpenFunc(pkg, name string) bool {
+	switch pkg {
+	case "database/sql":
+		return name == "Open"
+	case "github.com/jmoiron/sqlx":
+		return name == "Open" || name == "Connect" || name == "MustOpen" || name == "MustConnect"
+	}
+	return false
+}
+
+// FindRequestDSNs returns the calls to sql.Open (and its sqlx equivalents)
+// whose data source name is built from an HTTP request, which lets whoever
+// sends it choose the server to connect to, the credentials and options such
+// as multi-statement execution. They're found wherever they are, including in
+// the initialization of packages.
+func FindRequestDSNs(s *ssa.Program) []ssa.CallInstruction {
+	sites := make([]ssa.CallInstruction, 0)
+	for _, site := range openCalls(s) {
+		if fromRequest(site.Common().Args[1], make(map[ssa.Value]bool)) {
+			sites = append(sites, site)
+		}
+	}
+	return sites
+}
+
+// sqlFields are the struct fields which hold raw SQL, by package, type and
+// field name. Whatever is stored in them is run as it is, like the query of a
+// query method.
+var sqlFields = []struct{ packageName, typeName, fieldName string }{
+	{"gorm.io/gorm/clause", "Expr", "SQL"},
+	{"gorm.io/gorm/clause", "NamedExpr", "SQL"},
+	{"github.com/jackc/pgx/v5", "QueuedQuery", "SQL"},
+}
+
+// isSQLField reports whether the field of the struct type t at index is one
+// of the sqlFields.
+func isSQLField(t types.Type, index int) bool {
+	named, ok := deref(t).(*types.Named)
+	if !ok || named.Obj().Pkg() == nil {
+		return false
+	}
+	st, ok := named.Underlying().(*types.Struct)
+	if !ok || index >= st.NumFields() {
+		return false
+	}
+	for _, f := range sqlFields {
+		if named.Obj().Pkg().Path() == f.packageName && named.Obj().Name() == f.typeName && st.Field(index).Name() == f.fieldName {
+			return true
+		}
+	}
+	return false
+}
+
+// FindNonConstFields returns the stores of values which aren't compile-time
+// constants to the sqlFields, e.g. clause.Expr{SQL: s}, wherever they are.
+func FindNonConstFields(s *ssa.Program, cc *ConstChecker) []*ssa.Store {
+	stores := make([]*ssa.Store, 0)
+	for fn := range ssautil.AllFunctions(s) {
+		if fn.Pkg == nil || isSQLPackage(fn.Pkg.Pkg.Path()) {
+			continue
+		}
+		for _, b := range fn.Blocks {
+			for _, instr := range b.Instrs {
+				store, ok := instr.(*ssa.Store)
+				if !ok {
+					continue
+				}
+				addr, ok := store.Addr.(*ssa.FieldAddr)
+				if !ok || !isSQLField(addr.X.Type(), addr.Field) {
+					continue
+				}
+				if !cc.IsConst(store.Val) {
+					stores = append(stores, store)
+				}
+			}
+		}
+	}
+	return stores
+}
 
-			cc := edge.Site.Common()
-			args := cc.Args
-			// The first parameter is occasionally the receiver.
-			if len(args) == m.ArgCount+1 {
-				args = args[1:]
-			} else if len(args) != m.ArgCount {
-				panic("arg count mismatch")
+// EnablesMultiStatements reports whether a connection opened with the given
+// driver name and data source name allows several statements per Exec.
+func EnablesMultiStatements(driver, dsn string) bool {
+	lower := strings.ToLower(dsn)
+	switch driver {
+	case "mysql":
+		return strings.Contains(lower, "multistatements=true")
+	case "clickhouse":
+		return true
+	case "snowflake":
+		// A count of 0 allows any number of statements.
+		i := strings.Index(lower, "multi_statement_count=")
+		if i < 0 {
+			return false
+		}
+		count := lower[i+len("multi_statement_count="):]
+		if j := strings.IndexByte(count, '&'); j >= 0 {
+			count = count[:j]
+		}
+		return count != "1"
+	}
+	return false
+}
+
+// An UncheckedUse is a place where a value whose type has query methods is
+// handed to package reflect, or converted to an unsafe.Pointer, after which we
+// can no longer see what gets called.
+type UncheckedUse struct {
+	Site ssa.Instruction
+	Type types.Type
+	// Via is "reflect" or "unsafe".
+	Via string
+}
+
+// FindUncheckedUses returns the calls to reflect.ValueOf and the conversions
+// to unsafe.Pointer whose operand is a value of a type which has one of the
+// given methods, including types which embed one of the types they belong to,
+// and interfaces such as sqlx.Ext which have them through the interfaces they
+// embed. Calls made through the resulting reflect.Value (e.g.
+// MethodByName("Query").Call(...)) or pointer are invisible to the pointer
+// analysis, so these are reported as informational findings rather than
+// passing silently.
+//
+// A value is followed from where it's put in an interface which doesn't have
+// the methods, e.g. interface{}, to the calls to reflect.ValueOf it reaches:
+// through variables, fields and closures it's stored in, and the functions
+// it's passed to and returned from.
+func FindUncheckedUses(s *ssa.Program, qms []*QueryMethod) []UncheckedUse {
+	flow := newHandleFlow(s)
+	uses := make([]UncheckedUse, 0)
+	seen := make(map[ssa.Instruction]map[string]bool)
+	addReflect := func(site ssa.CallInstruction, t types.Type) {
+		if fn := site.Parent(); fn.Pkg != nil && isSQLPackage(fn.Pkg.Pkg.Path()) {
+			return
+		}
+		if seen[site] == nil {
+			seen[site] = make(map[string]bool)
+		}
+		if !seen[site][t.String()] {
+			seen[site][t.String()] = true
+			uses = append(uses, UncheckedUse{Site: site, Type: t, Via: "reflect"})
+		}
+	}
+	for fn := range ssautil.AllFunctions(s) {
+		if fn.Pkg == nil || isSQLPackage(fn.Pkg.Pkg.Path()) {
+			continue
+		}
+		for _, b := range fn.Blocks {
+			for _, instr := range b.Instrs {
+				var boxed ssa.Value
+				switch instr := instr.(type) {
+				case *ssa.Convert:
+					if types.Identical(instr.Type(), types.Typ[types.UnsafePointer]) && hasQueryMethod(instr.X.Type(), qms) {
+						uses = append(uses, UncheckedUse{Site: instr, Type: instr.X.Type(), Via: "unsafe"})
+					}
+					continue
+				case *ssa.MakeInterface:
+					boxed = instr.X
+				case *ssa.ChangeInterface:
+					boxed = instr.X
+				default:
+					continue
+				}
+				v := instr.(ssa.Value)
+				if !hasQueryMethod(boxed.Type(), qms) || hasQueryMethod(v.Type(), qms) {
+					continue
+				}
+				flow.reflectCalls(v, func(site ssa.CallInstruction) {
+					addReflect(site, boxed.Type())
+				})
 			}
-			v := args[m.Param]
+		}
+	}
+	return uses
+}
+
+// handleFlow follows values through the program without the pointer
+// analysis, which is only as precise as -precision asks for, if it's run at
+// all.
+type handleFlow struct {
+	globals *Globals
+	// calls are the static call sites of each function.
+	calls map[*ssa.Function][]ssa.CallInstruction
+	// fields are the fields, and the addresses of fields, of each struct
+	// field, by fieldKey.
+	fields map[string][]ssa.Value
+}
 
-			if _, ok := v.(*ssa.Const); !ok {
-				if inter, ok := v.(*ssa.MakeInterface); ok && types.IsInterface(v.(*ssa.MakeInterface).Type()) {
-					if inter.X.Referrers() == nil || inter.X.Type() != types.Typ[types.String] {
-						continue
+func newHandleFlow(s *ssa.Program) *handleFlow {
+	flow := &handleFlow{
+		globals: NewGlobals(s),
+		calls:   make(map[*ssa.Function][]ssa.CallInstruction),
+		fields:  make(map[string][]ssa.Value),
+	}
+	for fn := range ssautil.AllFunctions(s) {
+		for _, b := range fn.Blocks {
+			for _, instr := range b.Instrs {
+				switch instr := instr.(type) {
+				case ssa.CallInstruction:
+					if callee := instr.Common().StaticCallee(); callee != nil {
+						flow.calls[callee] = append(flow.calls[callee], instr)
 					}
+				case *ssa.FieldAddr:
+					key := fieldKey(instr.X.Type(), instr.Field)
+					flow.fields[key] = append(flow.fields[key], instr)
+				case *ssa.Field:
+					key := fieldKey(instr.X.Type(), instr.Field)
+					flow.fields[key] = append(flow.fields[key], instr)
 				}
+			}
+		}
+	}
+	return flow
+}
+
+// fieldKey identifies the field of the given struct type, or pointer to it,
+// with the given index.
+func fieldKey(t types.Type, field int) string {
+	return fmt.Sprintf("%s.%d", deref(t), field)
+}
+
+// reflectCalls calls found with each call to reflect.ValueOf which v reaches.
+func (f *handleFlow) reflectCalls(v ssa.Value, found func(ssa.CallInstruction)) {
+	w := &handleWalk{flow: f, seen: make(map[handleStep]bool), seenAddrs: make(map[ssa.Value]bool), found: found}
+	w.value(v, nil)
+}
+
+// A handleWalk follows one value through the program. Values which flow into
+// a function through a call and out of it again are returned to that call
+// only; the calls the walk is in are its stack, innermost last.
+type handleWalk struct {
+	flow      *handleFlow
+	seen      map[handleStep]bool
+	seenAddrs map[ssa.Value]bool
+	found     func(ssa.CallInstruction)
+}
 
-				bad = append(bad, edge.Site)
+// A handleStep is a value a walk reached, and the call it reached it in.
+type handleStep struct {
+	v    ssa.Value
+	call ssa.CallInstruction
+}
+
+// value follows v to the instructions it's used by.
+func (w *handleWalk) value(v ssa.Value, stack []ssa.CallInstruction) {
+	step := handleStep{v: v}
+	if len(stack) > 0 {
+		step.call = stack[len(stack)-1]
+	}
+	if w.seen[step] {
+		return
+	}
+	w.seen[step] = true
+	refs := v.Referrers()
+	if refs == nil {
+		return
+	}
+	for _, ref := range *refs {
+		switch ref := ref.(type) {
+		case ssa.CallInstruction:
+			w.call(ref, v, stack)
+		case *ssa.Return:
+			for i, r := range ref.Results {
+				if r == v {
+					w.result(ref.Parent(), i, len(ref.Results), stack)
+				}
+			}
+		case *ssa.Store:
+			if ref.Val == v {
+				w.addr(ref.Addr)
+			}
+		case *ssa.MakeClosure:
+			fn := ref.Fn.(*ssa.Function)
+			for i, b := range ref.Bindings {
+				if b == v {
+					w.value(fn.FreeVars[i], nil)
+				}
+			}
+		case *ssa.Phi:
+			w.value(ref, stack)
+		case *ssa.ChangeInterface:
+			w.value(ref, stack)
+		case *ssa.TypeAssert:
+			if ref.CommaOk {
+				w.extract(ref, 0, stack)
+			} else {
+				w.value(ref, stack)
 			}
 		}
 	}
+}
 
-	return bad
+// call follows v into the function it's passed to by call, unless it's
+// reflect.ValueOf, which is what the walk looks for.
+func (w *handleWalk) call(call ssa.CallInstruction, v ssa.Value, stack []ssa.CallInstruction) {
+	common := call.Common()
+	callee := common.StaticCallee()
+	if callee == nil {
+		return
+	}
+	if callee.Pkg != nil && callee.Pkg.Pkg.Path() == "reflect" && callee.Name() == "ValueOf" {
+		if common.Args[0] == v {
+			w.found(call)
+		}
+		return
+	}
+	inner := append(stack[:len(stack):len(stack)], call)
+	for i, arg := range common.Args {
+		if arg == v && i < len(callee.Params) {
+			w.value(callee.Params[i], inner)
+		}
+	}
+}
+
+// result follows the i'th of the n results of fn to the call on the stack it
+// was called by or, if the walk didn't enter fn through a call, to all of its
+// static callers.
+func (w *handleWalk) result(fn *ssa.Function, i, n int, stack []ssa.CallInstruction) {
+	sites := w.flow.calls[fn]
+	var outer []ssa.CallInstruction
+	if len(stack) > 0 {
+		sites, outer = stack[len(stack)-1:], stack[:len(stack)-1]
+	}
+	for _, site := range sites {
+		call := site.Value()
+		if call == nil {
+			// A go or defer statement.
+			continue
+		}
+		if n == 1 {
+			w.value(call, outer)
+		} else {
+			w.extract(call, i, outer)
+		}
+	}
+}
+
+// extract follows the i'th value of the tuple v.
+func (w *handleWalk) extract(v ssa.Value, i int, stack []ssa.CallInstruction) {
+	for _, ref := range *v.Referrers() {
+		if e, ok := ref.(*ssa.Extract); ok && e.Index == i {
+			w.value(e, stack)
+		}
+	}
+}
+
+// addr follows what's stored at addr to where it's loaded, wherever that is.
+func (w *handleWalk) addr(addr ssa.Value) {
+	if w.seenAddrs[addr] {
+		return
+	}
+	w.seenAddrs[addr] = true
+	switch addr := addr.(type) {
+	case *ssa.Alloc, *ssa.FreeVar:
+		for _, ref := range *addr.Referrers() {
+			switch ref := ref.(type) {
+			case *ssa.UnOp:
+				if ref.Op == token.MUL {
+					w.value(ref, nil)
+				}
+			case *ssa.MakeClosure:
+				// A variable captured by a closure.
+				fn := ref.Fn.(*ssa.Function)
+				for i, b := range ref.Bindings {
+					if b == addr {
+						w.addr(fn.FreeVars[i])
+					}
+				}
+			}
+		}
+	case *ssa.Global:
+		for _, load := range w.flow.globals.Loads(addr) {
+			w.value(load, nil)
+		}
+	case *ssa.FieldAddr:
+		// The same field of any value of the struct type.
+		for _, field := range w.flow.fields[fieldKey(addr.X.Type(), addr.Field)] {
+			if _, ok := field.(*ssa.Field); ok {
+				w.value(field, nil)
+				continue
+			}
+			for _, ref := range *field.Referrers() {
+				if load, ok := ref.(*ssa.UnOp); ok && load.Op == token.MUL && load.X == field {
+					w.value(load, nil)
+				}
+			}
+		}
+	}
+}
+
+// hasQueryMethod reports whether a value of type t, or a variable of it, has
+// one of the query methods: declared on t or on the type it points to, or
+// promoted from a field they embed. For an interface, which has the methods of
+// the interfaces it embeds, any method of the same name and signature as one
+// of the query methods counts, since that's what it may be implemented by.
+func hasQueryMethod(t types.Type, qms []*QueryMethod) bool {
+	t = deref(t)
+	for _, m := range qms {
+		if !m.IsMethod() {
+			continue
+		}
+		obj, _, _ := types.LookupFieldOrMethod(t, true, m.Func.Pkg(), m.Func.Name())
+		fn, ok := obj.(*types.Func)
+		if !ok {
+			continue
+		}
+		if fn == m.Func || types.IsInterface(t) && types.Identical(fn.Type(), m.Func.Type()) {
+			return true
+		}
+	}
+	return false
+}
+
+func deref(t types.Type) types.Type {
+	if p, ok := t.Underlying().(*types.Pointer); ok {
+		return p.Elem()
+	}
+	return t
+}
+
+func isSQLPackage(path string) bool {
+	_, ok := lookupSQLPackage(path)
+	return ok
+}
+
+// lookupSQLPackage returns the one of the sqlPackages with the given path.
+func lookupSQLPackage(path string) (sqlPackage, bool) {
+	for _, pkg := range sqlPackages {
+		if pkg.packageName == path {
+			return pkg, true
+		}
+	}
+	return sqlPackage{}, false
+}
+
+// QueryArg returns the operand of the given call which is passed as the query
+// parameter of m, or false if it cannot be located.
+//
+// The shape of the argument list depends on how the method was called: static
+// calls to a method pass the receiver as the first argument, "invoke" mode
+// calls through an interface do not, and method expressions (thunks) take the
+// receiver as an ordinary leading parameter. Rather than guessing from the
+// argument count, we use the signature of the callee at the call site to
+// figure out how many leading operands precede the method's own parameters.
+func QueryArg(cc *ssa.CallCommon, m *QueryMethod) (ssa.Value, bool) {
+	sig := cc.Signature()
+	args := cc.Args
+	if !cc.IsInvoke() && sig.Recv() != nil {
+		args = args[1:]
+	}
+	if len(args) != sig.Params().Len() {
+		return nil, false
+	}
+
+	// Anything left over beyond the method's own parameters is a receiver
+	// that was turned into a parameter, e.g. by a method expression.
+	offset := len(args) - m.ArgCount
+	if offset < 0 || m.Param+offset >= len(args) {
+		return nil, false
+	}
+	return args[m.Param+offset], true
+}
+
+// packageInfo returns the type information of the package containing the given
+// call, or nil if it isn't in one of the loaded packages.
+func packageInfo(p *loader.Program, site ssa.CallInstruction) *loader.PackageInfo {
+	if pkg := site.Parent().Pkg; pkg != nil {
+		return p.AllPackages[pkg.Pkg]
+	}
+	return nil
+}
+
+// QueryArgExpr returns the expression passed as the query operand of the given
+// call to a query method, or nil if the call isn't in any of the given files.
+func QueryArgExpr(files []*ast.File, site ssa.CallInstruction, m *QueryMethod) ast.Expr {
+	call, query := QueryCall(files, site, m)
+	if call == nil {
+		return nil
+	}
+	return call.Args[query]
+}
+
+// QueryCall returns the expression of the given call to a query method and the
+// index of its query operand, or nil if the call isn't in any of the given
+// files.
+func QueryCall(files []*ast.File, site ssa.CallInstruction, m *QueryMethod) (*ast.CallExpr, int) {
+	var call *ast.CallExpr
+	pos := site.Pos()
+	for _, f := range files {
+		if pos < f.Pos() || pos >= f.End() {
+			continue
+		}
+		path, _ := astutil.PathEnclosingInterval(f, pos, pos)
+		for _, n := range path {
+			if c, ok := n.(*ast.CallExpr); ok && c.Lparen == pos {
+				call = c
+				break
+			}
+		}
+		break
+	}
+	if call == nil {
+		return nil, 0
+	}
+
+	// The syntactic arguments line up with the SSA ones up to the query,
+	// which comes before any variadic arguments.
+	cc := site.Common()
+	args := cc.Args
+	if !cc.IsInvoke() && cc.Signature().Recv() != nil {
+		args = args[1:]
+	}
+	offset := len(args) - m.ArgCount
+	if offset < 0 || m.Param+offset >= len(call.Args) {
+		return nil, 0
+	}
+	return call, m.Param + offset
 }
 
 // Deal with GO15VENDOREXPERIMENT
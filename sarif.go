@@ -0,0 +1,316 @@
+package main
+
+import (
+	"encoding/json"
+	"go/token"
+	"io"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// A SARIFReport accumulates findings to write in the SARIF 2.1.0 format
+// understood by code scanning tools such as GitHub's.
+type SARIFReport struct {
+	// root is the directory file locations are relative to.
+	root    string
+	results []Result
+	stats   *Stats
+}
+
+// NewSARIFReport returns an empty report whose file locations are relative to
+// the given directory.
+func NewSARIFReport(root string) *SARIFReport {
+	return &SARIFReport{root: root}
+}
+
+// Add adds a finding to the report.
+func (r *SARIFReport) Add(result Result) {
+	r.results = append(r.results, result)
+}
+
+// SetStats includes statistics about the run in the report, as properties of
+// the run.
+func (r *SARIFReport) SetStats(stats *Stats) {
+	r.stats = stats
+}
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool               sarifTool                        `json:"tool"`
+	OriginalURIBaseIDs map[string]sarifArtifactLocation `json:"originalUriBaseIds,omitempty"`
+	Results            []sarifResult                    `json:"results"`
+	Properties         map[string]float64               `json:"properties,omitempty"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string              `json:"id"`
+	Name                 string              `json:"name"`
+	ShortDescription     sarifMessage        `json:"shortDescription"`
+	FullDescription      sarifMessage        `json:"fullDescription"`
+	Help                 sarifMessage        `json:"help"`
+	HelpURI              string              `json:"helpUri"`
+	DefaultConfiguration sarifConfiguration  `json:"defaultConfiguration"`
+	Properties           map[string][]string `json:"properties,omitempty"`
+}
+
+type sarifConfiguration struct {
+	Level string `json:"level"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID              string             `json:"ruleId"`
+	RuleIndex           int                `json:"ruleIndex"`
+	Level               string             `json:"level"`
+	Message             sarifMessage       `json:"message"`
+	Locations           []sarifLocation    `json:"locations"`
+	PartialFingerprints map[string]string  `json:"partialFingerprints,omitempty"`
+	CodeFlows           []sarifCodeFlow    `json:"codeFlows,omitempty"`
+	RelatedLocations    []sarifLocation    `json:"relatedLocations,omitempty"`
+	Suppressions        []sarifSuppression `json:"suppressions,omitempty"`
+	BaselineState       string             `json:"baselineState,omitempty"`
+	Fixes               []sarifFix         `json:"fixes,omitempty"`
+	Properties          map[string]string  `json:"properties,omitempty"`
+}
+
+type sarifLocation struct {
+	ID               int                   `json:"id,omitempty"`
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+	Message          *sarifMessage         `json:"message,omitempty"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId,omitempty"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+}
+
+type sarifCodeFlow struct {
+	ThreadFlows []sarifThreadFlow `json:"threadFlows"`
+}
+
+type sarifThreadFlow struct {
+	Locations []sarifThreadFlowLocation `json:"locations"`
+}
+
+type sarifThreadFlowLocation struct {
+	Location sarifLocation `json:"location"`
+}
+
+type sarifFix struct {
+	Description     sarifMessage          `json:"description"`
+	ArtifactChanges []sarifArtifactChange `json:"artifactChanges"`
+}
+
+type sarifArtifactChange struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Replacements     []sarifReplacement    `json:"replacements"`
+}
+
+type sarifReplacement struct {
+	DeletedRegion   sarifSpan            `json:"deletedRegion"`
+	InsertedContent sarifArtifactContent `json:"insertedContent"`
+}
+
+// sarifSpan is a region with an end, unlike the point regions of locations.
+type sarifSpan struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn"`
+	EndLine     int `json:"endLine"`
+	EndColumn   int `json:"endColumn"`
+}
+
+type sarifArtifactContent struct {
+	Text string `json:"text"`
+}
+
+type sarifSuppression struct {
+	Kind          string `json:"kind"`
+	Justification string `json:"justification,omitempty"`
+}
+
+// Write writes the report to w.
+func (r *SARIFReport) Write(w io.Writer) error {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           "safesql",
+			InformationURI: "https://github.com/stripe/safesql",
+			Rules:          make([]sarifRule, 0, len(Rules)),
+		}},
+		Results: make([]sarifResult, 0, len(r.results)),
+	}
+	if r.root != "" {
+		run.OriginalURIBaseIDs = map[string]sarifArtifactLocation{
+			"%SRCROOT%": {URI: fileURI(r.root) + "/"},
+		}
+	}
+	if r.stats != nil {
+		run.Properties = make(map[string]float64)
+		for _, m := range r.stats.Metrics() {
+			run.Properties["safesql/"+m.Name] = m.Value
+		}
+	}
+	ruleIndex := make(map[string]int)
+	for i, rule := range Rules {
+		ruleIndex[rule.ID] = i
+		run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{
+			ID:                   rule.ID,
+			Name:                 rule.Name,
+			ShortDescription:     sarifMessage{rule.Description},
+			FullDescription:      sarifMessage{rule.Description + "."},
+			Help:                 sarifMessage{rule.Help},
+			HelpURI:              rule.HelpURI(),
+			DefaultConfiguration: sarifConfiguration{Level: severityName(rule.Severity, "error", "warning", "note")},
+			Properties:           sarifRuleProperties(rule),
+		})
+	}
+
+	for _, res := range r.results {
+		sr := sarifResult{
+			RuleID:        res.Rule,
+			RuleIndex:     ruleIndex[res.Rule],
+			Level:         severityName(res.Severity, "error", "warning", "note"),
+			Message:       sarifMessage{res.Message},
+			Locations:     []sarifLocation{r.location(res.Position, "")},
+			BaselineState: res.BaselineState,
+		}
+		if res.Severity != 0 {
+			sr.Properties = map[string]string{"severity": res.Severity.String(), "confidence": res.Confidence.String()}
+		}
+		if len(res.Commands) > 0 {
+			if sr.Properties == nil {
+				sr.Properties = make(map[string]string)
+			}
+			sr.Properties["commands"] = strings.Join(res.Commands, ",")
+		}
+		if res.Fingerprint != "" {
+			sr.PartialFingerprints = map[string]string{"safesql/v1": res.Fingerprint}
+		}
+		if len(res.Flow) > 0 {
+			var flow sarifThreadFlow
+			for _, step := range res.Flow {
+				flow.Locations = append(flow.Locations, sarifThreadFlowLocation{r.location(step.Position, step.Message)})
+			}
+			flow.Locations = append(flow.Locations, sarifThreadFlowLocation{r.location(res.Position, res.Message)})
+			sr.CodeFlows = []sarifCodeFlow{{ThreadFlows: []sarifThreadFlow{flow}}}
+		}
+		for i, step := range res.Related {
+			loc := r.location(step.Position, step.Message)
+			loc.ID = i + 1
+			sr.RelatedLocations = append(sr.RelatedLocations, loc)
+		}
+		if res.Fix != nil {
+			sr.Fixes = []sarifFix{r.fix(res.Fix)}
+		}
+		if res.Suppression != "" {
+			sr.Suppressions = []sarifSuppression{{Kind: res.Suppression, Justification: res.Justification}}
+		}
+		run.Results = append(run.Results, sr)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	})
+}
+
+// sarifRuleProperties returns the properties of the rule's SARIF descriptor:
+// its tags, with a tag for each CWE and OWASP identifier in the form code
+// scanning and DefectDojo classify findings by, the identifiers themselves for
+// tools which read those, and the gosec rules which overlap with it.
+func sarifRuleProperties(rule Rule) map[string][]string {
+	tags := append([]string(nil), rule.Tags...)
+	for _, cwe := range rule.CWE {
+		tags = append(tags, "external/cwe/"+strings.ToLower(cwe))
+	}
+	for _, owasp := range rule.OWASP {
+		// e.g. external/owasp/a03:2021
+		tags = append(tags, "external/owasp/"+strings.ToLower(strings.Fields(owasp)[0]))
+	}
+	props := map[string][]string{"tags": tags}
+	if len(rule.CWE) > 0 {
+		props["cwe"] = rule.CWE
+	}
+	if len(rule.OWASP) > 0 {
+		props["owasp"] = rule.OWASP
+	}
+	if len(rule.Gosec) > 0 {
+		props["gosec"] = rule.Gosec
+	}
+	return props
+}
+
+// location returns the SARIF location of pos. Files below the report's root
+// are given relative to it, so that code scanning can match them up with the
+// repository.
+func (r *SARIFReport) location(pos token.Position, message string) sarifLocation {
+	artifact := sarifArtifactLocation{URI: fileURI(pos.Filename)}
+	if rel, ok := relPath(r.root, pos.Filename); ok {
+		artifact = sarifArtifactLocation{URI: rel, URIBaseID: "%SRCROOT%"}
+	}
+	loc := sarifLocation{PhysicalLocation: sarifPhysicalLocation{
+		ArtifactLocation: artifact,
+		Region:           sarifRegion{StartLine: pos.Line, StartColumn: pos.Column},
+	}}
+	if message != "" {
+		loc.Message = &sarifMessage{message}
+	}
+	return loc
+}
+
+// fix returns the SARIF fix for f, with a change for each file it edits.
+func (r *SARIFReport) fix(f *Fix) sarifFix {
+	fix := sarifFix{Description: sarifMessage{f.Message}}
+	changes := make(map[string]int)
+	for _, edit := range f.Edits {
+		i, ok := changes[edit.Pos.Filename]
+		if !ok {
+			i = len(fix.ArtifactChanges)
+			changes[edit.Pos.Filename] = i
+			fix.ArtifactChanges = append(fix.ArtifactChanges, sarifArtifactChange{
+				ArtifactLocation: r.location(edit.Pos, "").PhysicalLocation.ArtifactLocation,
+			})
+		}
+		fix.ArtifactChanges[i].Replacements = append(fix.ArtifactChanges[i].Replacements, sarifReplacement{
+			DeletedRegion:   sarifSpan{edit.Pos.Line, edit.Pos.Column, edit.End.Line, edit.End.Column},
+			InsertedContent: sarifArtifactContent{edit.NewText},
+		})
+	}
+	return fix
+}
+
+func fileURI(filename string) string {
+	u := url.URL{Scheme: "file", Path: filepath.ToSlash(filename)}
+	return u.String()
+}
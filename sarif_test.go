@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"go/token"
+	"reflect"
+	"testing"
+)
+
+func TestSARIFReport(t *testing.T) {
+	r := NewSARIFReport("/src/app")
+	r.Add(Result{
+		Rule:        RuleNonConstQuery,
+		Position:    token.Position{Filename: "/src/app/db/db.go", Line: 14, Column: 19},
+		Message:     "Query passed to (*database/sql.DB).Query is not a compile-time constant",
+		Flow:        []FlowStep{{token.Position{Filename: "/src/app/db/db.go", Line: 12, Column: 2}, "non-constant part: parameter name"}},
+		Fingerprint: "0123456789abcdef",
+		Fix: &Fix{Message: "Pass name as a query argument", Edits: []TextEdit{{
+			Pos:     token.Position{Filename: "/src/app/db/db.go", Line: 14, Column: 19},
+			End:     token.Position{Filename: "/src/app/db/db.go", Line: 14, Column: 40},
+			NewText: `"SELECT * FROM t WHERE name = ?", name`,
+		}}},
+	})
+	r.Add(Result{
+		Rule:          RuleNonConstQuery,
+		Position:      token.Position{Filename: "/elsewhere/db.go", Line: 3, Column: 1},
+		Suppression:   "external",
+		Justification: "legacy",
+	})
+
+	var buf bytes.Buffer
+	if err := r.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var log sarifLog
+	if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+		t.Fatal(err)
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Fatalf("unexpected log: %s", buf.String())
+	}
+	run := log.Runs[0]
+	if len(run.Tool.Driver.Rules) != len(Rules) || run.Tool.Driver.Rules[0].ID != RuleNonConstQuery {
+		t.Errorf("unexpected rules: %+v", run.Tool.Driver.Rules)
+	}
+	rule := run.Tool.Driver.Rules[0]
+	if rule.HelpURI != "https://github.com/stripe/safesql#safesql001" {
+		t.Errorf("unexpected help URI %q", rule.HelpURI)
+	}
+	expectedProps := map[string][]string{
+		"tags":  {"security", "external/cwe/cwe-89", "external/owasp/a03:2021"},
+		"cwe":   {"CWE-89"},
+		"owasp": {"A03:2021 - Injection"},
+		"gosec": {"G201", "G202"},
+	}
+	if !reflect.DeepEqual(rule.Properties, expectedProps) {
+		t.Errorf("unexpected rule properties %v", rule.Properties)
+	}
+	levels := make(map[string]string)
+	for _, r := range run.Tool.Driver.Rules {
+		levels[r.ID] = r.DefaultConfiguration.Level
+	}
+	if levels[RuleNonConstQuery] != "error" || levels[RuleInvalidSQL] != "warning" || levels[RuleUncheckedHandle] != "note" || levels[RuleSQLFormat] != "note" {
+		t.Errorf("unexpected default levels %v", levels)
+	}
+	if uri := run.OriginalURIBaseIDs["%SRCROOT%"].URI; uri != "file:///src/app/" {
+		t.Errorf("unexpected root %q", uri)
+	}
+	if len(run.Results) != 2 {
+		t.Fatalf("got %d results, expected 2", len(run.Results))
+	}
+
+	first := run.Results[0]
+	if loc := first.Locations[0].PhysicalLocation; loc.ArtifactLocation != (sarifArtifactLocation{URI: "db/db.go", URIBaseID: "%SRCROOT%"}) || loc.Region != (sarifRegion{14, 19}) {
+		t.Errorf("unexpected location %+v", loc)
+	}
+	if !reflect.DeepEqual(first.PartialFingerprints, map[string]string{"safesql/v1": "0123456789abcdef"}) {
+		t.Errorf("unexpected fingerprints %v", first.PartialFingerprints)
+	}
+	if len(first.CodeFlows) != 1 || len(first.CodeFlows[0].ThreadFlows[0].Locations) != 2 {
+		t.Fatalf("unexpected code flows %+v", first.CodeFlows)
+	}
+	if msg := first.CodeFlows[0].ThreadFlows[0].Locations[0].Location.Message; msg == nil || msg.Text != "non-constant part: parameter name" {
+		t.Errorf("unexpected first step message %v", msg)
+	}
+
+	expectedFix := sarifFix{
+		Description: sarifMessage{"Pass name as a query argument"},
+		ArtifactChanges: []sarifArtifactChange{{
+			ArtifactLocation: sarifArtifactLocation{URI: "db/db.go", URIBaseID: "%SRCROOT%"},
+			Replacements: []sarifReplacement{{
+				DeletedRegion:   sarifSpan{14, 19, 14, 40},
+				InsertedContent: sarifArtifactContent{`"SELECT * FROM t WHERE name = ?", name`},
+			}},
+		}},
+	}
+	if !reflect.DeepEqual(first.Fixes, []sarifFix{expectedFix}) {
+		t.Errorf("unexpected fixes %+v", first.Fixes)
+	}
+
+	second := run.Results[1]
+	if uri := second.Locations[0].PhysicalLocation.ArtifactLocation; uri != (sarifArtifactLocation{URI: "file:///elsewhere/db.go"}) {
+		t.Errorf("unexpected location %+v", uri)
+	}
+	if !reflect.DeepEqual(second.Suppressions, []sarifSuppression{{Kind: "external", Justification: "legacy"}}) {
+		t.Errorf("unexpected suppressions %+v", second.Suppressions)
+	}
+}
@@ -0,0 +1,336 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/build"
+	"go/parser"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/tools/go/loader"
+)
+
+// A Server keeps the findings of a program between requests to analyze some
+// of its packages or files, so that editors and review bots needn't load,
+// build and analyze the program for each one. The program is analyzed again
+// when one of its files changes, or a request is about packages it doesn't
+// include yet.
+type Server struct {
+	c      loader.Config
+	config *Config
+	wd     string
+	opts   AnalysisOptions
+	warn   io.Writer
+
+	// mu guards the packages and the state, and serializes the analyses,
+	// which share sqlPackages.
+	mu    sync.Mutex
+	pkgs  []string
+	state *serverState
+}
+
+// serverState is what a Server knows about its program as it was when it was
+// last analyzed.
+type serverState struct {
+	// results are all of the findings, suppressed ones included.
+	results []Result
+	// modTimes are the modification times of the files of the program
+	// outside GOROOT, and of their directories, when it was loaded.
+	modTimes map[string]time.Time
+}
+
+// NewServer returns a server for the program made of the given packages, as
+// loaded by c, which it analyzes as Analyze does with opts. File names and
+// packages in requests are relative to wd. Warnings about loading and
+// analyzing the program are written to warn.
+func NewServer(c loader.Config, config *Config, wd string, pkgs []string, opts AnalysisOptions, warn io.Writer) *Server {
+	return &Server{c: c, config: config, wd: wd, pkgs: pkgs, opts: opts, warn: warn}
+}
+
+// Analyze returns the findings in the given packages, given as for
+// ExpandPatterns, and files, or in all of the program if there are neither.
+// The program is analyzed again first if it's changed since it last was or
+// doesn't include all of the packages and files yet.
+func (s *Server) Analyze(pkgs, files []string) ([]Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	inFiles := make(map[string]bool, len(files))
+	for _, file := range files {
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(s.wd, file)
+		}
+		abs, pkg, err := FilePackage(file, s.wd)
+		if err != nil {
+			return nil, err
+		}
+		inFiles[fileKey(abs)] = true
+		s.include(pkg)
+	}
+	inDirs := make(map[string]bool, len(pkgs))
+	for _, pkg := range ExpandPatterns(s.c.Build, s.wd, pkgs, nil) {
+		bp, err := FindPackage(s.c.Build, pkg, s.wd, build.FindOnly)
+		if err != nil {
+			return nil, err
+		}
+		inDirs[fileKey(bp.Dir)] = true
+		s.include(pkg)
+	}
+
+	if s.state == nil || s.state.stale() {
+		if err := s.load(); err != nil {
+			return nil, err
+		}
+	}
+	results := make([]Result, 0)
+	for _, result := range s.state.results {
+		name := result.Position.Filename
+		if len(files)+len(pkgs) == 0 || inFiles[fileKey(name)] || inDirs[fileKey(filepath.Dir(name))] {
+			results = append(results, result)
+		}
+	}
+	return results, nil
+}
+
+// include adds pkg to the server's program, if it isn't part of it yet.
+func (s *Server) include(pkg string) {
+	if !contains(s.pkgs, pkg) {
+		s.pkgs = append(s.pkgs, pkg)
+		s.state = nil
+	}
+}
+
+// load loads and analyzes the server's program.
+func (s *Server) load() error {
+	s.state = nil
+	start := time.Now()
+	p, _, err := LoadPackages(s.c, s.pkgs, s.opts.Tests, s.warn)
+	if err != nil {
+		return fmt.Errorf("error loading packages %v: %v", s.pkgs, err)
+	}
+	opts := s.opts
+	if opts.DiffRef != "" {
+		// The lines changed since the ref change as the program does.
+		if opts.Changed, err = GitChangedLines(opts.DiffRef); err != nil {
+			return fmt.Errorf("error computing changes relative to %s: %v", opts.DiffRef, err)
+		}
+	}
+	a, err := analyze(p, s.config, opts, s.warn)
+	if err != nil {
+		return err
+	}
+	SortResults(a.Results)
+	s.state = &serverState{results: a.Results, modTimes: modTimes(s.c.Build, p)}
+	fmt.Fprintf(s.warn, "analyzed %d packages in %s\n", len(p.AllPackages), time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+// analyze is Analyze, but returns panics as errors, as analyzeProgram does, so
+// that the server keeps running.
+func analyze(p *loader.Program, config *Config, opts AnalysisOptions, out io.Writer) (a *Analysis, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return Analyze(p, config, opts, out)
+}
+
+// stale reports whether any of the files of the program, or the directories
+// they're in, have changed, and so the program may have too.
+func (st *serverState) stale() bool {
+	for name, t := range st.modTimes {
+		fi, err := os.Stat(name)
+		if err != nil || !fi.ModTime().Equal(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// modTimes returns the modification times of the files of p outside GOROOT,
+// and of their directories, whose own modification times change when files are
+// added to or removed from them.
+func modTimes(ctxt *build.Context, p *loader.Program) map[string]time.Time {
+	goroot := filepath.Join(ctxt.GOROOT, "src") + string(filepath.Separator)
+	times := make(map[string]time.Time)
+	add := func(name string) {
+		if fi, err := os.Stat(name); err == nil {
+			times[name] = fi.ModTime()
+		}
+	}
+	for _, info := range p.AllPackages {
+		for _, f := range info.Files {
+			name := p.Fset.File(f.Pos()).Name()
+			if strings.HasPrefix(name, goroot) {
+				break
+			}
+			add(name)
+			add(filepath.Dir(name))
+		}
+	}
+	return times
+}
+
+// An analyzeRequest asks a Server for the findings in some packages or files,
+// as a report in the given format.
+type analyzeRequest struct {
+	Packages []string `json:"packages"`
+	Files    []string `json:"files"`
+	// Format is any of -format's but text and pretty, and defaults to
+	// sarif. Template is the template of the template format.
+	Format   string `json:"format"`
+	Template string `json:"template"`
+}
+
+// ServeHTTP answers POST requests to /analyze, whose body is an analyzeRequest
+// in JSON, with a report of the findings.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/analyze" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "only POST is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req analyzeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("bad request: %v", err), http.StatusBadRequest)
+		return
+	}
+	if req.Format == "" {
+		req.Format = "sarif"
+	}
+	reporter, err := NewReporter(req.Format, s.wd, req.Template)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	results, err := s.Analyze(req.Packages, req.Files)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for _, result := range results {
+		reporter.Add(result)
+	}
+	var buf bytes.Buffer
+	if err := reporter.Write(&buf); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Write(buf.Bytes())
+}
+
+// serveMain runs `safesql serve` with the given arguments, and returns the
+// status to exit with.
+func serveMain(args []string) int {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	var addr, configFile, tags, goos, goarch, precisionName string
+	var minSeverity, minConfidence, dialectName, baselineFile, diffRef, gosecFile string
+	var opts AnalysisOptions
+	fs.StringVar(&addr, "addr", "localhost:7347", "Address to listen on")
+	fs.StringVar(&configFile, "config", DefaultConfigFile, "Configuration file")
+	fs.StringVar(&tags, "tags", goflagsTags(), "Comma-separated build tags to load the packages with")
+	fs.StringVar(&goos, "goos", "", "Operating system to load the packages for, if not GOOS")
+	fs.StringVar(&goarch, "goarch", "", "Architecture to load the packages for, if not GOARCH")
+	fs.StringVar(&precisionName, "precision", "max", "How precisely to build the call graph: fast, balanced or max")
+	fs.BoolVar(&opts.Tests, "tests", false, "Also load the packages' tests, and analyze them as entry points of the program")
+	fs.BoolVar(&opts.TrustMigrations, "trust-migrations", true, "Treat files embedded in the binary, such as migrations, as constant queries, and don't check the queries migration tools run")
+	fs.StringVar(&minSeverity, "min-severity", "low", "Only report findings of at least this severity: low, medium or high")
+	fs.StringVar(&minConfidence, "min-confidence", "low", "Only report findings of at least this confidence: low, medium or high")
+	fs.BoolVar(&opts.ValidateSQL, "validate-sql", false, "Also report constant queries which aren't valid SQL")
+	fs.StringVar(&dialectName, "dialect", "", "SQL dialect of the queries, for -validate-sql: mysql, postgres, sqlite, clickhouse, sqlserver or oracle. Defaults to that of the drivers passed to sql.Open and the database packages used")
+	fs.StringVar(&baselineFile, "baseline-file", "", "Report the findings in this baseline file, written with safesql -baseline write, as suppressed")
+	fs.StringVar(&diffRef, "diff", "", "Report the findings on lines not changed relative to this git ref as suppressed")
+	fs.StringVar(&gosecFile, "gosec-report", "", "Report the SQL findings on lines which this gosec JSON report (gosec -fmt json) has a G201 or G202 finding on as suppressed")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s serve [flags] package1 [package2 ...]\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+	var err error
+	if opts.Precision, err = ParsePrecision(precisionName); err != nil {
+		fmt.Fprintf(os.Stderr, "-precision: %v\n", err)
+		return 2
+	}
+	if opts.MinSeverity, err = ParseLevel(minSeverity); err != nil {
+		fmt.Fprintf(os.Stderr, "-min-severity: %v\n", err)
+		return 2
+	}
+	if opts.MinConfidence, err = ParseLevel(minConfidence); err != nil {
+		fmt.Fprintf(os.Stderr, "-min-confidence: %v\n", err)
+		return 2
+	}
+	if dialectName != "" {
+		dialect, err := ParseDialect(dialectName)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "-dialect: %v\n", err)
+			return 2
+		}
+		opts.Dialect = &dialect
+	}
+	wd, _ := os.Getwd()
+	if baselineFile != "" {
+		if opts.Baseline, err = ReadBaseline(baselineFile); err != nil {
+			fmt.Fprintf(os.Stderr, "error reading baseline: %v\n", err)
+			return 2
+		}
+		opts.BaselineMode = "check"
+	}
+	if gosecFile != "" {
+		if opts.Gosec, err = ReadGosecReport(gosecFile, wd); err != nil {
+			fmt.Fprintf(os.Stderr, "error reading gosec report: %v\n", err)
+			return 2
+		}
+	}
+	// Findings say which commands they're in, since a server is usually
+	// asked about a whole repository.
+	opts.Commands, opts.DiffRef = true, diffRef
+
+	c := loader.Config{
+		Build:       BuildContext(tags, goos, goarch),
+		FindPackage: FindPackage,
+		ParserMode:  parser.ParseComments,
+	}
+	config, err := LoadConfig(configFile)
+	if err == nil {
+		err = config.LoadBundles(c.Build)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error loading configuration: %v\n", err)
+		return 2
+	}
+	for _, pkg := range config.Packages {
+		sqlPackages = append(sqlPackages, sqlPackage{packageName: pkg.Package, paramNames: pkg.Params})
+	}
+
+	server := NewServer(c, config, wd, ExpandPatterns(c.Build, wd, fs.Args(), nil), opts, os.Stderr)
+	// Analyzed before listening, so that the first request is answered
+	// as quickly as the others.
+	if _, err := server.Analyze(nil, nil); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	fmt.Fprintf(os.Stderr, "Listening on http://%s/analyze\n", addr)
+	if err := http.ListenAndServe(addr, server); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	return 0
+}
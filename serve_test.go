@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"go/build"
+	"go/parser"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/tools/go/loader"
+)
+
+const serveSrc = `package main
+
+import (
+	"database/sql"
+	"os"
+)
+
+func main() {
+	db, _ := sql.Open("mysql", "")
+	db.Query("SELECT * FROM users WHERE name = '" + os.Args[1] + "'")
+}
+`
+
+func TestServer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "safesql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	app := filepath.Join(dir, "src", "example.com", "app")
+	if err := os.MkdirAll(app, 0755); err != nil {
+		t.Fatal(err)
+	}
+	main := filepath.Join(app, "main.go")
+	if err := ioutil.WriteFile(main, []byte(serveSrc), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Setenv("GO111MODULE", os.Getenv("GO111MODULE"))
+	os.Setenv("GO111MODULE", "off")
+	ctxt := build.Default
+	ctxt.GOPATH = dir
+
+	c := loader.Config{Build: &ctxt, Cwd: app, ParserMode: parser.ParseComments}
+	var warn bytes.Buffer
+	s := NewServer(c, &Config{}, app, []string{"."}, AnalysisOptions{Precision: PrecisionMax, TrustMigrations: true}, &warn)
+	results, err := s.Analyze(nil, nil)
+	if err != nil && strings.HasPrefix(err.Error(), "error loading packages") {
+		t.Fatal(err)
+	} else if err != nil {
+		// e.g. package database/sql, with a version of Go newer than the
+		// golang.org/x/tools safesql is built with.
+		t.Skipf("package ssa can't build the program: %v", err)
+	}
+	if len(results) != 1 || results[0].Rule != RuleNonConstQuery || results[0].Position.Line != 10 {
+		t.Fatalf("expected the unsafe query on line 10, got %v", results)
+	}
+	if results, err = s.Analyze(nil, []string{"other.go"}); err != nil || len(results) != 0 {
+		t.Errorf("expected no findings in other.go, got %v, %v", results, err)
+	}
+
+	// Once the query is fixed, the program is analyzed again.
+	fixed := strings.Replace(serveSrc, `"SELECT * FROM users WHERE name = '" + os.Args[1] + "'"`, `"SELECT * FROM users WHERE name = ?", os.Args[1]`, 1)
+	if err := ioutil.WriteFile(main, []byte(fixed), 0644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(main, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if results, err = s.Analyze([]string{"."}, nil); err != nil || len(results) != 0 {
+		t.Errorf("expected no findings once the query is fixed, got %v, %v", results, err)
+	}
+}
+
+func TestServerStateStale(t *testing.T) {
+	dir, err := ioutil.TempDir("", "safesql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "db.go")
+	if err := ioutil.WriteFile(name, []byte("package db\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	st := &serverState{modTimes: map[string]time.Time{name: fi.ModTime()}}
+	if st.stale() {
+		t.Errorf("expected an unchanged file not to be stale")
+	}
+	later := fi.ModTime().Add(time.Minute)
+	if err := os.Chtimes(name, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if !st.stale() {
+		t.Errorf("expected a changed file to be stale")
+	}
+	os.Remove(name)
+	st.modTimes[name] = later
+	if !st.stale() {
+		t.Errorf("expected a removed file to be stale")
+	}
+}
+
+func TestServeHTTPErrors(t *testing.T) {
+	s := NewServer(loader.Config{}, &Config{}, "", nil, AnalysisOptions{Precision: PrecisionMax}, ioutil.Discard)
+	for _, test := range []struct {
+		method, path, body string
+		status             int
+	}{
+		{"GET", "/analyze", "", http.StatusMethodNotAllowed},
+		{"POST", "/analyze", "{", http.StatusBadRequest},
+		{"POST", "/analyze", `{"format": "text"}`, http.StatusBadRequest},
+		{"POST", "/", "{}", http.StatusNotFound},
+	} {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest(test.method, test.path, strings.NewReader(test.body)))
+		if w.Code != test.status {
+			t.Errorf("%s %s %q: expected status %d, got %d: %s", test.method, test.path, test.body, test.status, w.Code, w.Body)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"fmt"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/ssa"
+)
+
+// A Level grades the severity of a finding, or the confidence that it is a
+// real problem.
+type Level int
+
+// The zero Level means the finding hasn't been graded.
+const (
+	LevelLow Level = iota + 1
+	LevelMedium
+	LevelHigh
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelLow:
+		return "low"
+	case LevelMedium:
+		return "medium"
+	case LevelHigh:
+		return "high"
+	}
+	return ""
+}
+
+// ParseLevel parses the name of a Level.
+func ParseLevel(s string) (Level, error) {
+	for l := LevelLow; l <= LevelHigh; l++ {
+		if s == l.String() {
+			return l, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown level %q, expected low, medium or high", s)
+}
+
+// Classify grades a non-constant query by the values it is built from.
+//
+// Severity is high if data from an HTTP request makes it into the query, low
+// if only numbers, booleans and identifiers quoted for the database (e.g.
+// with pq.QuoteIdentifier) do, and medium otherwise.
+//
+// Confidence is high if data from an HTTP request makes it into the query. It
+// is low if the query couldn't be broken down, or is only built from values
+// which come from elsewhere and may well be constant there, such as function
+// parameters and package variables; and medium otherwise.
+func (c *ConstChecker) Classify(query ssa.Value) (severity, confidence Level) {
+	parts := c.DynamicParts(query)
+	if len(parts) == 0 {
+		return LevelMedium, LevelLow
+	}
+	severity, confidence = LevelLow, LevelLow
+	for _, part := range parts {
+		switch {
+		case fromRequest(part.Value, make(map[ssa.Value]bool)):
+			return LevelHigh, LevelHigh
+		case isScalar(part.Value), isQuotedIdentifier(part.Value):
+		default:
+			severity = LevelMedium
+		}
+		if !isIndirect(part.Value) {
+			confidence = LevelMedium
+		}
+	}
+	return severity, confidence
+}
+
+// fromRequest reports whether v is derived from a net/http request or a
+// net/url value, e.g. by r.FormValue or r.URL.Query().Get.
+func fromRequest(v ssa.Value, seen map[ssa.Value]bool) bool {
+	if seen[v] {
+		return false
+	}
+	seen[v] = true
+
+	switch v := v.(type) {
+	case *ssa.Call:
+		cc := v.Common()
+		if cc.IsInvoke() {
+			if isRequestType(cc.Value.Type()) {
+				return true
+			}
+		} else if sig := cc.Signature(); sig.Recv() != nil && isRequestType(sig.Recv().Type()) {
+			return true
+		}
+		for _, arg := range cc.Args {
+			if fromRequest(arg, seen) {
+				return true
+			}
+		}
+	case *ssa.FieldAddr:
+		return isRequestType(v.X.Type()) || fromRequest(v.X, seen)
+	case *ssa.Field:
+		return isRequestType(v.X.Type()) || fromRequest(v.X, seen)
+	case *ssa.UnOp:
+		return fromRequest(v.X, seen)
+	case *ssa.Lookup:
+		return fromRequest(v.X, seen)
+	case *ssa.Index:
+		return fromRequest(v.X, seen)
+	case *ssa.IndexAddr:
+		return fromRequest(v.X, seen)
+	case *ssa.Slice:
+		return fromRequest(v.X, seen)
+	case *ssa.Extract:
+		return fromRequest(v.Tuple, seen)
+	case *ssa.Convert:
+		return fromRequest(v.X, seen)
+	case *ssa.ChangeType:
+		return fromRequest(v.X, seen)
+	case *ssa.MakeInterface:
+		return fromRequest(v.X, seen)
+	case *ssa.BinOp:
+		return fromRequest(v.X, seen) || fromRequest(v.Y, seen)
+	case *ssa.Phi:
+		for _, e := range v.Edges {
+			if fromRequest(e, seen) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isRequestType(t types.Type) bool {
+	n, ok := deref(t).(*types.Named)
+	if !ok || n.Obj().Pkg() == nil {
+		return false
+	}
+	switch n.Obj().Pkg().Path() {
+	case "net/http", "net/url":
+		return true
+	}
+	return false
+}
+
+// isScalar reports whether v is a number or boolean, or a string formatted
+// from one by package strconv.
+func isScalar(v ssa.Value) bool {
+	if b, ok := v.Type().Underlying().(*types.Basic); ok && b.Info()&(types.IsNumeric|types.IsBoolean) != 0 {
+		return true
+	}
+	if call, ok := v.(*ssa.Call); ok {
+		if callee := call.Common().StaticCallee(); callee != nil && callee.Pkg != nil && callee.Pkg.Pkg.Path() == "strconv" {
+			switch callee.Name() {
+			case "Itoa", "FormatInt", "FormatUint", "FormatFloat", "FormatBool":
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isQuotedIdentifier reports whether v is an identifier quoted by one of the
+// IdentifierQuoters, which can't break out of the quotes.
+func isQuotedIdentifier(v ssa.Value) bool {
+	call, ok := v.(*ssa.Call)
+	if !ok {
+		return false
+	}
+	callee := call.Common().StaticCallee()
+	if callee == nil {
+		return false
+	}
+	fn, _ := callee.Object().(*types.Func)
+	return isQuoteFunc(fn)
+}
+
+// isIndirect reports whether v comes from somewhere the query-building code
+// can't see, and so may well be constant: a parameter, a captured variable, a
+// package variable, a channel or an interface it's asserted from.
+func isIndirect(v ssa.Value) bool {
+	if isTypeAssertion(v) {
+		return true
+	}
+	switch v := v.(type) {
+	case *ssa.Parameter, *ssa.FreeVar:
+		return true
+	case *ssa.UnOp:
+		_, global := v.X.(*ssa.Global)
+		return global || v.Op == token.ARROW
+	}
+	return false
+}
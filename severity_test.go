@@ -0,0 +1,127 @@
+package main
+
+import (
+	"go/importer"
+	"go/token"
+	"go/types"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/ssa"
+)
+
+const classifySrc = `package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+type DB struct{}
+
+func (*DB) Exec(query string) {}
+
+var table = "t"
+
+func handler(w http.ResponseWriter, r *http.Request) {
+	db := &DB{}
+	db.Exec(fmt.Sprintf("SELECT * FROM t WHERE a = '%s'", r.FormValue("a"))) // high high
+	db.Exec("SELECT * FROM t WHERE a = '" + r.URL.Query().Get("a") + "'")     // high high
+	db.Exec("SELECT * FROM t WHERE a = '" + r.Form["a"][0] + "'")             // high high
+	db.Exec("SELECT * FROM t LIMIT " + strconv.Itoa(len(table)))              // low medium
+	db.Exec(fmt.Sprintf("SELECT * FROM t LIMIT %d", len(table)))              // low medium
+	db.Exec("SELECT * FROM " + table)                                         // medium low
+	db.Exec("SELECT * FROM " + name())                                        // medium medium
+}
+
+func query(db *DB, q string) {
+	db.Exec(q) // medium low
+}
+
+func assert(db *DB, payload interface{}) {
+	db.Exec(payload.(string)) // medium low
+}
+
+func name() string { return "t" }
+
+func main() {}
+`
+
+// TestClassify checks the severity and confidence of findings, which are
+// given by comments on the lines they are on.
+func TestClassify(t *testing.T) {
+	fset := token.NewFileSet()
+	pkg := buildSource(t, fset, "main.go", classifySrc, types.NewPackage("main", ""), importer.Default())
+	cc := &ConstChecker{}
+
+	lines := strings.Split(classifySrc, "\n")
+	n := 0
+	for _, fn := range []string{"handler", "query", "assert"} {
+		for _, b := range pkg.Func(fn).Blocks {
+			for _, instr := range b.Instrs {
+				call, ok := instr.(*ssa.Call)
+				if !ok || call.Common().StaticCallee() == nil || call.Common().StaticCallee().Name() != "Exec" {
+					continue
+				}
+				n++
+				line := lines[fset.Position(call.Pos()).Line-1]
+				expected := line[strings.Index(line, "// ")+3:]
+				severity, confidence := cc.Classify(call.Common().Args[1])
+				if actual := severity.String() + " " + confidence.String(); actual != expected {
+					t.Errorf("%s: got %s, expected %s", strings.TrimSpace(line), actual, expected)
+				}
+			}
+		}
+	}
+	if n != 9 {
+		t.Errorf("checked %d calls, expected 9", n)
+	}
+}
+
+// TestClassifyQuotedIdentifier checks that identifiers quoted for the
+// database count as harmless as numbers.
+func TestClassifyQuotedIdentifier(t *testing.T) {
+	src := `package main
+
+import "github.com/lib/pq"
+
+type DB struct{}
+
+func (*DB) Exec(query string) {}
+
+func run(db *DB, schema string) {
+	db.Exec("CREATE SCHEMA " + pq.QuoteIdentifier(schema))
+}
+`
+	fset := token.NewFileSet()
+	pkg := buildSource(t, fset, "main.go", src, types.NewPackage("main", ""), quoterImporter(fset))
+	cc := &ConstChecker{}
+	n := 0
+	for _, b := range pkg.Func("run").Blocks {
+		for _, instr := range b.Instrs {
+			call, ok := instr.(*ssa.Call)
+			if !ok || call.Common().StaticCallee() == nil || call.Common().StaticCallee().Name() != "Exec" {
+				continue
+			}
+			n++
+			if severity, _ := cc.Classify(call.Common().Args[1]); severity != LevelLow {
+				t.Errorf("expected low severity, got %s", severity)
+			}
+		}
+	}
+	if n != 1 {
+		t.Errorf("checked %d calls, expected 1", n)
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	for l := LevelLow; l <= LevelHigh; l++ {
+		if parsed, err := ParseLevel(l.String()); err != nil || parsed != l {
+			t.Errorf("ParseLevel(%q) = %v, %v", l.String(), parsed, err)
+		}
+	}
+	if _, err := ParseLevel("critical"); err == nil {
+		t.Error("ParseLevel(\"critical\") succeeded")
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"go/types"
+	"io"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/loader"
+)
+
+// sinkParamNames are the names of string parameters which suggest that a
+// method takes a query.
+var sinkParamNames = []string{"query", "sql", "stmt"}
+
+// A SuggestedSink is a package which isn't one safesql checks calls to, but
+// whose methods look like they take queries.
+type SuggestedSink struct {
+	Package string
+	// Params are the names of the parameters the queries are passed as,
+	// and Methods the methods which have them, e.g. "(*DB).Select".
+	Params  []string
+	Methods []string
+}
+
+// SuggestSinks returns the packages in the program outside the standard
+// library, other than the database packages safesql already knows, with
+// exported methods on exported types which have a string parameter named
+// query, sql or stmt (in any case), sorted by import path.
+func SuggestSinks(p *loader.Program) []SuggestedSink {
+	sinks := make([]SuggestedSink, 0)
+	for pkg := range p.AllPackages {
+		if isStandardPackage(pkg.Path()) || isSQLPackage(pkg.Path()) {
+			continue
+		}
+		sink := SuggestedSink{Package: pkg.Path()}
+		for _, m := range ExportedMethods(pkg) {
+			s := m.Type().(*types.Signature)
+			param, ok := sinkParam(s)
+			if !ok {
+				continue
+			}
+			name := types.TypeString(s.Recv().Type(), types.RelativeTo(pkg))
+			if strings.HasPrefix(name, "*") {
+				name = "(" + name + ")"
+			}
+			sink.Methods = append(sink.Methods, name+"."+m.Name())
+			if !contains(sink.Params, param) {
+				sink.Params = append(sink.Params, param)
+			}
+		}
+		if len(sink.Methods) > 0 {
+			sort.Strings(sink.Params)
+			sinks = append(sinks, sink)
+		}
+	}
+	sort.Slice(sinks, func(i, j int) bool {
+		return sinks[i].Package < sinks[j].Package
+	})
+	return sinks
+}
+
+// sinkParam returns the name of the first string parameter of s which
+// suggests that it's a query.
+func sinkParam(s *types.Signature) (string, bool) {
+	params := s.Params()
+	for i := 0; i < params.Len(); i++ {
+		v := params.At(i)
+		if !types.Identical(v.Type(), types.Typ[types.String]) {
+			continue
+		}
+		for _, name := range sinkParamNames {
+			if strings.EqualFold(v.Name(), name) {
+				return v.Name(), true
+			}
+		}
+	}
+	return "", false
+}
+
+// isStandardPackage reports whether the import path is that of a package in
+// the standard library, whose first element, unlike those of other packages,
+// has no dot.
+func isStandardPackage(path string) bool {
+	return !strings.Contains(strings.SplitN(path, "/", 2)[0], ".")
+}
+
+// WriteSuggestedSinks writes the suggested sinks, and the configuration which
+// checks calls to them.
+func WriteSuggestedSinks(w io.Writer, sinks []SuggestedSink) {
+	if len(sinks) == 0 {
+		fmt.Fprintln(w, "Found no packages with methods which look like they take queries")
+		return
+	}
+	fmt.Fprintf(w, "Found %d packages with methods which look like they take queries:\n", len(sinks))
+	for _, sink := range sinks {
+		fmt.Fprintf(w, "- %s: %s\n", sink.Package, strings.Join(sink.Methods, ", "))
+	}
+	fmt.Fprintf(w, "\nTo check the calls to them, add them to %s:\n\npackages:\n", DefaultConfigFile)
+	for _, sink := range sinks {
+		fmt.Fprintf(w, "  - package: %s\n    params: [%s]\n", sink.Package, strings.Join(sink.Params, ", "))
+	}
+}
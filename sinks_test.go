@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/loader"
+)
+
+const sinksSrc = `package db
+
+type DB struct{}
+
+func (*DB) Select(dest interface{}, query string, args ...interface{}) error { return nil }
+func (DB) Run(SQL string) error                                             { return nil }
+func (*DB) Close() error                                                    { return nil }
+func (*DB) Named(query int) error                                           { return nil }
+func (*DB) unexported(query string) error                                   { return nil }
+
+type tx struct{}
+
+func (*tx) Exec(stmt string) error { return nil }
+`
+
+func TestSuggestSinks(t *testing.T) {
+	var c loader.Config
+	f, err := c.ParseFile("db.go", sinksSrc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.CreateFromFiles("example.com/app/db", f)
+	p, err := c.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sinks := SuggestSinks(p)
+	if len(sinks) != 1 {
+		t.Fatalf("expected 1 sink, got %+v", sinks)
+	}
+	sink := sinks[0]
+	if sink.Package != "example.com/app/db" || strings.Join(sink.Methods, ",") != "(*DB).Select,DB.Run" || strings.Join(sink.Params, ",") != "SQL,query" {
+		t.Errorf("unexpected sink %+v", sink)
+	}
+
+	var buf bytes.Buffer
+	WriteSuggestedSinks(&buf, sinks)
+	if !strings.Contains(buf.String(), "  - package: example.com/app/db\n    params: [SQL, query]\n") {
+		t.Errorf("unexpected output %s", buf.String())
+	}
+}
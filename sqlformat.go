@@ -0,0 +1,139 @@
+package main
+
+import (
+	"go/token"
+	"go/types"
+	"regexp"
+
+	"golang.org/x/tools/go/loader"
+	"golang.org/x/tools/go/ssa"
+	"golang.org/x/tools/go/ssa/ssautil"
+)
+
+// sqlFormatRE matches the shapes of SQL statements, and WHERE clauses, in
+// upper case.
+var sqlFormatRE = regexp.MustCompile(`\bSELECT\b[\s\S]*\bFROM\b|\bINSERT\s+INTO\b|\bUPDATE\b[\s\S]*\bSET\b|\bDELETE\s+FROM\b|\bWHERE\b`)
+
+// sqlFormatStartRE matches text which starts with a SQL statement, in any
+// case.
+var sqlFormatStartRE = regexp.MustCompile(`(?i)^\s*(SELECT\b[\s\S]*\bFROM|INSERT\s+INTO|UPDATE\b[\s\S]*\bSET|DELETE\s+FROM)\b`)
+
+// looksLikeSQL reports whether a format string looks like SQL: whether it has
+// a statement or a WHERE clause in upper case, or starts with a statement in
+// any case. Lower case SQL anywhere else is too easily confused with English,
+// e.g. "select a file from the list".
+func looksLikeSQL(format string) bool {
+	return sqlFormatRE.MatchString(format) || sqlFormatStartRE.MatchString(format)
+}
+
+// FindSQLFormats returns the calls in the given packages to Sprintf-like
+// functions, such as fmt.Sprintf, whose constant format string looks like SQL,
+// whether or not what they build is passed to a query method. Those which
+// build one of the given queries, which are passed to query methods and so
+// reported already, are left out. The others may build queries for database
+// layers safesql doesn't know.
+func FindSQLFormats(s *ssa.Program, pkgs []*ssa.Package, queries []ssa.Value) []*ssa.Call {
+	inPkgs := make(map[*ssa.Package]bool, len(pkgs))
+	for _, pkg := range pkgs {
+		inPkgs[pkg] = true
+	}
+	known := make(map[*ssa.Call]bool)
+	seen := make(map[ssa.Value]bool)
+	for _, q := range queries {
+		formatCalls(q, known, seen)
+	}
+
+	calls := make([]*ssa.Call, 0)
+	for fn := range ssautil.AllFunctions(s) {
+		if !inPkgs[fn.Pkg] {
+			continue
+		}
+		for _, b := range fn.Blocks {
+			for _, instr := range b.Instrs {
+				call, ok := instr.(*ssa.Call)
+				if !ok || known[call] {
+					continue
+				}
+				if format, ok := sprintfFormat(call.Common()); ok && looksLikeSQL(format) {
+					calls = append(calls, call)
+				}
+			}
+		}
+	}
+	return calls
+}
+
+// InitialSSAPackages returns the SSA packages of the initial packages of p,
+// those the user asked for.
+func InitialSSAPackages(p *loader.Program, s *ssa.Program) []*ssa.Package {
+	pkgs := make([]*ssa.Package, 0, len(p.InitialPackages()))
+	for _, info := range p.InitialPackages() {
+		pkgs = append(pkgs, s.Package(info.Pkg))
+	}
+	return pkgs
+}
+
+// formatCalls adds the calls to Sprintf-like functions which v is built from
+// to found.
+func formatCalls(v ssa.Value, found map[*ssa.Call]bool, seen map[ssa.Value]bool) {
+	if v == nil || seen[v] {
+		return
+	}
+	seen[v] = true
+
+	switch v := v.(type) {
+	case *ssa.BinOp:
+		if v.Op == token.ADD {
+			formatCalls(v.X, found, seen)
+			formatCalls(v.Y, found, seen)
+		}
+	case *ssa.MakeInterface:
+		formatCalls(v.X, found, seen)
+	case *ssa.ChangeType:
+		formatCalls(v.X, found, seen)
+	case *ssa.Convert:
+		formatCalls(v.X, found, seen)
+	case *ssa.Phi:
+		for _, e := range v.Edges {
+			formatCalls(e, found, seen)
+		}
+	case *ssa.Call:
+		if _, ok := sprintfFormat(v.Common()); ok {
+			found[v] = true
+			for _, arg := range v.Common().Args {
+				formatCalls(arg, found, seen)
+			}
+		}
+	}
+}
+
+// sprintfFormat returns the format string of a call to a Sprintf-like
+// function, one which takes a format string and a variadic ...interface{}
+// operands and returns a string, if the format is a constant.
+func sprintfFormat(cc *ssa.CallCommon) (string, bool) {
+	callee := cc.StaticCallee()
+	if callee == nil || len(cc.Args) < 2 {
+		return "", false
+	}
+	sig := callee.Signature
+	params := sig.Params()
+	if !sig.Variadic() || params.Len() < 2 || sig.Results().Len() != 1 || !isString(sig.Results().At(0).Type()) {
+		return "", false
+	}
+	if !isString(params.At(params.Len() - 2).Type()) {
+		return "", false
+	}
+	operands, ok := params.At(params.Len() - 1).Type().(*types.Slice)
+	if !ok {
+		return "", false
+	}
+	if iface, ok := operands.Elem().Underlying().(*types.Interface); !ok || !iface.Empty() {
+		return "", false
+	}
+	return stringConst(cc.Args[len(cc.Args)-2])
+}
+
+func isString(t types.Type) bool {
+	b, ok := t.Underlying().(*types.Basic)
+	return ok && b.Info()&types.IsString != 0
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/xwb1989/sqlparser"
+)
+
+// ValidateSQL returns an error if the given query, which may consist of
+// several statements separated by semicolons, doesn't parse. The parser
+// understands MySQL's grammar, with ? and :name placeholders.
+func ValidateSQL(query string) error {
+	pieces, err := sqlparser.SplitStatementToPieces(query)
+	if err != nil {
+		return err
+	}
+	for _, piece := range pieces {
+		if strings.TrimSpace(piece) == "" {
+			continue
+		}
+		if _, err := sqlparser.Parse(piece); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+package main
+
+import "testing"
+
+func TestValidateSQL(t *testing.T) {
+	for query, valid := range map[string]bool{
+		"SELECT * FROM users WHERE id = ?":                     true,
+		"SELECT name FROM users WHERE id = :id LIMIT 1":        true,
+		"UPDATE users SET name = ? WHERE id = ?":               true,
+		"SET @x = 1; SELECT @x":                                true,
+		"SELECT * FROM users WHERE id = ? AND":                 false,
+		"SELECT * FROM usersWHERE id = ?":                      false,
+		"SELECT * FROM users WHERE id = ?; UPDATE users SET":   false,
+		"INSERT INTO users (name) VALUES (?), (?)":             true,
+		"SELECT name FROM users ORDER BY name DESC LIMIT 10 ,": false,
+	} {
+		err := ValidateSQL(query)
+		if valid && err != nil {
+			t.Errorf("%q: unexpected error %v", query, err)
+		} else if !valid && err == nil {
+			t.Errorf("%q: expected an error", query)
+		}
+	}
+}
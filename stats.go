@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Stats summarizes a run.
+type Stats struct {
+	// Packages is the number of packages analyzed, including dependencies.
+	Packages int
+	// Sinks is the number of query methods found in the database packages.
+	Sinks int
+	// CallSites is the number of calls to query methods which were
+	// checked, and ConstCallSites how many of them were passed constant
+	// queries.
+	CallSites      int
+	ConstCallSites int
+	// Findings counts the findings which fail the run by rule and by
+	// severity.
+	FindingsByRule     map[string]int
+	FindingsBySeverity map[Level]int
+	Suppressed         int
+	Duration           time.Duration
+
+	// findings counts the findings by rule, severity and package
+	// together, for WriteMetrics.
+	findings map[findingKey]int
+}
+
+type findingKey struct {
+	Rule     string
+	Severity Level
+	Package  string
+}
+
+// NewStats returns empty statistics.
+func NewStats() *Stats {
+	return &Stats{
+		FindingsByRule:     make(map[string]int),
+		FindingsBySeverity: make(map[Level]int),
+		findings:           make(map[findingKey]int),
+	}
+}
+
+// AddFinding counts a finding which fails the run.
+func (s *Stats) AddFinding(r Result) {
+	s.FindingsByRule[r.Rule]++
+	s.FindingsBySeverity[r.Severity]++
+	s.findings[findingKey{r.Rule, r.Severity, r.Package}]++
+}
+
+// Findings returns the number of findings which fail the run.
+func (s *Stats) Findings() int {
+	n := 0
+	for _, count := range s.FindingsByRule {
+		n += count
+	}
+	return n
+}
+
+// Metrics returns the statistics as a flat list of named numbers, in a fixed
+// order, for machine-readable formats.
+func (s *Stats) Metrics() []Metric {
+	metrics := []Metric{
+		{"packages", float64(s.Packages)},
+		{"sinks", float64(s.Sinks)},
+		{"callSites", float64(s.CallSites)},
+		{"constCallSites", float64(s.ConstCallSites)},
+		{"findings", float64(s.Findings())},
+	}
+	rules := make([]string, 0, len(s.FindingsByRule))
+	for rule := range s.FindingsByRule {
+		rules = append(rules, rule)
+	}
+	sort.Strings(rules)
+	for _, rule := range rules {
+		metrics = append(metrics, Metric{"findings." + rule, float64(s.FindingsByRule[rule])})
+	}
+	for l := LevelHigh; l >= LevelLow; l-- {
+		metrics = append(metrics, Metric{"findings." + l.String(), float64(s.FindingsBySeverity[l])})
+	}
+	return append(metrics,
+		Metric{"suppressed", float64(s.Suppressed)},
+		Metric{"seconds", s.Duration.Seconds()},
+	)
+}
+
+// A Metric is a named statistic.
+type Metric struct {
+	Name  string
+	Value float64
+}
+
+// Write writes the statistics to w for people to read.
+func (s *Stats) Write(w io.Writer) {
+	var bySeverity []string
+	for l := LevelHigh; l >= LevelLow; l-- {
+		if n := s.FindingsBySeverity[l]; n > 0 {
+			bySeverity = append(bySeverity, fmt.Sprintf("%d %s", n, l))
+		}
+	}
+	var byRule []string
+	for _, m := range s.Metrics() {
+		if strings.HasPrefix(m.Name, "findings."+RulePrefix) {
+			byRule = append(byRule, fmt.Sprintf("%s: %d", strings.TrimPrefix(m.Name, "findings."), int(m.Value)))
+		}
+	}
+
+	fmt.Fprintln(w, "Summary:")
+	fmt.Fprintf(w, "  packages analyzed:  %d\n", s.Packages)
+	fmt.Fprintf(w, "  query methods:      %d\n", s.Sinks)
+	fmt.Fprintf(w, "  call sites checked: %d (%d constant)\n", s.CallSites, s.ConstCallSites)
+	fmt.Fprintf(w, "  findings:           %d", s.Findings())
+	if len(byRule) > 0 {
+		fmt.Fprintf(w, " (%s; %s)", strings.Join(byRule, ", "), strings.Join(bySeverity, ", "))
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "  suppressed:         %d\n", s.Suppressed)
+	fmt.Fprintf(w, "  time:               %s\n", s.Duration.Round(time.Millisecond))
+}
+
+// A StatsReporter is a Reporter which can include statistics about the run in
+// its report.
+type StatsReporter interface {
+	Reporter
+	// SetStats sets the statistics to include in the report.
+	SetStats(*Stats)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	s := NewStats()
+	s.Packages, s.Sinks, s.CallSites, s.ConstCallSites = 12, 30, 45, 42
+	s.AddFinding(Result{Rule: RuleNonConstQuery, Severity: LevelHigh})
+	s.AddFinding(Result{Rule: RuleNonConstQuery, Severity: LevelMedium})
+	s.AddFinding(Result{Rule: RuleNonConstQuery, Severity: LevelMedium})
+	s.Suppressed = 4
+	s.Duration = 1234567 * time.Microsecond
+
+	var buf bytes.Buffer
+	s.Write(&buf)
+	expected := `Summary:
+  packages analyzed:  12
+  query methods:      30
+  call sites checked: 45 (42 constant)
+  findings:           3 (SAFESQL001: 3; 1 high, 2 medium)
+  suppressed:         4
+  time:               1.235s
+`
+	if buf.String() != expected {
+		t.Errorf("Write wrote:\n%s\nexpected:\n%s", buf.String(), expected)
+	}
+
+	metrics := s.Metrics()
+	if len(metrics) != 11 || metrics[3] != (Metric{"constCallSites", 42}) || metrics[5] != (Metric{"findings.SAFESQL001", 3}) || metrics[7] != (Metric{"findings.medium", 2}) {
+		t.Errorf("unexpected metrics %v", metrics)
+	}
+}
+
+func TestWriteMetrics(t *testing.T) {
+	dir, err := ioutil.TempDir("", "safesql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	s := NewStats()
+	s.Packages, s.Sinks, s.CallSites, s.ConstCallSites = 12, 30, 45, 42
+	s.AddFinding(Result{Rule: RuleNonConstQuery, Severity: LevelHigh, Package: "example.com/app/db"})
+	s.AddFinding(Result{Rule: RuleNonConstQuery, Severity: LevelHigh, Package: "example.com/app/db"})
+	s.AddFinding(Result{Rule: RuleNonConstQuery, Severity: LevelLow, Package: "example.com/app/api"})
+	s.Duration = 1500 * time.Millisecond
+
+	prom := filepath.Join(dir, "safesql.prom")
+	if err := WriteMetrics(prom, s); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(prom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		"safesql_call_sites 45\n",
+		"safesql_const_call_sites 42\n",
+		"safesql_duration_seconds 1.5\n",
+		`safesql_findings{rule="SAFESQL001",severity="high",package="example.com/app/db"} 2` + "\n",
+		`safesql_findings{rule="SAFESQL001",severity="low",package="example.com/app/api"} 1` + "\n",
+	} {
+		if !strings.Contains(string(data), line) {
+			t.Errorf("expected %q in:\n%s", line, data)
+		}
+	}
+
+	js := filepath.Join(dir, "safesql.json")
+	if err := WriteMetrics(js, s); err != nil {
+		t.Fatal(err)
+	}
+	data, err = ioutil.ReadFile(js)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var metrics metricsFile
+	if err := json.Unmarshal(data, &metrics); err != nil {
+		t.Fatal(err)
+	}
+	if metrics.Findings != 3 || len(metrics.Counts) != 2 || metrics.Counts[0] != (metricFinding{RuleNonConstQuery, "high", "example.com/app/db", 2}) {
+		t.Errorf("unexpected metrics %+v", metrics)
+	}
+}
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+const IgnoreComment = "//nolint:safesql"
+
+// IgnoreDirective is safesql's own ignore comment, for teams which don't use
+// the //nolint convention. It can be followed by a comma-separated list of
+// rule identifiers, in which case it only ignores issues from those rules.
+const IgnoreDirective = "//safesql:ignore"
+
+type Issue struct {
+	statement token.Position
+	ignored   bool
+}
+
+// Suppressor decides whether issues are suppressed by an ignore comment. A
+// comment suppresses an issue if it is attached (in the sense of
+// ast.NewCommentMap) to the statement containing the issue, i.e. if it is
+// either on a line of its own directly above the statement, or at the end of
+// the statement's first or last line. Comments attached to the enclosing function
+// declaration or to the package clause suppress issues in the whole function
+// or file respectively.
+type Suppressor struct {
+	fset *token.FileSet
+	// files are keyed by fileKey, so that an issue is matched up with its
+	// file however the name is spelled, e.g. on Windows.
+	files map[string]*suppressedFile
+	// used records the directives which suppressed at least one issue.
+	used map[*ast.Comment]bool
+}
+
+type suppressedFile struct {
+	file *ast.File
+	cmap ast.CommentMap
+}
+
+// NewSuppressor returns a Suppressor which uses the given parsed files, which
+// must have been parsed with comments. Issues in other files are checked by
+// parsing those files from disk.
+func NewSuppressor(fset *token.FileSet, files []*ast.File) *Suppressor {
+	s := &Suppressor{
+		fset:  fset,
+		files: make(map[string]*suppressedFile),
+		used:  make(map[*ast.Comment]bool),
+	}
+	for _, f := range files {
+		s.add(f)
+	}
+	return s
+}
+
+func (s *Suppressor) add(f *ast.File) *suppressedFile {
+	sf := &suppressedFile{file: f, cmap: ast.NewCommentMap(s.fset, f, f.Comments)}
+	s.files[fileKey(s.fset.Position(f.Pos()).Filename)] = sf
+	return sf
+}
+
+func (s *Suppressor) file(filename string) (*suppressedFile, error) {
+	if sf, ok := s.files[fileKey(filename)]; ok {
+		return sf, nil
+	}
+	f, err := parser.ParseFile(s.fset, filename, nil, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	return s.add(f), nil
+}
+
+// Ignored reports whether an issue from the given rule at the given position
+// is suppressed by an ignore comment.
+func (s *Suppressor) Ignored(position token.Position, rule string) (bool, error) {
+	sf, err := s.file(position.Filename)
+	if err != nil {
+		return false, err
+	}
+	tf := s.fset.File(sf.file.Pos())
+	if position.Line < 1 || position.Line > tf.LineCount() {
+		return false, nil
+	}
+	pos := tf.LineStart(position.Line) + token.Pos(position.Column-1)
+	if position.Column < 1 {
+		pos = tf.LineStart(position.Line)
+	}
+
+	path, _ := astutil.PathEnclosingInterval(sf.file, pos, pos)
+	inStmt := true
+	for _, n := range path {
+		switch n.(type) {
+		case *ast.FuncDecl, *ast.File:
+			// A directive above a function declaration (or on the line of
+			// its signature) suppresses the whole function, and one above
+			// the package clause suppresses the whole file.
+			if s.hasDirective(sf, n, rule, true) {
+				return true, nil
+			}
+		default:
+			// Otherwise, only comments on the statement itself (or the
+			// nodes inside it) count, not those on enclosing blocks.
+			if inStmt && s.hasDirective(sf, n, rule, false) {
+				return true, nil
+			}
+		}
+		if _, ok := n.(ast.Stmt); ok && inStmt {
+			// The reported position of a call whose arguments span
+			// several lines may well not be the first line of the
+			// statement, so a directive anywhere on that line counts
+			// too, even if the comment map attached it to a node which
+			// doesn't enclose the call.
+			if s.hasDirectiveOnFirstLine(sf, n, rule) {
+				return true, nil
+			}
+			inStmt = false
+		}
+	}
+	return false, nil
+}
+
+// hasDirective reports whether an ignore directive for the given rule is
+// attached to n. If leading is true, only directives starting no later than
+// the first line of n are considered.
+func (s *Suppressor) hasDirective(sf *suppressedFile, n ast.Node, rule string, leading bool) bool {
+	for _, cg := range sf.cmap[n] {
+		if leading && s.fset.Position(cg.Pos()).Line > s.fset.Position(n.Pos()).Line {
+			continue
+		}
+		for _, c := range cg.List {
+			rules, ok := ParseIgnoreDirective(c.Text)
+			if !ok || (rules != nil && !contains(rules, rule)) {
+				continue
+			}
+			s.used[c] = true
+			return true
+		}
+	}
+	return false
+}
+
+// hasDirectiveOnFirstLine reports whether an ignore directive for the given
+// rule is inside n, on its first line.
+func (s *Suppressor) hasDirectiveOnFirstLine(sf *suppressedFile, n ast.Node, rule string) bool {
+	line := s.fset.Position(n.Pos()).Line
+	for _, cg := range sf.file.Comments {
+		if cg.Pos() >= n.End() {
+			break
+		}
+		if cg.End() <= n.Pos() || s.fset.Position(cg.Pos()).Line != line {
+			continue
+		}
+		for _, c := range cg.List {
+			rules, ok := ParseIgnoreDirective(c.Text)
+			if !ok || (rules != nil && !contains(rules, rule)) {
+				continue
+			}
+			s.used[c] = true
+			return true
+		}
+	}
+	return false
+}
+
+// Unused returns the positions of the ignore directives in the given files
+// which haven't suppressed any of the issues checked so far, sorted by
+// position. These are usually left over from code that has since been fixed
+// or removed.
+func (s *Suppressor) Unused(files []*ast.File) []token.Position {
+	unused := make([]token.Position, 0)
+	for _, f := range files {
+		for _, cg := range f.Comments {
+			for _, c := range cg.List {
+				if IsIgnoreDirective(c.Text) && !s.used[c] {
+					unused = append(unused, s.fset.Position(c.Pos()))
+				}
+			}
+		}
+	}
+	sort.Slice(unused, func(i, j int) bool { return positionLess(unused[i], unused[j]) })
+	return unused
+}
+
+// CheckIssues marks the issues at the given positions which are suppressed by
+// an ignore comment. The issues are returned sorted by position.
+func (s *Suppressor) CheckIssues(lines []token.Position) ([]Issue, error) {
+	files := make(map[string][]token.Position)
+
+	for _, line := range lines {
+		key := fileKey(line.Filename)
+		files[key] = append(files[key], line)
+	}
+
+	issues := []Issue{}
+
+	for _, linesInFile := range files {
+		// ensure we have the lines in ascending order
+		sort.Slice(linesInFile, func(i, j int) bool { return linesInFile[i].Line < linesInFile[j].Line })
+
+		for _, line := range linesInFile {
+			isIgnored, err := s.Ignored(line, RuleNonConstQuery)
+			if err != nil {
+				return nil, err
+			}
+			issues = append(issues, Issue{statement: line, ignored: isIgnored})
+		}
+	}
+
+	sort.Slice(issues, func(i, j int) bool { return positionLess(issues[i].statement, issues[j].statement) })
+	return issues, nil
+}
+
+// CheckIssues checks whether the statements at the given positions have an
+// ignore comment on the line before or at the end of the line, parsing the
+// files they are in from disk.
+func CheckIssues(lines []token.Position) ([]Issue, error) {
+	return NewSuppressor(token.NewFileSet(), nil).CheckIssues(lines)
+}
+
+// IsIgnoreDirective reports whether the text of a comment is an ignore
+// directive.
+func IsIgnoreDirective(text string) bool {
+	_, ok := ParseIgnoreDirective(text)
+	return ok
+}
+
+// ParseIgnoreDirective parses the text of a comment. It reports whether the
+// comment is an ignore directive, i.e. either
+//
+//	//nolint:safesql
+//
+// (possibly listing other linters too), or
+//
+//	//safesql:ignore
+//
+// (possibly limited to some rules), either of which can be followed by an
+// explanation. If the directive only applies to some rules, they are
+// returned.
+func ParseIgnoreDirective(text string) (rules []string, ok bool) {
+	switch {
+	case strings.HasPrefix(text, "//nolint:"):
+		linters := strings.TrimPrefix(text, "//nolint:")
+		if i := strings.IndexAny(linters, " \t/"); i >= 0 {
+			linters = linters[:i]
+		}
+		return nil, contains(strings.Split(linters, ","), "safesql")
+	case text == IgnoreDirective:
+		return nil, true
+	case strings.HasPrefix(text, IgnoreDirective+" "), strings.HasPrefix(text, IgnoreDirective+"\t"):
+		fields := strings.Fields(strings.TrimPrefix(text, IgnoreDirective))
+		if len(fields) == 0 {
+			return nil, true
+		}
+		// The first word is either a list of rules or the start of the
+		// explanation.
+		rules = strings.Split(fields[0], ",")
+		for _, r := range rules {
+			if !strings.HasPrefix(r, RulePrefix) {
+				return nil, true
+			}
+		}
+		return rules, true
+	}
+	return nil, false
+}
+
+func contains(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+// The mechanisms by which a finding can be suppressed, in the order they are
+// checked.
+const (
+	SuppressedByComment  = "ignore comment"
+	SuppressedByConfig   = "configuration"
+	SuppressedByGosec    = "gosec"
+	SuppressedByBaseline = "baseline"
+	SuppressedByDiff     = "diff"
+)
+
+var suppressionMechanisms = []string{SuppressedByComment, SuppressedByConfig, SuppressedByGosec, SuppressedByBaseline, SuppressedByDiff}
+
+// SuppressionSummary counts the findings which were suppressed, by mechanism
+// and by file, so that an accumulation of suppressions doesn't go unnoticed.
+type SuppressionSummary struct {
+	total  int
+	counts map[string]map[string]int
+}
+
+// Add records that a finding in the given file was suppressed by the given
+// mechanism.
+func (s *SuppressionSummary) Add(mechanism, filename string) {
+	if s.counts == nil {
+		s.counts = make(map[string]map[string]int)
+	}
+	if s.counts[mechanism] == nil {
+		s.counts[mechanism] = make(map[string]int)
+	}
+	s.counts[mechanism][filename]++
+	s.total++
+}
+
+// Total returns the number of suppressed findings.
+func (s *SuppressionSummary) Total() int {
+	return s.total
+}
+
+// Write writes the summary to w, with the files for each mechanism sorted by
+// name.
+func (s *SuppressionSummary) Write(w io.Writer) {
+	fmt.Fprintf(w, "Suppressed %d potentially unsafe SQL statements:\n", s.total)
+	for _, mechanism := range suppressionMechanisms {
+		files := s.counts[mechanism]
+		if len(files) == 0 {
+			continue
+		}
+		names := make([]string, 0, len(files))
+		n := 0
+		for name, count := range files {
+			names = append(names, name)
+			n += count
+		}
+		sort.Strings(names)
+		fmt.Fprintf(w, "- %d by %s\n", n, mechanism)
+		for _, name := range names {
+			fmt.Fprintf(w, "  %s: %d\n", name, files[name])
+		}
+	}
+}
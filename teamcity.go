@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// A TeamCityReport accumulates findings to write as TeamCity inspection
+// service messages, which show up in a build's Inspections tab. Only the
+// findings which fail the run are included.
+type TeamCityReport struct {
+	root    string
+	results []Result
+	stats   *Stats
+}
+
+// NewTeamCityReport returns an empty report whose file names are relative to
+// the given directory.
+func NewTeamCityReport(root string) *TeamCityReport {
+	return &TeamCityReport{root: root}
+}
+
+// Add adds a finding to the report.
+func (r *TeamCityReport) Add(result Result) {
+	if !result.Suppressed {
+		r.results = append(r.results, result)
+	}
+}
+
+// SetStats includes statistics about the run in the report, as build
+// statistics which TeamCity can chart.
+func (r *TeamCityReport) SetStats(stats *Stats) {
+	r.stats = stats
+}
+
+// Write writes the report to w, starting with the inspection types of the
+// rules which have findings.
+func (r *TeamCityReport) Write(w io.Writer) error {
+	used := make(map[string]bool)
+	for _, res := range r.results {
+		used[res.Rule] = true
+	}
+	for _, rule := range Rules {
+		if !used[rule.ID] {
+			continue
+		}
+		_, err := fmt.Fprintf(w, "##teamcity[inspectionType id='%s' name='%s' description='%s' category='%s']\n",
+			teamcityEscape(rule.ID), teamcityEscape(rule.Name), teamcityEscape(rule.Description), teamcityEscape(rule.Category))
+		if err != nil {
+			return err
+		}
+	}
+	for _, res := range r.results {
+		name := res.Position.Filename
+		if rel, ok := relPath(r.root, name); ok {
+			name = rel
+		}
+		_, err := fmt.Fprintf(w, "##teamcity[inspection typeId='%s' message='%s' file='%s' line='%d' SEVERITY='%s']\n",
+			teamcityEscape(res.Rule), teamcityEscape(res.Message), teamcityEscape(name), res.Position.Line,
+			severityName(res.Severity, "ERROR", "WARNING", "INFO"))
+		if err != nil {
+			return err
+		}
+	}
+	if r.stats != nil {
+		for _, m := range r.stats.Metrics() {
+			_, err := fmt.Fprintf(w, "##teamcity[buildStatisticValue key='safesql.%s' value='%s']\n",
+				teamcityEscape(m.Name), strconv.FormatFloat(m.Value, 'f', -1, 64))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+var teamcityEscaper = strings.NewReplacer("|", "||", "'", "|'", "\n", "|n", "\r", "|r", "[", "|[", "]", "|]")
+
+func teamcityEscape(s string) string {
+	return teamcityEscaper.Replace(s)
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"go/token"
+	"testing"
+)
+
+func TestTeamCityReport(t *testing.T) {
+	r := NewTeamCityReport("/src/app")
+	r.Add(Result{
+		Rule:     RuleNonConstQuery,
+		Position: token.Position{Filename: "/src/app/db/db.go", Line: 14, Column: 19},
+		Message:  "Query passed to 'Query' [sic] is not a compile-time constant",
+	})
+	r.Add(Result{
+		Rule:       RuleNonConstQuery,
+		Position:   token.Position{Filename: "/src/app/db/db.go", Line: 20, Column: 19},
+		Suppressed: true,
+	})
+
+	var buf bytes.Buffer
+	if err := r.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	expected := "##teamcity[inspectionType id='SAFESQL001' name='NonConstantQuery' description='SQL query is not a compile-time constant' category='Security']\n" +
+		"##teamcity[inspection typeId='SAFESQL001' message='Query passed to |'Query|' |[sic|] is not a compile-time constant' file='db/db.go' line='14' SEVERITY='ERROR']\n"
+	if buf.String() != expected {
+		t.Errorf("Write wrote:\n%s\nexpected:\n%s", buf.String(), expected)
+	}
+}
+
+func TestTeamCityReportStats(t *testing.T) {
+	r := NewTeamCityReport("")
+	stats := NewStats()
+	stats.Packages = 12
+	r.SetStats(stats)
+
+	var buf bytes.Buffer
+	if err := r.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte("##teamcity[buildStatisticValue key='safesql.packages' value='12']\n")) {
+		t.Errorf("report doesn't include the number of packages:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"text/template"
+)
+
+// A TemplateReport writes each finding which fails the run using a
+// user-supplied text/template, for tools which expect some other line format.
+type TemplateReport struct {
+	root    string
+	tmpl    *template.Template
+	results []Result
+}
+
+// TemplateFinding is what a -template is executed with for each finding.
+type TemplateFinding struct {
+	File        string
+	Line        int
+	Column      int
+	Rule        string
+	Message     string
+	Package     string
+	Severity    string
+	Confidence  string
+	Fingerprint string
+	// CWE, OWASP and HelpURI classify and document the finding's rule, as
+	// in Rule.
+	CWE     []string
+	OWASP   []string
+	HelpURI string
+	// Commands are the main packages the finding is in, as in Result.
+	Commands []string
+	// Flow and Related are the steps leading up to the finding and other
+	// places it shows up, as in Result.
+	Flow    []FlowStep
+	Related []FlowStep
+}
+
+// NewTemplateReport returns an empty report which writes each finding with the
+// given template, followed by a newline if the template doesn't end with one.
+// File names are relative to root.
+func NewTemplateReport(root, text string) (*TemplateReport, error) {
+	if !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	tmpl, err := template.New("finding").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	return &TemplateReport{root: root, tmpl: tmpl}, nil
+}
+
+// Add adds a finding to the report.
+func (r *TemplateReport) Add(result Result) {
+	if !result.Suppressed {
+		r.results = append(r.results, result)
+	}
+}
+
+// Write writes the report to w.
+func (r *TemplateReport) Write(w io.Writer) error {
+	for _, res := range r.results {
+		pos := ShowPosition(r.root, res.Position)
+		rule, _ := LookupRule(res.Rule)
+		err := r.tmpl.Execute(w, TemplateFinding{
+			File:        pos.Filename,
+			Line:        pos.Line,
+			Column:      pos.Column,
+			Rule:        res.Rule,
+			Message:     res.Message,
+			Package:     res.Package,
+			Severity:    res.Severity.String(),
+			Confidence:  res.Confidence.String(),
+			Fingerprint: res.Fingerprint,
+			CWE:         rule.CWE,
+			OWASP:       rule.OWASP,
+			HelpURI:     rule.HelpURI(),
+			Commands:    res.Commands,
+			Flow:        res.Flow,
+			Related:     res.Related,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
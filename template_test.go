@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bytes"
+	"go/token"
+	"testing"
+)
+
+func TestTemplateReport(t *testing.T) {
+	r, err := NewTemplateReport("/src/app", "{{.File}}:{{.Line}} [{{.Severity}}] {{.Message}}{{range .Flow}} <- {{.Message}}{{end}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Add(Result{
+		Rule:     RuleNonConstQuery,
+		Position: token.Position{Filename: "/src/app/db/db.go", Line: 14, Column: 19},
+		Message:  "Query is not a compile-time constant",
+		Severity: LevelHigh,
+		Flow:     []FlowStep{{token.Position{Filename: "/src/app/db/db.go", Line: 12, Column: 2}, "parameter name"}},
+	})
+	r.Add(Result{
+		Rule:       RuleNonConstQuery,
+		Position:   token.Position{Filename: "/src/app/db/db.go", Line: 20, Column: 19},
+		Suppressed: true,
+	})
+
+	var buf bytes.Buffer
+	if err := r.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	expected := "db/db.go:14 [high] Query is not a compile-time constant <- parameter name\n"
+	if buf.String() != expected {
+		t.Errorf("Write wrote %q, expected %q", buf.String(), expected)
+	}
+
+	if _, err := NewTemplateReport("", "{{.File"); err == nil {
+		t.Error("NewTemplateReport accepted a malformed template")
+	}
+}
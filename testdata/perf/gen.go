@@ -0,0 +1,132 @@
+//go:build ignore
+// +build ignore
+
+// gen writes a synthetic program for benchmarking safesql, in GOPATH layout:
+// a chain of store packages, each importing the one before it, which query the
+// database in the ways safesql has to tell apart (constant queries, queries
+// built with fmt.Sprintf, and calls through an interface), and commands which
+// each use a share of them.
+//
+//	go run testdata/perf/gen.go -out /tmp/perf -packages 500 -commands 20
+//	GOPATH=/tmp/perf GO111MODULE=off safesql example.com/perf/...
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"text/template"
+)
+
+const root = "example.com/perf"
+
+var storeTemplate = template.Must(template.New("store").Parse(`package {{.Name}}
+
+import (
+	"database/sql"
+	"fmt"
+{{if .Prev}}
+	"{{.Root}}/store/{{.Prev}}"
+{{end}}
+)
+
+type Store struct {
+	db *sql.DB
+}
+
+func New(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+func (s *Store) Get(id int) (*sql.Rows, error) {
+	return s.db.Query("SELECT * FROM {{.Name}} WHERE id = ?", id)
+}
+
+func (s *Store) Search(column string) (*sql.Rows, error) {
+	return s.db.Query(fmt.Sprintf("SELECT * FROM {{.Name}} ORDER BY %s", column))
+}
+
+type querier interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+func (s *Store) Count(q querier) error {
+	_, err := q.Query("SELECT COUNT(*) FROM {{.Name}}")
+	return err
+}
+{{if .Prev}}
+func (s *Store) Related(id int) (*sql.Rows, error) {
+	return {{.Prev}}.New(s.db).Get(id)
+}
+{{end}}`))
+
+var commandTemplate = template.Must(template.New("command").Parse(`package main
+
+import (
+	"database/sql"
+	"os"
+{{range .Stores}}
+	"{{$.Root}}/store/{{.}}"
+{{- end}}
+)
+
+func main() {
+	db, _ := sql.Open("mysql", "")
+{{- range .Stores}}
+	{{.}}.New(db).Get(1)
+	{{.}}.New(db).Search(os.Args[1])
+	{{.}}.New(db).Count(db)
+{{- end}}
+}
+`))
+
+func main() {
+	out := flag.String("out", "", "GOPATH directory to write the program to")
+	packages := flag.Int("packages", 200, "Number of store packages")
+	commands := flag.Int("commands", 10, "Number of commands")
+	flag.Parse()
+	if *out == "" || *packages < 1 || *commands < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	stores := make([]string, *packages)
+	for i := range stores {
+		stores[i] = fmt.Sprintf("store%04d", i)
+		data := map[string]string{"Root": root, "Name": stores[i]}
+		if i > 0 {
+			data["Prev"] = stores[i-1]
+		}
+		write(filepath.Join(*out, "src", root, "store", stores[i], "store.go"), storeTemplate, data)
+	}
+	for i := 0; i < *commands; i++ {
+		var used []string
+		for j := i; j < len(stores); j += *commands {
+			used = append(used, stores[j])
+		}
+		data := map[string]interface{}{"Root": root, "Stores": used}
+		write(filepath.Join(*out, "src", root, "cmd", fmt.Sprintf("cmd%02d", i), "main.go"), commandTemplate, data)
+	}
+}
+
+func write(name string, t *template.Template, data interface{}) {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		log.Fatal(err)
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		log.Fatalf("%s: %v", name, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		log.Fatal(err)
+	}
+	if err := ioutil.WriteFile(name, src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,13 @@
+// Package sqlitex is a stub of the parts of crawshaw.io/sqlite/sqlitex the
+// want fixtures use.
+package sqlitex
+
+import "crawshaw.io/sqlite"
+
+func Exec(conn *sqlite.Conn, query string, resultFn func(stmt *sqlite.Stmt) error, args ...interface{}) error {
+	return nil
+}
+func ExecTransient(conn *sqlite.Conn, query string, resultFn func(stmt *sqlite.Stmt) error, args ...interface{}) error {
+	return nil
+}
+func ExecScript(conn *sqlite.Conn, queries string) error { return nil }
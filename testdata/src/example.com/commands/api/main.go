@@ -0,0 +1,8 @@
+package main
+
+import "example.com/commands/store"
+
+func main() {
+	store.Shared()
+	store.Search()
+}
@@ -0,0 +1,10 @@
+// Package store is shared by the commands beside it, for TestCommandIndex.
+package store
+
+func Shared() string { return query("shared") }
+
+func Search() string { return query("search") }
+
+func Unused() string { return query("unused") }
+
+func query(q string) string { return q }
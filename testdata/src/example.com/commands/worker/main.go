@@ -0,0 +1,7 @@
+package main
+
+import "example.com/commands/store"
+
+var startup = store.Shared()
+
+func main() {}
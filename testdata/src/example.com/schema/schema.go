@@ -0,0 +1,26 @@
+// Package schema sets up the database schema when it's initialized, as some
+// programs do.
+package schema
+
+import (
+	"github.com/jmoiron/sqlx"
+
+	"input"
+)
+
+var DB, _ = sqlx.Connect("mysql", "")
+
+var table = input.Read()
+
+var created = create()
+
+func create() bool {
+	DB.Exec("CREATE TABLE IF NOT EXISTS users (id INT)")
+	DB.Exec("CREATE TABLE IF NOT EXISTS " + table + " (id INT)") // want "SAFESQL001"
+	return true
+}
+
+func init() {
+	DB.Exec("CREATE INDEX users_id ON users (id)")
+	DB.Exec("CREATE INDEX " + table + "_id ON " + table + " (id)") // want "SAFESQL001"
+}
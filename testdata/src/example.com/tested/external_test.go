@@ -0,0 +1,11 @@
+package tested_test
+
+import (
+	"testing"
+
+	"example.com/tested"
+)
+
+func BenchmarkFind(b *testing.B) { tested.OnlyBenchmarked() }
+
+func ExampleFind() { tested.Find("users") }
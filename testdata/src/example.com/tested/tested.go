@@ -0,0 +1,10 @@
+// Package tested has tests, for TestTestMains.
+package tested
+
+func Find(table string) string { return build(table) }
+
+func OnlyBenchmarked() string { return build("benchmarks") }
+
+func NotTested() string { return build("nothing") }
+
+func build(table string) string { return "SELECT * FROM " + table }
@@ -0,0 +1,12 @@
+package tested
+
+import "testing"
+
+func TestMain(m *testing.M) { m.Run() }
+
+func TestFind(t *testing.T) { Find("users") }
+
+// Testlower isn't a test, since its name goes on in lower case.
+func Testlower(t *testing.T) { NotTested() }
+
+func helper(t *testing.T) { NotTested() }
@@ -0,0 +1,37 @@
+// Package tracedsql is an instrumented wrapper around github.com/jmoiron/sqlx,
+// like the DB types of otelsql and ocsql, which traces each query before
+// running it.
+package tracedsql
+
+import (
+	"github.com/jmoiron/sqlx"
+)
+
+type DB struct {
+	db    *sqlx.DB
+	Trace func(query string)
+}
+
+func Connect(driverName, dataSourceName string) (*DB, error) {
+	db, err := sqlx.Connect(driverName, dataSourceName)
+	return &DB{db: db, Trace: func(string) {}}, err
+}
+
+func (db *DB) Select(dest interface{}, query string, args ...interface{}) error {
+	db.Trace(query)
+	return db.db.Select(dest, query, args...)
+}
+
+// MustSelect wraps the wrapper.
+func (db *DB) MustSelect(dest interface{}, query string, args ...interface{}) {
+	if err := db.Select(dest, query, args...); err != nil {
+		panic(err)
+	}
+}
+
+// Count isn't a wrapper, since it builds its own query.
+func (db *DB) Count(table string) (int, error) {
+	var n int
+	err := db.db.Get(&n, "SELECT COUNT(*) FROM "+table) // want "SAFESQL001"
+	return n, err
+}
@@ -0,0 +1,24 @@
+// Package sqlmock is a stub of the parts of github.com/DATA-DOG/go-sqlmock the
+// want fixtures use.
+package sqlmock
+
+import "database/sql"
+
+type ExpectedQuery struct{}
+
+type ExpectedExec struct{}
+
+type Sqlmock interface {
+	ExpectQuery(expectedSQL string) *ExpectedQuery
+	ExpectExec(expectedSQL string) *ExpectedExec
+}
+
+type sqlmock struct{}
+
+func (c *sqlmock) ExpectQuery(expectedSQL string) *ExpectedQuery { return &ExpectedQuery{} }
+func (c *sqlmock) ExpectExec(expectedSQL string) *ExpectedExec   { return &ExpectedExec{} }
+
+func New() (*sql.DB, Sqlmock, error) {
+	db, err := sql.Open("sqlmock", "")
+	return db, &sqlmock{}, err
+}
@@ -0,0 +1,31 @@
+// Package squirrel is a stub of the parts of github.com/Masterminds/squirrel
+// the want fixtures use.
+package squirrel
+
+type Sqlizer interface {
+	ToSql() (string, []interface{}, error)
+}
+
+type Eq map[string]interface{}
+
+func (eq Eq) ToSql() (string, []interface{}, error) { return "", nil, nil }
+
+type expr struct {
+	sql  string
+	args []interface{}
+}
+
+func (e expr) ToSql() (string, []interface{}, error) { return e.sql, e.args, nil }
+
+func Expr(sql string, args ...interface{}) Sqlizer { return expr{sql: sql, args: args} }
+
+type SelectBuilder struct{}
+
+func Select(columns ...string) SelectBuilder { return SelectBuilder{} }
+
+func (b SelectBuilder) From(from string) SelectBuilder                             { return b }
+func (b SelectBuilder) Where(pred interface{}, args ...interface{}) SelectBuilder  { return b }
+func (b SelectBuilder) Having(pred interface{}, rest ...interface{}) SelectBuilder { return b }
+func (b SelectBuilder) Join(join string, rest ...interface{}) SelectBuilder        { return b }
+func (b SelectBuilder) Suffix(sql string, args ...interface{}) SelectBuilder       { return b }
+func (b SelectBuilder) ToSql() (string, []interface{}, error)                      { return "", nil, nil }
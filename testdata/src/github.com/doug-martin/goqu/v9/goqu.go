@@ -0,0 +1,25 @@
+// Package goqu is a stub of the parts of github.com/doug-martin/goqu/v9 the
+// want fixtures use.
+package goqu
+
+type Expression interface{}
+
+type LiteralExpression struct {
+	sql  string
+	args []interface{}
+}
+
+func L(sql string, args ...interface{}) LiteralExpression {
+	return LiteralExpression{sql: sql, args: args}
+}
+
+func Literal(sql string, args ...interface{}) LiteralExpression { return L(sql, args...) }
+
+type Ex map[string]interface{}
+
+type SelectDataset struct{}
+
+func From(table ...interface{}) *SelectDataset { return &SelectDataset{} }
+
+func (sd *SelectDataset) Where(expressions ...Expression) *SelectDataset { return sd }
+func (sd *SelectDataset) ToSQL() (string, []interface{}, error)          { return "", nil, nil }
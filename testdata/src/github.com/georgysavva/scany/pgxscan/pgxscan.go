@@ -0,0 +1,32 @@
+// Package pgxscan is a stub of the parts of
+// github.com/georgysavva/scany/pgxscan the want fixtures use.
+package pgxscan
+
+// Context stands in for context.Context, so that the stub doesn't import the
+// standard library.
+type Context interface{}
+
+type Rows interface{}
+
+type Querier interface {
+	Query(ctx Context, query string, args ...interface{}) (Rows, error)
+}
+
+type API struct{}
+
+func NewAPI() (*API, error) { return &API{}, nil }
+
+func Select(ctx Context, db Querier, dst interface{}, query string, args ...interface{}) error {
+	return nil
+}
+func Get(ctx Context, db Querier, dst interface{}, query string, args ...interface{}) error {
+	return nil
+}
+func ScanAll(dst interface{}, rows Rows) error { return nil }
+
+func (api *API) Select(ctx Context, db Querier, dst interface{}, query string, args ...interface{}) error {
+	return nil
+}
+func (api *API) Get(ctx Context, db Querier, dst interface{}, query string, args ...interface{}) error {
+	return nil
+}
@@ -0,0 +1,21 @@
+// Package sqlscan is a stub of the parts of
+// github.com/georgysavva/scany/sqlscan the want fixtures use.
+package sqlscan
+
+// Context stands in for context.Context, so that the stub doesn't import the
+// standard library.
+type Context interface{}
+
+type Rows interface{}
+
+type Querier interface {
+	QueryContext(ctx Context, query string, args ...interface{}) (Rows, error)
+}
+
+func Select(ctx Context, db Querier, dst interface{}, query string, args ...interface{}) error {
+	return nil
+}
+func Get(ctx Context, db Querier, dst interface{}, query string, args ...interface{}) error {
+	return nil
+}
+func ScanAll(dst interface{}, rows Rows) error { return nil }
@@ -0,0 +1,29 @@
+// Package dbr is a stub of the parts of github.com/gocraft/dbr/v2 the want
+// fixtures use.
+package dbr
+
+type Builder interface {
+	Build(buf []byte) error
+}
+
+type raw struct {
+	Query string
+	Value []interface{}
+}
+
+func (raw *raw) Build(buf []byte) error { return nil }
+
+func Expr(query string, value ...interface{}) Builder { return &raw{Query: query, Value: value} }
+
+type Session struct{}
+
+type SelectStmt struct{}
+
+func (sess *Session) Select(column ...string) *SelectStmt { return &SelectStmt{} }
+func (sess *Session) SelectBySql(query string, value ...interface{}) *SelectStmt {
+	return &SelectStmt{}
+}
+
+func (b *SelectStmt) From(table interface{}) *SelectStmt                        { return b }
+func (b *SelectStmt) Where(query interface{}, value ...interface{}) *SelectStmt { return b }
+func (b *SelectStmt) Load(value interface{}) (int, error)                       { return 0, nil }
@@ -0,0 +1,26 @@
+// Package godror is a stub of the parts of github.com/godror/godror the want
+// fixtures use.
+package godror
+
+// Context stands in for context.Context, so that the stub doesn't import the
+// standard library.
+type Context interface{}
+
+type Execer interface{}
+
+type Subscription struct{}
+
+type Queue struct{}
+
+type ObjectType struct{}
+
+func NewQueue(ctx Context, execer Execer, name string, payloadObjectTypeName string) (*Queue, error) {
+	return &Queue{}, nil
+}
+
+func GetObjectType(ctx Context, ex Execer, typeName string) (*ObjectType, error) {
+	return &ObjectType{}, nil
+}
+
+func (s *Subscription) Register(qry string, params ...interface{}) error { return nil }
+func (s *Subscription) Close() error                                     { return nil }
@@ -0,0 +1,42 @@
+// Package pgx is a stub of the parts of github.com/jackc/pgx/v5 the want
+// fixtures use.
+package pgx
+
+// Context stands in for context.Context, so that the stub doesn't import the
+// standard library.
+type Context interface{}
+
+type Conn struct{}
+
+type Rows interface{}
+
+type Row interface{}
+
+type CommandTag struct{}
+
+type BatchResults interface{}
+
+type Batch struct {
+	QueuedQueries []*QueuedQuery
+}
+
+type QueuedQuery struct {
+	SQL       string
+	Arguments []interface{}
+}
+
+func Connect(ctx Context, connString string) (*Conn, error) { return &Conn{}, nil }
+
+func (c *Conn) Exec(ctx Context, sql string, arguments ...interface{}) (CommandTag, error) {
+	return CommandTag{}, nil
+}
+func (c *Conn) Query(ctx Context, sql string, args ...interface{}) (Rows, error) { return nil, nil }
+func (c *Conn) QueryRow(ctx Context, sql string, args ...interface{}) Row        { return nil }
+func (c *Conn) SendBatch(ctx Context, b *Batch) BatchResults                     { return nil }
+
+func (b *Batch) Queue(query string, arguments ...interface{}) *QueuedQuery {
+	qq := &QueuedQuery{SQL: query, Arguments: arguments}
+	b.QueuedQueries = append(b.QueuedQueries, qq)
+	return qq
+}
+func (b *Batch) Len() int { return len(b.QueuedQueries) }
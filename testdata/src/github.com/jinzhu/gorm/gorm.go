@@ -0,0 +1,24 @@
+// Package gorm is a stub of the parts of github.com/jinzhu/gorm the want
+// fixtures use.
+package gorm
+
+type DB struct{}
+
+func Open(dialect string, args ...interface{}) (*DB, error) { return &DB{}, nil }
+
+func (s *DB) Raw(sql string, values ...interface{}) *DB        { return s }
+func (s *DB) Exec(sql string, values ...interface{}) *DB       { return s }
+func (s *DB) Where(query interface{}, args ...interface{}) *DB { return s }
+func (s *DB) Find(out interface{}, where ...interface{}) *DB   { return s }
+
+type SqlExpr struct {
+	expr string
+	args []interface{}
+}
+
+func Expr(expression string, args ...interface{}) *SqlExpr {
+	return &SqlExpr{expr: expression, args: args}
+}
+
+func (s *DB) Model(value interface{}) *DB     { return s }
+func (s *DB) Update(attrs ...interface{}) *DB { return s }
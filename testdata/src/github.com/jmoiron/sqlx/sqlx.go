@@ -0,0 +1,45 @@
+// Package sqlx is a stub of the parts of github.com/jmoiron/sqlx the want
+// fixtures use.
+package sqlx
+
+type DB struct{}
+
+type Tx struct{}
+
+type Rows struct{}
+
+type Row struct{}
+
+type Stmt struct{}
+
+type Result interface{}
+
+type Queryer interface {
+	Queryx(query string, args ...interface{}) (*Rows, error)
+}
+
+type Execer interface {
+	Exec(query string, args ...interface{}) (Result, error)
+}
+
+type Ext interface {
+	Queryer
+	Execer
+}
+
+func Connect(driverName, dataSourceName string) (*DB, error) { return &DB{}, nil }
+
+func (db *DB) Select(dest interface{}, query string, args ...interface{}) error { return nil }
+func (db *DB) Get(dest interface{}, query string, args ...interface{}) error    { return nil }
+func (db *DB) Queryx(query string, args ...interface{}) (*Rows, error)          { return &Rows{}, nil }
+func (db *DB) Exec(query string, args ...interface{}) (Result, error)           { return nil, nil }
+func (db *DB) Beginx() (*Tx, error)                                             { return &Tx{}, nil }
+func (db *DB) Preparex(query string) (*Stmt, error)                             { return &Stmt{}, nil }
+
+func (tx *Tx) Queryx(query string, args ...interface{}) (*Rows, error) { return &Rows{}, nil }
+func (tx *Tx) Exec(query string, args ...interface{}) (Result, error)  { return nil, nil }
+func (tx *Tx) Stmtx(stmt interface{}) *Stmt                            { return &Stmt{} }
+
+func (s *Stmt) Queryx(args ...interface{}) (*Rows, error)          { return &Rows{}, nil }
+func (s *Stmt) QueryRowx(args ...interface{}) *Row                 { return &Row{} }
+func (s *Stmt) Select(dest interface{}, args ...interface{}) error { return nil }
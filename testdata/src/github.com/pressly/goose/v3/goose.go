@@ -0,0 +1,30 @@
+// Package goose is a stub of the parts of github.com/pressly/goose/v3 the want
+// fixtures use.
+package goose
+
+import (
+	"database/sql"
+	"io/fs"
+	"path"
+)
+
+var baseFS fs.FS
+
+func SetBaseFS(fsys fs.FS) { baseFS = fsys }
+
+func Up(db *sql.DB, dir string) error {
+	entries, err := fs.ReadDir(baseFS, dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		data, err := fs.ReadFile(baseFS, path.Join(dir, e.Name()))
+		if err != nil {
+			return err
+		}
+		if _, err := db.Exec(string(data)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
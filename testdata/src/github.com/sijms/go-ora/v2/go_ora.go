@@ -0,0 +1,26 @@
+// Package go_ora is a stub of the parts of github.com/sijms/go-ora/v2 the want
+// fixtures use.
+package go_ora
+
+// Value stands in for driver.Value, so that the stub doesn't import the
+// standard library.
+type Value interface{}
+
+type Connection struct{}
+
+type Stmt struct{}
+
+type Rows interface{}
+
+type Result interface{}
+
+func NewConnection(databaseUrl string) (*Connection, error) { return &Connection{}, nil }
+
+func NewStmt(text string, conn *Connection) *Stmt { return &Stmt{} }
+
+func (conn *Connection) Open() error                                     { return nil }
+func (conn *Connection) Exec(text string, args ...Value) (Result, error) { return nil, nil }
+func (conn *Connection) Query(query string, args []Value) (Rows, error)  { return nil, nil }
+func (conn *Connection) Prepare(query string) (*Stmt, error)             { return &Stmt{}, nil }
+
+func (stmt *Stmt) Query(args []Value) (Rows, error) { return nil, nil }
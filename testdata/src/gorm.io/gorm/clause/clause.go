@@ -0,0 +1,15 @@
+// Package clause is a stub of the parts of gorm.io/gorm/clause the want
+// fixtures use.
+package clause
+
+type Expression interface{}
+
+type Expr struct {
+	SQL  string
+	Vars []interface{}
+}
+
+type NamedExpr struct {
+	SQL  string
+	Vars []interface{}
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"github.com/jmoiron/sqlx"
+)
+
+type Job struct {
+	Payload interface{}
+}
+
+func main() {
+	db, _ := sqlx.Connect("mysql", "")
+	run(db, Job{Payload: "SELECT * FROM users"})
+}
+
+// Whatever was put in the interface, a string asserted from it can't be
+// verified to be constant, so it's reported.
+func run(db *sqlx.DB, job Job) {
+	q := job.Payload.(string)
+	db.Queryx(q) // want "SAFESQL001"
+	if q, ok := job.Payload.(string); ok {
+		db.Queryx(q) // want "SAFESQL001"
+	}
+}
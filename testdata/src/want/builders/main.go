@@ -0,0 +1,32 @@
+package main
+
+import (
+	sq "github.com/Masterminds/squirrel"
+	"github.com/doug-martin/goqu/v9"
+	"github.com/gocraft/dbr/v2"
+
+	"input"
+)
+
+func main() {
+	status := input.Read()
+
+	// A builder only protects the values it's given separately, not raw
+	// conditions built from them.
+	sq.Select("*").From("users").Where("status = ?", status)
+	sq.Select("*").From("users").Where(sq.Eq{"status": status})
+	sq.Select("*").From("users").Where("status = '" + status + "'") // want "SAFESQL001"
+	sq.Select("*").From("users").Where(sq.Expr("status = ?", status))
+	sq.Select("*").From("users").Where(sq.Expr("status = " + status)) // want "SAFESQL001"
+	sq.Select("*").From("users").Suffix("LIMIT " + status)            // want "SAFESQL001"
+
+	goqu.From("users").Where(goqu.Ex{"status": status})
+	goqu.From("users").Where(goqu.L("status = ?", status))
+	goqu.From("users").Where(goqu.L("status = '" + status + "'")) // want "SAFESQL001"
+
+	sess := &dbr.Session{}
+	sess.Select("*").From("users").Where("status = ?", status)
+	sess.Select("*").From("users").Where(dbr.Expr("status = ?", status))
+	sess.Select("*").From("users").Where("status = '" + status + "'") // want "SAFESQL001"
+	sess.SelectBySql("SELECT * FROM users WHERE status = " + status)  // want "SAFESQL001"
+}
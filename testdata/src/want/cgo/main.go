@@ -0,0 +1,20 @@
+package main
+
+// #include <stdlib.h>
+import "C"
+
+import (
+	"github.com/jmoiron/sqlx"
+
+	"input"
+)
+
+func main() {
+	db, _ := sqlx.Connect("sqlite3", "")
+	name := input.Read()
+
+	// Code in a package which uses cgo is analyzed like any other.
+	limit := int(C.abs(-10))
+	db.Queryx("SELECT * FROM users LIMIT ?", limit)
+	db.Queryx("SELECT * FROM users WHERE name = '" + name + "'") // want "SAFESQL001"
+}
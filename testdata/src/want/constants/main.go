@@ -0,0 +1,65 @@
+package main
+
+import (
+	"github.com/jmoiron/sqlx"
+
+	"input"
+)
+
+const usersTable = "users"
+
+const selectUsers = "SELECT * FROM " + usersTable
+
+var fixedQuery = "SELECT COUNT(*) FROM users"
+
+// activeUsers is written once, with a helper, when the package is initialized.
+var activeUsers = selectFrom(usersTable) + " WHERE active"
+
+func main() {
+	db, _ := sqlx.Connect("mysql", "")
+	name := input.Read()
+
+	// Constants, and concatenations of them, are safe.
+	db.Queryx(selectUsers)
+	db.Queryx(selectUsers + " WHERE active")
+
+	// So is a choice between constants.
+	query := "SELECT * FROM users"
+	if name == "" {
+		query = "SELECT * FROM admins"
+	}
+	db.Queryx(query)
+
+	// And a package variable which is only ever assigned constants.
+	db.Queryx(fixedQuery)
+
+	// Or one which is written once, when the package is initialized, with
+	// a value built from constants.
+	db.Queryx(activeUsers)
+
+	// Queries built from parameters are reported where they're used, with
+	// low confidence, even if every caller passes a constant.
+	count(db, "users")
+	count(db, "admins")
+	search(db, "name")
+	search(db, name)
+
+	// A query received from a channel is only constant if everything sent
+	// on it is.
+	queries := make(chan string, 2)
+	queries <- "SELECT * FROM users"
+	queries <- "SELECT * FROM users WHERE name = '" + name + "'"
+	db.Queryx(<-queries) // want "SAFESQL001"
+}
+
+func count(db *sqlx.DB, table string) {
+	db.Queryx("SELECT COUNT(*) FROM " + table) // want "SAFESQL001"
+}
+
+func search(db *sqlx.DB, column string) {
+	db.Queryx("SELECT * FROM users ORDER BY " + column) // want "SAFESQL001"
+}
+
+func selectFrom(table string) string {
+	return "SELECT * FROM " + table
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"os"
+)
+
+func main() {
+	db, _ := sql.Open("mysql", "")
+	name := os.Args[1]
+
+	db.Query("SELECT * FROM users WHERE name = ?", name)
+	db.Query("SELECT * FROM users WHERE name = '" + name + "'") // want "SAFESQL001"
+	db.QueryRow("SELECT * FROM users ORDER BY " + name)         // want "SAFESQL001"
+	db.Exec("DELETE FROM users WHERE name = '" + name + "'")    // want "SAFESQL001"
+
+	tx, _ := db.Begin()
+	tx.Exec("DELETE FROM users")
+	tx.Exec("DELETE FROM " + name) // want "SAFESQL001"
+
+	stmt, _ := db.Prepare("SELECT * FROM users WHERE name = " + name) // want "SAFESQL001"
+	stmt.Close()
+
+	// Method expressions and method values are checked at the calls.
+	ctx := context.Background()
+	(*sql.DB).QueryContext(db, ctx, "SELECT * FROM users WHERE name = ?", name)
+	(*sql.DB).QueryContext(db, ctx, "SELECT * FROM users WHERE name = '"+name+"'") // want "SAFESQL001"
+	query := db.QueryContext
+	query(ctx, "SELECT * FROM users ORDER BY "+name) // want "SAFESQL001"
+}
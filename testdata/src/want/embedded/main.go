@@ -0,0 +1,46 @@
+package main
+
+import (
+	"github.com/jmoiron/sqlx"
+
+	"input"
+)
+
+// Store composes the interfaces sqlx.Ext embeds with one of its own.
+type Store interface {
+	sqlx.Ext
+	Close() error
+}
+
+// DB embeds *sqlx.DB, so its method set has the query methods too.
+type DB struct {
+	*sqlx.DB
+}
+
+func (DB) Close() error { return nil }
+
+func main() {
+	db, _ := sqlx.Connect("mysql", "")
+	tx, _ := db.Beginx()
+	name := input.Read()
+
+	// Calls through sqlx.Ext reach its embedded Queryer and Execer.
+	list(db, name)
+	list(tx, name)
+
+	// As do calls through interfaces which embed it.
+	var s Store = DB{db}
+	s.Queryx("SELECT * FROM users")
+	s.Queryx("SELECT * FROM users WHERE name = '" + name + "'") // want "SAFESQL001"
+	s.Exec("DELETE FROM users WHERE name = '" + name + "'")     // want "SAFESQL001"
+
+	// And calls to the methods promoted from *sqlx.DB.
+	DB{db}.Queryx("SELECT * FROM users ORDER BY " + name) // want "SAFESQL001"
+}
+
+func list(e sqlx.Ext, name string) {
+	e.Queryx("SELECT * FROM users")
+	e.Queryx("SELECT * FROM users WHERE name = '" + name + "'") // want "SAFESQL001"
+	e.Exec("UPDATE users SET seen = 1 WHERE name = ?", name)
+	e.Exec("UPDATE users SET seen = 1 WHERE name = '" + name + "'") // want "SAFESQL001"
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"github.com/jinzhu/gorm"
+
+	"input"
+)
+
+func main() {
+	db, _ := gorm.Open("mysql", "")
+	name := input.Read()
+
+	db.Raw("SELECT * FROM users WHERE name = ?", name)
+	db.Raw("SELECT * FROM users WHERE name = '" + name + "'") // want "SAFESQL001"
+	db.Exec("DELETE FROM users WHERE name = '" + name + "'")  // want "SAFESQL001"
+	db.Where("name = ?", name).Find(nil)
+	db.Where("name = '" + name + "'").Find(nil) // want "SAFESQL001"
+
+	// Conditions given as a map or struct rather than a string aren't
+	// queries.
+	db.Where(map[string]interface{}{"name": name}).Find(nil)
+
+	update(db, input.Read(), name)
+}
+
+func update(db *gorm.DB, column, amount string) {
+	// Expressions are raw SQL, spliced into otherwise safe statements.
+	db.Model(nil).Update("count", gorm.Expr("count + ?", amount))
+	db.Model(nil).Update("count", gorm.Expr(column+" + ?", amount)) // want "SAFESQL001"
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"input"
+)
+
+func main() {
+	db, _ := gorm.Open(nil)
+	name := input.Read()
+
+	db.Raw("SELECT * FROM users WHERE name = ?", name)
+	db.Raw("SELECT * FROM users WHERE name = '" + name + "'") // want "SAFESQL001"
+	db.Where("name = ?", name).Find(nil)
+
+	// Expressions are raw SQL, whether they're made with gorm.Expr or as
+	// clauses.
+	db.Model(nil).Update("count", gorm.Expr("count + ?", name))
+	db.Model(nil).Update("count", gorm.Expr(name+" + 1")) // want "SAFESQL001"
+	db.Clauses(clause.Expr{SQL: "LOCK IN SHARE MODE"}).Find(nil)
+	db.Clauses(clause.Expr{SQL: "ORDER BY " + name}).Find(nil) // want "SAFESQL001"
+	db.Clauses(clause.NamedExpr{SQL: "name = @name", Vars: []interface{}{name}}).Find(nil)
+
+	var e clause.Expr
+	e.SQL = name // want "SAFESQL001"
+	db.Clauses(e).Find(nil)
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"example.com/schema"
+
+	"input"
+)
+
+var name = input.Read()
+
+// Queries run while packages are initialized are checked too, both in init
+// functions and in the initializers of package variables, here and in the
+// packages imported.
+var users, _ = schema.DB.Queryx("SELECT * FROM users WHERE name = '" + name + "'") // want "SAFESQL001"
+
+func init() {
+	schema.DB.Queryx("SELECT * FROM users")
+	schema.DB.Queryx("SELECT * FROM users ORDER BY " + name) // want "SAFESQL001"
+}
+
+func main() {}
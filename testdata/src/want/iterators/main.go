@@ -0,0 +1,42 @@
+package main
+
+import (
+	"github.com/jmoiron/sqlx"
+
+	"input"
+)
+
+// queries is an iterator, as in Go 1.23 range-over-func loops.
+func queries(yield func(string) bool) {
+	for _, q := range []string{"SELECT * FROM users", "SELECT * FROM admins"} {
+		if !yield(q) {
+			return
+		}
+	}
+}
+
+func main() {
+	db, _ := sqlx.Connect("mysql", "")
+	name := input.Read()
+
+	// The body of the loop is a function the iterator calls, so what the
+	// iterator yields is that function's parameter, and never constant.
+	for q := range queries {
+		db.Queryx(q) // want "SAFESQL001"
+	}
+
+	// Constants in the body are still constant.
+	for range queries {
+		db.Queryx("SELECT 1")
+	}
+
+	// As are the variables the body assigns constants to, and only those.
+	for q := range queries {
+		query := "SELECT * FROM users"
+		if q == name {
+			query = "SELECT * FROM admins"
+		}
+		db.Queryx(query)
+		db.Queryx(query + " WHERE name = '" + name + "'") // want "SAFESQL001"
+	}
+}
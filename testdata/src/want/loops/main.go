@@ -0,0 +1,57 @@
+package main
+
+import (
+	"github.com/jmoiron/sqlx"
+
+	"input"
+)
+
+var tables = map[string]string{
+	"users":  "SELECT * FROM users",
+	"admins": "SELECT * FROM admins",
+}
+
+func main() {
+	db, _ := sqlx.Connect("mysql", "")
+	name := input.Read()
+
+	// A query which is only ever assigned constants in a loop is constant.
+	query := "SELECT * FROM users"
+	for i := 0; i < 3; i++ {
+		db.Queryx(query)
+		query = "SELECT * FROM admins"
+	}
+
+	// But one rebuilt from the previous iteration isn't a compile-time
+	// constant, even if only constants are appended to it.
+	query = "SELECT id FROM users"
+	for i := 0; i < 3; i++ {
+		query += " UNION SELECT id FROM users"
+		db.Queryx(query) // want "SAFESQL001"
+	}
+
+	// Appending anything else to it makes it non-constant from then on,
+	// including at the start of later iterations.
+	query = "SELECT id FROM users"
+	for i := 0; i < 3; i++ {
+		db.Queryx(query) // want "SAFESQL001"
+		query += " WHERE name = '" + name + "'"
+	}
+
+	// Ranging over a map of constants yields constants.
+	for _, q := range tables {
+		db.Queryx(q)
+	}
+
+	// With per-iteration loop variables, each closure captures its own
+	// query, but a captured variable is never constant.
+	var queries []func()
+	for _, q := range tables {
+		queries = append(queries, func() {
+			db.Queryx(q) // want "SAFESQL001"
+		})
+	}
+	for _, run := range queries {
+		run()
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"github.com/jmoiron/sqlx"
+
+	"input"
+)
+
+func main() {
+	db, _ := sqlx.Connect("mysql", "")
+	name := input.Read()
+
+	// A method expression is called with its receiver as its first
+	// argument, so the query is the one after it.
+	(*sqlx.DB).Queryx(db, "SELECT * FROM users WHERE name = ?", name)
+	(*sqlx.DB).Queryx(db, "SELECT * FROM users WHERE name = '"+name+"'") // want "SAFESQL001"
+
+	// A method value is bound to its receiver, and reported where it's
+	// called.
+	exec := db.Exec
+	exec("DELETE FROM users WHERE name = ?", name)
+	exec("DELETE FROM users WHERE name = '" + name + "'") // want "SAFESQL001"
+
+	// Method expressions and values passed to other functions are followed
+	// to where they're called, where the queries, parameters there, are
+	// reported.
+	run(db, (*sqlx.DB).Exec, "DELETE FROM sessions")
+	each(db.Queryx, "SELECT * FROM sessions WHERE name = '"+name+"'")
+}
+
+func run(db *sqlx.DB, exec func(*sqlx.DB, string, ...interface{}) (sqlx.Result, error), query string) {
+	exec(db, query) // want "SAFESQL001"
+}
+
+func each(query func(string, ...interface{}) (*sqlx.Rows, error), q string) {
+	query(q) // want "SAFESQL001"
+}
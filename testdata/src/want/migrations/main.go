@@ -0,0 +1,32 @@
+package main
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"github.com/pressly/goose/v3"
+)
+
+//go:embed migrations/*.sql
+var embedMigrations embed.FS
+
+//go:embed migrations/00001_init.sql
+var schema []byte
+
+func main() {
+	db, _ := sql.Open("mysql", "")
+
+	// The migration tool runs the migrations itself, and embedded files are
+	// as trusted as constants.
+	goose.SetBaseFS(embedMigrations)
+	goose.Up(db, "migrations")
+	db.Exec(string(schema))
+	up, _ := fs.ReadFile(embedMigrations, "migrations/00001_init.sql")
+	db.Exec(string(up))
+
+	// Migrations assembled at runtime are checked like any other query.
+	db.Exec(fmt.Sprintf("ALTER TABLE users ADD COLUMN %s TEXT", os.Args[1])) // want "SAFESQL001"
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"github.com/jmoiron/sqlx"
+
+	"input"
+)
+
+func main() {
+	db, _ := sqlx.Connect("mysql", "user:pass@tcp(db:3306)/app?multiStatements=true")
+	name := input.Read()
+
+	db.Exec("DELETE FROM users WHERE name = '" + name + "'") // want "SAFESQL001"
+	db.Queryx("SELECT id FROM users ORDER BY " + name)       // want "SAFESQL001"
+
+	query := "UPDATE users SET name = '" + name + "'"
+	db.Queryx(query) // want "SAFESQL001"
+	db.Exec(query)   // want "SAFESQL001"
+}
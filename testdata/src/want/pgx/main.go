@@ -0,0 +1,24 @@
+package main
+
+import (
+	"github.com/jackc/pgx/v5"
+
+	"input"
+)
+
+func main() {
+	var ctx pgx.Context
+	conn, _ := pgx.Connect(ctx, "")
+	name := input.Read()
+
+	conn.Exec(ctx, "DELETE FROM users WHERE name = $1", name)
+	conn.Query(ctx, "SELECT id FROM users WHERE name = '"+name+"'") // want "SAFESQL001"
+
+	// Batched queries are run later, by SendBatch, but are as unsafe.
+	b := &pgx.Batch{}
+	b.Queue("UPDATE users SET seen = now() WHERE name = $1", name)
+	b.Queue("DELETE FROM sessions WHERE user_name = '" + name + "'") // want "SAFESQL001"
+	b.QueuedQueries = append(b.QueuedQueries, &pgx.QueuedQuery{SQL: "SELECT 1"})
+	b.QueuedQueries = append(b.QueuedQueries, &pgx.QueuedQuery{SQL: "SELECT " + name}) // want "SAFESQL001"
+	conn.SendBatch(ctx, b)
+}
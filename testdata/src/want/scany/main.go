@@ -0,0 +1,36 @@
+package main
+
+import (
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/georgysavva/scany/sqlscan"
+
+	"input"
+)
+
+type user struct {
+	ID   int
+	Name string
+}
+
+func main() {
+	var ctx pgxscan.Context
+	var pool pgxscan.Querier
+	var db sqlscan.Querier
+	name := input.Read()
+
+	var users []*user
+	var u user
+	pgxscan.Select(ctx, pool, &users, "SELECT id, name FROM users WHERE name = $1", name)
+	pgxscan.Select(ctx, pool, &users, "SELECT id, name FROM users WHERE name = '"+name+"'") // want "SAFESQL001"
+	pgxscan.Get(ctx, pool, &u, "SELECT id, name FROM users ORDER BY "+name+" LIMIT 1")      // want "SAFESQL001"
+	pgxscan.ScanAll(&users, nil)
+
+	api, _ := pgxscan.NewAPI()
+	api.Get(ctx, pool, &u, "SELECT id, name FROM users WHERE id = 1")
+	api.Select(ctx, pool, &users, "SELECT id, name FROM users WHERE name = '"+name+"'") // want "SAFESQL001"
+
+	sqlscan.Select(ctx, db, &users, "SELECT id, name FROM users")
+	sqlscan.Get(ctx, db, &u, "SELECT id, name FROM users WHERE name = ?", name)
+	sqlscan.Select(ctx, db, &users, name)                                   // want "SAFESQL001"
+	sqlscan.Get(ctx, db, &u, "SELECT id, name FROM users WHERE id = "+name) // want "SAFESQL001"
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"crawshaw.io/sqlite"
+	"crawshaw.io/sqlite/sqlitex"
+	zsqlite "zombiezen.com/go/sqlite"
+	zsqlitex "zombiezen.com/go/sqlite/sqlitex"
+
+	"input"
+)
+
+func main() {
+	name := input.Read()
+
+	conn, _ := sqlite.OpenConn("app.db", 0)
+	stmt := conn.Prep("SELECT id FROM users WHERE name = $name")
+	stmt.SetText("$name", name)
+	stmt.Step()
+	conn.Prep("SELECT id FROM users WHERE name = '" + name + "'") // want "SAFESQL001"
+	conn.Prepare("SELECT id FROM " + name)                        // want "SAFESQL001"
+	conn.PrepareTransient("DELETE FROM " + name)                  // want "SAFESQL001"
+	sqlitex.Exec(conn, "DELETE FROM users WHERE name = ?", nil, name)
+	sqlitex.Exec(conn, "DELETE FROM users WHERE name = '"+name+"'", nil) // want "SAFESQL001"
+	sqlitex.ExecTransient(conn, "SELECT id FROM "+name, nil)             // want "SAFESQL001"
+	sqlitex.ExecScript(conn, "CREATE TABLE users (id INTEGER, name TEXT);")
+
+	zconn, _ := zsqlite.OpenConn("app.db")
+	zconn.Prep("SELECT id FROM users WHERE name = ?")
+	zconn.Prep("SELECT id FROM users ORDER BY " + name)          // want "SAFESQL001"
+	zconn.PrepareTransient("SELECT id FROM users LIMIT " + name) // want "SAFESQL001"
+	zsqlitex.Execute(zconn, "DELETE FROM users WHERE name = ?", &zsqlitex.ExecOptions{Args: []interface{}{name}})
+	zsqlitex.Execute(zconn, "DELETE FROM users WHERE name = '"+name+"'", nil) // want "SAFESQL001"
+	zsqlitex.ExecuteTransient(zconn, "SELECT id FROM "+name, nil)             // want "SAFESQL001"
+	zsqlitex.ExecuteScript(zconn, "DELETE FROM "+name+";", nil)               // want "SAFESQL001"
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"database/sql"
+	"os"
+	"regexp"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func main() {
+	db, _ := sql.Open("mysql", "")
+	table := os.Args[1]
+	db.Query("SELECT * FROM " + table) // want "SAFESQL001"
+
+	// Expectations are regular expressions, often built from queries, and
+	// the queries run against a mock database don't reach a real one.
+	mockDB, mock, _ := sqlmock.New()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM " + table))
+	mockDB.Query("SELECT * FROM " + table)
+	tx, _ := mockDB.Begin()
+	mock.ExpectExec("DELETE FROM " + table)
+	tx.Exec("DELETE FROM " + table)
+}
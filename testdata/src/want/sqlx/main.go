@@ -0,0 +1,19 @@
+package main
+
+import (
+	"github.com/jmoiron/sqlx"
+
+	"input"
+)
+
+func main() {
+	db, _ := sqlx.Connect("mysql", "")
+	name := input.Read()
+
+	var ids []int
+	db.Select(&ids, "SELECT id FROM users WHERE name = ?", name)
+	db.Select(&ids, "SELECT id FROM users WHERE name = '"+name+"'") // want "SAFESQL001"
+	db.Get(&ids, "SELECT id FROM users ORDER BY "+name)             // want "SAFESQL001"
+	db.Queryx("SELECT id FROM users")
+	db.Queryx(name) // want "SAFESQL001"
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"github.com/jmoiron/sqlx"
+
+	"input"
+)
+
+// Store prepares its statements once, when it's made, and runs them later.
+type Store struct {
+	db         *sqlx.DB
+	getUser    *sqlx.Stmt
+	listUsers  *sqlx.Stmt
+	searchUser *sqlx.Stmt
+}
+
+func NewStore(db *sqlx.DB, order string) *Store {
+	s := &Store{db: db}
+	// The queries are checked where they're prepared, once, however many
+	// times the statements are run.
+	s.getUser, _ = db.Preparex("SELECT * FROM users WHERE id = ?")
+	s.listUsers, _ = db.Preparex("SELECT * FROM users ORDER BY " + order) // want "SAFESQL001"
+	s.searchUser, _ = db.Preparex("SELECT * FROM users WHERE name LIKE ?")
+	return s
+}
+
+// Running a prepared statement takes only its arguments, so it's never
+// reported, whatever they are.
+func (s *Store) GetUser(id string) {
+	s.getUser.QueryRowx(id)
+}
+
+func (s *Store) ListUsers() {
+	var names []string
+	s.listUsers.Select(&names)
+}
+
+func (s *Store) SearchUser(name string) {
+	tx, _ := s.db.Beginx()
+	tx.Stmtx(s.searchUser).Queryx("%" + name + "%")
+}
+
+func main() {
+	db, _ := sqlx.Connect("mysql", "")
+	s := NewStore(db, input.Read())
+	s.GetUser(input.Read())
+	s.ListUsers()
+	s.SearchUser(input.Read())
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"example.com/tracedsql"
+
+	"input"
+)
+
+func main() {
+	db, _ := tracedsql.Connect("mysql", "")
+	name := input.Read()
+
+	var ids []int
+	db.Select(&ids, "SELECT id FROM users WHERE name = ?", name)
+	db.Select(&ids, "SELECT id FROM users WHERE name = '"+name+"'") // want "SAFESQL001"
+	db.MustSelect(&ids, "SELECT id FROM users")
+	db.MustSelect(&ids, "SELECT id FROM users ORDER BY "+name) // want "SAFESQL001"
+	db.Count("users")
+}
@@ -0,0 +1,17 @@
+// Package sqlitex is a stub of the parts of zombiezen.com/go/sqlite/sqlitex
+// the want fixtures use.
+package sqlitex
+
+import "zombiezen.com/go/sqlite"
+
+type ExecOptions struct {
+	Args       []interface{}
+	ResultFunc func(stmt *sqlite.Stmt) error
+}
+
+func Exec(conn *sqlite.Conn, query string, resultFn func(stmt *sqlite.Stmt) error, args ...interface{}) error {
+	return nil
+}
+func Execute(conn *sqlite.Conn, query string, opts *ExecOptions) error          { return nil }
+func ExecuteTransient(conn *sqlite.Conn, query string, opts *ExecOptions) error { return nil }
+func ExecuteScript(conn *sqlite.Conn, queries string, opts *ExecOptions) error  { return nil }
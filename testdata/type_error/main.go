@@ -0,0 +1,6 @@
+package main
+
+func main() {
+	var query int = "SELECT 1"
+	_ = query
+}
@@ -0,0 +1,6 @@
+package main
+
+func broken() {
+	var query int = "SELECT 1"
+	_ = query
+}
@@ -0,0 +1,11 @@
+package main
+
+import (
+	"database/sql"
+	"os"
+)
+
+func main() {
+	db, _ := sql.Open("mysql", "")
+	db.Query(os.Args[1])
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/types"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/tools/go/loader"
+	"golang.org/x/tools/go/ssa"
+)
+
+// TestMains returns a main package for each of the program's initial packages
+// which has tests, loaded with them, standing in for the test binary go test
+// would build: its main function calls the package's TestMain, tests,
+// benchmarks, fuzz tests and examples, in the package itself and in its
+// external test package. They're then entry points of the call graph like the
+// commands' main functions, so that query helpers which only tests call so far
+// are checked too. Each is named after its test binary, e.g.
+// example.com/app/store.test.
+//
+// The packages are created in s but not built.
+func TestMains(p *loader.Program, s *ssa.Program) ([]*ssa.Package, error) {
+	// The tests of each package under test, in the package itself and in
+	// its external test package, by import path.
+	tests := make(map[string][]testFuncs)
+	for _, info := range p.InitialPackages() {
+		path := info.Pkg.Path()
+		external := isExternalTest(p, info)
+		if external {
+			path = strings.TrimSuffix(path, "_test")
+		}
+		var names []string
+		for _, f := range info.Files {
+			if external || strings.HasSuffix(p.Fset.File(f.Pos()).Name(), "_test.go") {
+				names = append(names, testFuncNames(info.Pkg, f)...)
+			}
+		}
+		if len(names) > 0 {
+			tests[path] = append(tests[path], testFuncs{info.Pkg, names})
+		}
+	}
+	paths := make([]string, 0, len(tests))
+	for path := range tests {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	pkgs := make(map[string]*types.Package, len(p.AllPackages))
+	for pkg := range p.AllPackages {
+		pkgs[pkg.Path()] = pkg
+	}
+	importer := importerFunc(func(path string) (*types.Package, error) {
+		if pkg, ok := pkgs[path]; ok {
+			return pkg, nil
+		}
+		return nil, fmt.Errorf("package %s isn't loaded", path)
+	})
+
+	mains := make([]*ssa.Package, 0, len(paths))
+	for _, path := range paths {
+		name := path + ".test"
+		f, err := parser.ParseFile(p.Fset, name+"/_testmain.go", testMainSource(tests[path]), 0)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		info := &types.Info{
+			Types:      make(map[ast.Expr]types.TypeAndValue),
+			Defs:       make(map[*ast.Ident]types.Object),
+			Uses:       make(map[*ast.Ident]types.Object),
+			Implicits:  make(map[ast.Node]types.Object),
+			Selections: make(map[*ast.SelectorExpr]*types.Selection),
+			Scopes:     make(map[ast.Node]*types.Scope),
+		}
+		conf := types.Config{Importer: importer}
+		pkg, err := conf.Check(name, p.Fset, []*ast.File{f}, info)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		mains = append(mains, s.CreatePackage(pkg, []*ast.File{f}, info, false))
+	}
+	return mains, nil
+}
+
+// testFuncs are the names of the test functions of a package.
+type testFuncs struct {
+	pkg   *types.Package
+	names []string
+}
+
+type importerFunc func(path string) (*types.Package, error)
+
+func (f importerFunc) Import(path string) (*types.Package, error) {
+	return f(path)
+}
+
+// testMainSource returns the source of a main package which calls each of the
+// given test functions, passing nil for their *testing.T, *testing.B,
+// *testing.F or *testing.M.
+func testMainSource(tests []testFuncs) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("package main\n\nimport (\n")
+	for i, t := range tests {
+		fmt.Fprintf(&buf, "\tp%d %q\n", i, t.pkg.Path())
+	}
+	buf.WriteString(")\n\nfunc main() {\n")
+	for i, t := range tests {
+		for _, name := range t.names {
+			if strings.HasPrefix(name, "Example") {
+				fmt.Fprintf(&buf, "\tp%d.%s()\n", i, name)
+			} else {
+				fmt.Fprintf(&buf, "\tp%d.%s(nil)\n", i, name)
+			}
+		}
+	}
+	buf.WriteString("}\n")
+	return buf.Bytes()
+}
+
+// testFuncNames returns the names of the functions declared in f which go
+// test runs.
+func testFuncNames(pkg *types.Package, f *ast.File) []string {
+	var names []string
+	for _, decl := range f.Decls {
+		fd, ok := decl.(*ast.FuncDecl)
+		if !ok || fd.Recv != nil || fd.Type.TypeParams != nil {
+			continue
+		}
+		fn, ok := pkg.Scope().Lookup(fd.Name.Name).(*types.Func)
+		if !ok {
+			continue
+		}
+		sig := fn.Type().(*types.Signature)
+		if sig.Results().Len() > 0 {
+			continue
+		}
+		name := fn.Name()
+		switch {
+		case name == "TestMain" && sig.Params().Len() == 1:
+			names = append(names, name)
+		case isTestName(name, "Example") && sig.Params().Len() == 0:
+			names = append(names, name)
+		case (isTestName(name, "Test") || isTestName(name, "Benchmark") || isTestName(name, "Fuzz")) && sig.Params().Len() == 1:
+			if _, ok := sig.Params().At(0).Type().(*types.Pointer); ok {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// isTestName reports whether name is that of a test function of the kind
+// prefix names, as go test tells them: the prefix followed by nothing or by
+// something which doesn't start with a lower case letter.
+func isTestName(name, prefix string) bool {
+	if !strings.HasPrefix(name, prefix) {
+		return false
+	}
+	if len(name) == len(prefix) {
+		return true
+	}
+	r, _ := utf8.DecodeRuneInString(name[len(prefix):])
+	return !unicode.IsLower(r)
+}
+
+// isExternalTest reports whether info is the external test package of one of
+// the packages loaded with its tests, whose files are all tests.
+func isExternalTest(p *loader.Program, info *loader.PackageInfo) bool {
+	if !strings.HasSuffix(info.Pkg.Path(), "_test") || len(info.Files) == 0 {
+		return false
+	}
+	for _, f := range info.Files {
+		if !strings.HasSuffix(p.Fset.File(f.Pos()).Name(), "_test.go") {
+			return false
+		}
+	}
+	return true
+}
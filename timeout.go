@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// A Deadline stops the run if the analysis takes longer than a timeout, saying
+// how far it got. The packages safesql relies on can't be cancelled, so this
+// is the only way to stop them cleanly. A nil Deadline never expires.
+type Deadline struct {
+	mu       sync.Mutex
+	out      io.Writer
+	timeout  time.Duration
+	phase    string
+	progress []string
+	timer    *time.Timer
+	exit     func(int)
+}
+
+// StartDeadline starts a deadline which, unless it's stopped first, writes
+// what the analysis was doing and what it had found so far to out after the
+// given timeout, and calls exit with status 2. It returns nil if timeout is
+// zero.
+func StartDeadline(out io.Writer, timeout time.Duration, exit func(int)) *Deadline {
+	if timeout <= 0 {
+		return nil
+	}
+	d := &Deadline{out: out, timeout: timeout, exit: exit}
+	d.timer = time.AfterFunc(timeout, d.expire)
+	return d
+}
+
+// Phase records that the analysis is now doing what phase describes, e.g.
+// "running pointer analysis".
+func (d *Deadline) Phase(phase string) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.phase = phase
+}
+
+// Progress records something the analysis found, e.g. "loaded 12 packages",
+// to report if it times out.
+func (d *Deadline) Progress(format string, args ...interface{}) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.progress = append(d.progress, fmt.Sprintf(format, args...))
+}
+
+// Stop stops the deadline, once the analysis is done. Once Stop returns, the
+// deadline won't expire.
+func (d *Deadline) Stop() {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.timer.Stop()
+	d.timer = nil
+}
+
+func (d *Deadline) expire() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.timer == nil {
+		return
+	}
+	fmt.Fprintf(d.out, "error: timed out after %s while %s\n", d.timeout, d.phase)
+	for _, p := range d.progress {
+		fmt.Fprintf(d.out, "- %s\n", p)
+	}
+	d.exit(2)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+	"time"
+)
+
+// syncBuffer is a bytes.Buffer which is safe to write to from the deadline's
+// timer goroutine.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestDeadline(t *testing.T) {
+	var out syncBuffer
+	exited := make(chan int, 1)
+	d := StartDeadline(&out, 10*time.Millisecond, func(status int) { exited <- status })
+	d.Phase("loading packages")
+	d.Progress("loaded %d packages", 12)
+	d.Phase("running pointer analysis")
+
+	select {
+	case status := <-exited:
+		if status != 2 {
+			t.Errorf("expected status 2, got %d", status)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("deadline didn't expire")
+	}
+	expected := "error: timed out after 10ms while running pointer analysis\n- loaded 12 packages\n"
+	if out.String() != expected {
+		t.Errorf("expected %q, got %q", expected, out.String())
+	}
+
+	d = StartDeadline(&out, 10*time.Millisecond, func(int) { t.Errorf("stopped deadline expired") })
+	d.Stop()
+	time.Sleep(50 * time.Millisecond)
+
+	none := StartDeadline(&out, 0, nil)
+	none.Phase("loading packages")
+	none.Stop()
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"go/build"
+	"go/parser"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/loader"
+)
+
+// wantRE matches the annotation on the line of each call a want fixture
+// expects to be reported, e.g. // want "SAFESQL001".
+var wantRE = regexp.MustCompile(`// want "(` + RulePrefix + `[0-9]+)"`)
+
+// TestWant analyzes each command under testdata/src/want, which are loaded
+// from testdata as a GOPATH along with stubs of the database packages safesql
+// knows, and checks that exactly the calls on lines annotated with a
+// // want "SAFESQL001" comment are reported, like analysistest, along with
+// the non-constant SQL stored in the fields which hold it.
+func TestWant(t *testing.T) {
+	gopath, err := filepath.Abs(testDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Setenv("GO111MODULE", os.Getenv("GO111MODULE"))
+	os.Setenv("GO111MODULE", "off")
+	ctxt := build.Default
+	ctxt.GOPATH = gopath
+
+	fixtures, err := ioutil.ReadDir(filepath.Join(gopath, "src", "want"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, fi := range fixtures {
+		pkg := "want/" + fi.Name()
+		t.Run(fi.Name(), func(t *testing.T) {
+			c := loader.Config{
+				Build:       &ctxt,
+				FindPackage: FindPackage,
+				ParserMode:  parser.ParseComments,
+			}
+			c.Import(pkg)
+			p, err := c.Load()
+			if err != nil {
+				t.Fatal(err)
+			}
+			a, err := analyzeProgram(p)
+			if err != nil {
+				if strings.HasPrefix(err.Error(), "panic: ") {
+					// e.g. database/sql, with a version of Go newer than
+					// the golang.org/x/tools safesql is built with.
+					t.Skipf("package ssa can't build the program: %v", err)
+				}
+				t.Fatal(err)
+			}
+
+			// The fixtures' annotations may be in the packages they import
+			// from testdata too, e.g. in wrappers around the stubs.
+			expected := make([]string, 0)
+			for _, info := range p.AllPackages {
+				for _, f := range info.Files {
+					if !strings.HasPrefix(p.Fset.File(f.Pos()).Name(), gopath) {
+						continue
+					}
+					for _, cg := range f.Comments {
+						for _, c := range cg.List {
+							if m := wantRE.FindStringSubmatch(c.Text); m != nil {
+								pos := p.Fset.Position(c.Pos())
+								expected = append(expected, fmt.Sprintf("%s:%d: %s", filepath.Base(pos.Filename), pos.Line, m[1]))
+							}
+						}
+					}
+				}
+			}
+			reported := make([]string, 0, len(a.bad)+len(a.fields))
+			for _, ci := range a.bad {
+				pos := p.Fset.Position(ci.Site.Pos())
+				reported = append(reported, fmt.Sprintf("%s:%d: %s", filepath.Base(pos.Filename), pos.Line, RuleNonConstQuery))
+			}
+			for _, store := range a.fields {
+				pos := p.Fset.Position(store.Pos())
+				reported = append(reported, fmt.Sprintf("%s:%d: %s", filepath.Base(pos.Filename), pos.Line, RuleNonConstQuery))
+			}
+			sort.Strings(expected)
+			sort.Strings(reported)
+			if strings.Join(reported, "\n") != strings.Join(expected, "\n") {
+				t.Errorf("expected:\n%s\nreported:\n%s", strings.Join(expected, "\n"), strings.Join(reported, "\n"))
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/build"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDelay is how long Watch waits for more changes before running again,
+// since editors often write a file in several steps.
+const watchDelay = 100 * time.Millisecond
+
+// Watch runs safesql with the given arguments, and the flags it was run with
+// other than -watch, and runs it again whenever a Go file in one of pkgs or
+// the packages they import, as found in ctxt, changes, until it fails to watch
+// the files. Each run is a separate process, so that it sees the program as it
+// is on disk.
+func Watch(out io.Writer, ctxt *build.Context, pkgs, pkgArgs []string) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	args := make([]string, 0)
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name != "watch" {
+			args = append(args, "-"+f.Name+"="+f.Value.String())
+		}
+	})
+	args = append(args, pkgArgs...)
+
+	for {
+		// The packages may import different ones after a change, so
+		// look for the directories to watch again each time.
+		for _, dir := range watchDirs(ctxt, pkgs) {
+			if err := watcher.Add(dir); err != nil {
+				return err
+			}
+		}
+
+		fmt.Fprintf(out, "--- %s\n", time.Now().Format("15:04:05"))
+		cmd := exec.Command(os.Args[0], args...)
+		cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+		if err := cmd.Run(); err != nil {
+			if _, ok := err.(*exec.ExitError); !ok {
+				return err
+			}
+		}
+
+		if err := waitForChange(watcher); err != nil {
+			return err
+		}
+	}
+}
+
+// waitForChange waits for a change to a Go file, and then until there haven't
+// been any for watchDelay.
+func waitForChange(watcher *fsnotify.Watcher) error {
+	var settled <-chan time.Time
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return fmt.Errorf("watcher closed")
+			}
+			if filepath.Ext(event.Name) == ".go" && event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Remove|fsnotify.Rename) != 0 {
+				settled = time.After(watchDelay)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return fmt.Errorf("watcher closed")
+			}
+			return err
+		case <-settled:
+			return nil
+		}
+	}
+}
+
+// watchDirs returns the directories of the given packages and of the packages
+// they import, directly or indirectly, outside the standard library.
+func watchDirs(ctxt *build.Context, pkgs []string) []string {
+	wd, _ := os.Getwd()
+	seen := make(map[string]bool)
+	dirs := make([]string, 0)
+	var visit func(path, dir string)
+	visit = func(path, dir string) {
+		if seen[path] || path == "C" {
+			return
+		}
+		seen[path] = true
+		pkg, err := FindPackage(ctxt, path, dir, 0)
+		if err != nil || pkg.Goroot {
+			return
+		}
+		dirs = append(dirs, pkg.Dir)
+		for _, imp := range pkg.Imports {
+			visit(imp, pkg.Dir)
+		}
+	}
+	for _, pkg := range pkgs {
+		visit(pkg, wd)
+	}
+	return dirs
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"go/build"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+func TestWatchDirs(t *testing.T) {
+	dirs := watchDirs(&build.Default, []string{"./testdata/multiple_files"})
+	if len(dirs) != 1 || filepath.Base(dirs[0]) != "multiple_files" {
+		t.Errorf("expected only the package's own directory, got %v", dirs)
+	}
+}
+
+func TestWaitForChange(t *testing.T) {
+	dir, err := ioutil.TempDir("", "safesql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Close()
+	if err := watcher.Add(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error)
+	go func() { done <- waitForChange(watcher) }()
+	if err := ioutil.WriteFile(filepath.Join(dir, "notes.txt"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-done:
+		t.Fatalf("returned after a change to a file other than a Go file: %v", err)
+	case <-time.After(2 * watchDelay):
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("didn't return after a change to a Go file")
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+)
+
+// FindWorkspace returns the go.work file of the workspace wd is in, like the
+// go command: the file named by GOWORK if it's set, and otherwise the first
+// go.work in wd or a directory above it. It returns "" if there isn't one, or
+// GOWORK is "off".
+func FindWorkspace(wd string) string {
+	switch gowork := os.Getenv("GOWORK"); gowork {
+	case "":
+	case "off":
+		return ""
+	default:
+		return gowork
+	}
+	for dir := wd; ; dir = filepath.Dir(dir) {
+		name := filepath.Join(dir, "go.work")
+		if fi, err := os.Stat(name); err == nil && !fi.IsDir() {
+			return name
+		}
+		if filepath.Dir(dir) == dir {
+			return ""
+		}
+	}
+}
+
+// WorkspacePatterns returns a pattern matching all of the packages in each of
+// the modules the given go.work file uses, as a directory relative to wd, so
+// that they can all be checked together: calls from one module to a query
+// method wrapper in another are then checked like any other.
+func WorkspacePatterns(gowork, wd string) ([]string, error) {
+	data, err := ioutil.ReadFile(gowork)
+	if err != nil {
+		return nil, err
+	}
+	wf, err := modfile.ParseWork(gowork, data, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(wf.Use) == 0 {
+		return nil, fmt.Errorf("%s doesn't use any modules", gowork)
+	}
+	root := filepath.Dir(gowork)
+	if !filepath.IsAbs(root) {
+		root = filepath.Join(wd, root)
+	}
+	patterns := make([]string, 0, len(wf.Use))
+	for _, use := range wf.Use {
+		dir := filepath.FromSlash(use.Path)
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(root, dir)
+		}
+		rel, err := filepath.Rel(wd, dir)
+		if err != nil {
+			return nil, err
+		}
+		pattern := filepath.ToSlash(rel)
+		if pattern != "." && !strings.HasPrefix(pattern, "../") {
+			pattern = "./" + pattern
+		}
+		patterns = append(patterns, pattern+"/...")
+	}
+	return patterns, nil
+}
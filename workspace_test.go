@@ -0,0 +1,46 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestWorkspacePatterns(t *testing.T) {
+	defer os.Setenv("GOWORK", os.Getenv("GOWORK"))
+	os.Unsetenv("GOWORK")
+
+	dir, err := ioutil.TempDir("", "safesql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	work := "go 1.18\n\nuse (\n\t.\n\t./services/api\n\t./lib/db\n)\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "go.work"), []byte(work), 0644); err != nil {
+		t.Fatal(err)
+	}
+	wd := filepath.Join(dir, "services", "api")
+	if err := os.MkdirAll(wd, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	gowork := FindWorkspace(wd)
+	if gowork != filepath.Join(dir, "go.work") {
+		t.Fatalf("expected to find %s, got %q", filepath.Join(dir, "go.work"), gowork)
+	}
+	patterns, err := WorkspacePatterns(gowork, wd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"../../...", "./...", "../../lib/db/..."}
+	if !reflect.DeepEqual(patterns, expected) {
+		t.Errorf("expected %q, got %q", expected, patterns)
+	}
+
+	os.Setenv("GOWORK", "off")
+	if gowork := FindWorkspace(wd); gowork != "" {
+		t.Errorf("expected no workspace with GOWORK=off, got %s", gowork)
+	}
+}
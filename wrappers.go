@@ -0,0 +1,121 @@
+package main
+
+import (
+	"go/types"
+	"sort"
+
+	"golang.org/x/tools/go/loader"
+	"golang.org/x/tools/go/ssa"
+)
+
+// FindWrapperMethods returns the exported methods on exported types, outside
+// the sqlPackages, which pass a string parameter named like a query (as for
+// SuggestSinks) straight on as the query of one of the given query methods, or
+// of another such method, e.g. the DB types of instrumented drivers, which
+// trace each query before running it with database/sql.
+//
+// These are query methods too: it's the calls to them which must be passed
+// constant queries, rather than the calls inside them, whose queries are
+// their parameters and so are never constant. Only methods which have been
+// built are found, which are those of the packages the commands import.
+func FindWrapperMethods(p *loader.Program, s *ssa.Program, qms []*QueryMethod) []*QueryMethod {
+	paths := make([]string, 0, len(p.AllPackages))
+	pkgs := make(map[string]*types.Package, len(p.AllPackages))
+	for pkg := range p.AllPackages {
+		if !isSQLPackage(pkg.Path()) {
+			paths = append(paths, pkg.Path())
+			pkgs[pkg.Path()] = pkg
+		}
+	}
+	sort.Strings(paths)
+
+	// Candidates are methods with such a parameter which can be called.
+	candidates := make([]*QueryMethod, 0)
+	for _, path := range paths {
+		for _, m := range ExportedMethods(pkgs[path]) {
+			sig := m.Type().(*types.Signature)
+			param, ok := sinkParamIndex(sig)
+			if !ok {
+				continue
+			}
+			fn := s.FuncValue(m)
+			if fn == nil || fn.Blocks == nil {
+				continue
+			}
+			candidates = append(candidates, &QueryMethod{
+				Func:     m,
+				SSA:      fn,
+				ArgCount: sig.Params().Len(),
+				Param:    param,
+			})
+		}
+	}
+
+	// A wrapper may call another wrapper, so look again until no more are
+	// found.
+	known := append([]*QueryMethod(nil), qms...)
+	wrappers := make([]*QueryMethod, 0)
+	for found := true; found; {
+		found = false
+		remaining := candidates[:0]
+		for _, c := range candidates {
+			if forwardsQuery(c, known) {
+				known = append(known, c)
+				wrappers = append(wrappers, c)
+				found = true
+			} else {
+				remaining = append(remaining, c)
+			}
+		}
+		candidates = remaining
+	}
+	return wrappers
+}
+
+// sinkParamIndex returns the index of the first string parameter of s which
+// suggests that it's a query.
+func sinkParamIndex(s *types.Signature) (int, bool) {
+	name, ok := sinkParam(s)
+	if !ok {
+		return 0, false
+	}
+	params := s.Params()
+	for i := 0; i < params.Len(); i++ {
+		if params.At(i).Name() == name {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// forwardsQuery reports whether the method passes its query parameter as the
+// query of a call to one of the query methods, statically or through an
+// interface method of the same name and signature.
+func forwardsQuery(w *QueryMethod, qms []*QueryMethod) bool {
+	// The receiver is the first parameter of the function.
+	param := w.SSA.Params[w.Param+1]
+	refs := param.Referrers()
+	if refs == nil {
+		return false
+	}
+	for _, ref := range *refs {
+		call, ok := ref.(ssa.CallInstruction)
+		if !ok {
+			continue
+		}
+		cc := call.Common()
+		for _, m := range qms {
+			if cc.IsInvoke() {
+				if !m.IsMethod() || cc.Method.Name() != m.Func.Name() || !types.Identical(cc.Method.Type(), m.Func.Type()) {
+					continue
+				}
+			} else if cc.StaticCallee() != m.SSA {
+				continue
+			}
+			if v, ok := QueryArg(cc, m); ok && v == param {
+				return true
+			}
+		}
+	}
+	return false
+}